@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"errors"
+	"golang/jobs"
+	"golang/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BackfillController struct {
+	DB *gorm.DB
+}
+
+func NewBackfillController(db *gorm.DB) *BackfillController {
+	return &BackfillController{DB: db}
+}
+
+// StartBackfill memulai (atau melanjutkan) backfill riwayat NAV sebuah reksa dana di background
+func (bc *BackfillController) StartBackfill(c *gin.Context) {
+	fundID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mutual fund ID"})
+		return
+	}
+
+	var input struct {
+		InceptionDate string `json:"inception_date"`
+		ChunkDays     int    `json:"chunk_days"`
+		Restart       bool   `json:"restart"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			return
+		}
+	}
+
+	opts := jobs.NavBackfillOptions{ChunkDays: input.ChunkDays, Restart: input.Restart}
+	if input.InceptionDate != "" {
+		inception, err := time.Parse("2006-01-02", input.InceptionDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inception_date, expected YYYY-MM-DD"})
+			return
+		}
+		opts.InceptionDate = &inception
+	}
+
+	checkpoint, err := jobs.StartNavBackfill(bc.DB, uint(fundID), opts)
+	if err != nil {
+		if errors.Is(err, jobs.ErrBackfillRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Backfill is already running for this mutual fund"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to start backfill", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, checkpoint)
+}
+
+// GetBackfill menampilkan progres backfill terakhir sebuah reksa dana
+func (bc *BackfillController) GetBackfill(c *gin.Context) {
+	var checkpoint models.BackfillCheckpoint
+	if err := bc.DB.Where("mutual_fund_id = ?", c.Param("id")).First(&checkpoint).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No backfill found for this mutual fund"})
+		return
+	}
+
+	c.JSON(http.StatusOK, checkpoint)
+}
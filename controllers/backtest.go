@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"errors"
+	"golang/utils"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BacktestController struct {
+	DB *gorm.DB
+}
+
+func NewBacktestController(db *gorm.DB) *BacktestController {
+	return &BacktestController{DB: db}
+}
+
+// GetBacktest menangani GET /mutual-funds/:id/backtest: simulasi "bagaimana jika" investasi amount
+// dari start_date sampai end_date (default hari ini) di riwayat NAV reksa dana. Query parameter
+// strategy memilih lump_sum, monthly_dca atau value_averaging (boleh dipisah koma); tanpa strategy
+// semua strategi dijalankan. purchase_fee adalah biaya pembelian dalam desimal (0.01 = 1%, default 0).
+// Tidak ada transaksi atau portfolio yang dibuat.
+func (bc *BacktestController) GetBacktest(c *gin.Context) {
+	fund, ok := findMutualFund(bc.DB, c)
+	if !ok {
+		return
+	}
+
+	amount, err := strconv.ParseFloat(c.Query("amount"), 64)
+	if err != nil || amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be a positive number"})
+		return
+	}
+	feeRate, err := strconv.ParseFloat(c.DefaultQuery("purchase_fee", "0"), 64)
+	if err != nil || feeRate < 0 || feeRate >= 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "purchase_fee must be a decimal between 0 and 1"})
+		return
+	}
+	start, end, ok := parseReturnRange(c)
+	if !ok {
+		return
+	}
+	if start.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date is required"})
+		return
+	}
+
+	strategies := utils.BacktestStrategies
+	if raw := c.Query("strategy"); raw != "" {
+		strategies = nil
+		for _, s := range strings.Split(raw, ",") {
+			s = strings.ToLower(strings.TrimSpace(s))
+			if !utils.ValidBacktestStrategy(s) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "strategy must be lump_sum, monthly_dca or value_averaging"})
+				return
+			}
+			strategies = append(strategies, s)
+		}
+	}
+
+	series, err := utils.GetMutualFundNav(bc.DB, fund.ID, "custom", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if err != nil {
+		c.JSON(upstreamErrorStatus(err), gin.H{"error": "Failed to fetch NAV data", "detail": err.Error()})
+		return
+	}
+	if series.Stale {
+		c.Header("Warning", `110 - "NAV provider unavailable, serving stored data"`)
+	}
+	dists, err := utils.FundDistributions(bc.DB, fund.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch distributions"})
+		return
+	}
+
+	results := make([]utils.BacktestResult, 0, len(strategies))
+	for _, strategy := range strategies {
+		result, err := utils.RunBacktest(fund, series.Navs, dists, amount, feeRate, start, end, strategy)
+		if err != nil {
+			if errors.Is(err, utils.ErrNotEnoughHistory) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "No NAV data in this date range"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run backtest"})
+			return
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mutual_fund_id": fund.ID,
+		"product_name":   series.ProductName,
+		"currency":       fund.Currency,
+		"amount":         amount,
+		"purchase_fee":   feeRate,
+		"start_date":     start.Format("2006-01-02"),
+		"end_date":       end.Format("2006-01-02"),
+		"results":        results,
+	})
+}
@@ -1,74 +1,177 @@
 package controllers
 
 import (
-	"io"
-	"log"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"golang/models"
+	"golang/utils"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-type BareksaController struct{}
+// navPeriods adalah nilai period yang diterima GET /mutual-fund-nav
+var navPeriods = map[string]bool{
+	"1w": true, "1m": true, "3m": true, "6m": true, "ytd": true,
+	"1y": true, "3y": true, "5y": true, "all": true, "custom": true,
+}
+
+// NavPointResponse adalah satu titik NAV pada response GET /mutual-fund-nav
+type NavPointResponse struct {
+	Date string  `json:"date"`
+	Nav  float64 `json:"nav"`
+}
+
+// NavResponse adalah skema response JSON GET /mutual-fund-nav
+type NavResponse struct {
+	MutualFundID uint               `json:"mutual_fund_id"`
+	Name         string             `json:"name"`
+	Period       string             `json:"period"`
+	StartDate    string             `json:"start_date"`
+	EndDate      string             `json:"end_date"`
+	Stale        bool               `json:"stale"`
+	Navs         []NavPointResponse `json:"navs"`
+}
+
+// ErrorResponse adalah amplop error JSON: {"error": {"code": "...", "message": "...", "field": "..."}}
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
 
-func NewBareksaController() *BareksaController {
-	return &BareksaController{}
+func respondError(c *gin.Context, status int, code, message, field string) {
+	c.AbortWithStatusJSON(status, ErrorResponse{Error: ErrorDetail{Code: code, Message: message, Field: field}})
 }
 
-func (bc *BareksaController) GetMutualFundNav(c *gin.Context) {
-	// Ambil parameter dari query
-	id := c.Query("id")
-	cperiod := c.Query("cperiod")
-	startdate := c.Query("startdate")
-	enddate := c.Query("enddate")
-
-	// Buat URL dengan parameter
-	url := "https://www.bareksa.com/ajax/mutualfund/nav/product1/?id=" + id + 
-		"&cperiod=" + cperiod + 
-		"&startdate=" + startdate + 
-		"&enddate=" + enddate
-
-	// Buat HTTP client dengan timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+type NavController struct {
+	DB *gorm.DB
+}
+
+func NewNavController(db *gorm.DB) *NavController {
+	return &NavController{DB: db}
+}
+
+// GetMutualFundNav menangani GET /mutual-fund-nav.
+//
+// Query parameter:
+//   - mutual_fund_id: ID reksa dana internal (wajib)
+//   - period: 1w, 1m, 3m, 6m, ytd, 1y, 3y, 5y, all, atau custom (default 1m)
+//   - start_date, end_date: YYYY-MM-DD, wajib jika period=custom
+//   - format: json (default) atau csv
+//
+// Response JSON mengikuti NavResponse, CSV berisi kolom date,nav. Error dikembalikan
+// dalam bentuk ErrorResponse.
+func (nc *NavController) GetMutualFundNav(c *gin.Context) {
+	fundID, err := strconv.ParseUint(c.Query("mutual_fund_id"), 10, 32)
+	if err != nil || fundID == 0 {
+		respondError(c, http.StatusBadRequest, "invalid_parameter", "mutual_fund_id must be a positive integer", "mutual_fund_id")
+		return
 	}
 
-	// Buat request
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Printf("Failed to create request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
+	period := c.DefaultQuery("period", "1m")
+	if !navPeriods[period] {
+		respondError(c, http.StatusBadRequest, "invalid_parameter", "period must be one of 1w, 1m, 3m, 6m, ytd, 1y, 3y, 5y, all, custom", "period")
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		respondError(c, http.StatusBadRequest, "invalid_parameter", "format must be json or csv", "format")
 		return
 	}
 
-	// Tambahkan header
-	req.Header.Add("X-Requested-With", "XMLHttpRequest")
-	// req.Header.Add("Cookie", "ba_session=d73ebb9b46c1a5fa0a2a8ec8d2987aed; clang=id")
+	startDate := c.Query("start_date")
+	endDate := c.Query("end_date")
+	if period == "custom" {
+		if _, err := time.Parse("2006-01-02", startDate); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid_parameter", "start_date must be an ISO date (YYYY-MM-DD)", "start_date")
+			return
+		}
+		if _, err := time.Parse("2006-01-02", endDate); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid_parameter", "end_date must be an ISO date (YYYY-MM-DD)", "end_date")
+			return
+		}
+		if endDate < startDate {
+			respondError(c, http.StatusBadRequest, "invalid_parameter", "end_date must not be before start_date", "end_date")
+			return
+		}
+	}
 
-	// Kirim request
-	resp, err := client.Do(req)
+	var fund models.MutualFund
+	if err := nc.DB.First(&fund, fundID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "not_found", "Mutual fund not found", "mutual_fund_id")
+		return
+	}
+
+	start, end, err := utils.ResolveNavPeriod(period, startDate, endDate, time.Now())
 	if err != nil {
-		log.Printf("Request failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Request to Bareksa failed"})
+		respondError(c, http.StatusBadRequest, "invalid_parameter", err.Error(), "period")
 		return
 	}
-	defer resp.Body.Close()
 
-	// Baca response body
-	body, err := io.ReadAll(resp.Body)
+	series, err := utils.GetMutualFundNav(nc.DB, fund.ID, period, startDate, endDate)
 	if err != nil {
-		log.Printf("Failed to read response body: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read response body"})
+		code := "internal_error"
+		if utils.IsUpstreamFailure(err) || errors.Is(err, utils.ErrUpstreamRejected) {
+			code = "upstream_error"
+		}
+		respondError(c, upstreamErrorStatus(err), code, "Failed to fetch NAV data", "")
 		return
 	}
 
-	// Salin header yang diperlukan
-	for k, v := range resp.Header {
-		if k == "Content-Type" || k == "Content-Length" {
-			c.Writer.Header()[k] = v
+	c.Header("X-Cache", series.CacheStatus)
+	if series.Stale {
+		c.Header("Warning", `110 - "NAV provider unavailable, serving stored data"`)
+	}
+
+	navs := make([]NavPointResponse, 0, len(series.Navs))
+	for _, nav := range series.Navs {
+		navs = append(navs, NavPointResponse{Date: nav.Date.Format("2006-01-02"), Nav: nav.Nav})
+	}
+
+	if format == "csv" {
+		filename := fmt.Sprintf("nav-%d-%s-%s.csv", fund.ID, start.Format("20060102"), end.Format("20060102"))
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"date", "nav"})
+		for _, nav := range navs {
+			w.Write([]string{nav.Date, strconv.FormatFloat(nav.Nav, 'f', -1, 64)})
 		}
+		w.Flush()
+		return
 	}
 
-	// Kirim response ke client
-	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), body)
-}
\ No newline at end of file
+	c.JSON(http.StatusOK, NavResponse{
+		MutualFundID: fund.ID,
+		Name:         fund.Name,
+		Period:       period,
+		StartDate:    start.Format("2006-01-02"),
+		EndDate:      end.Format("2006-01-02"),
+		Stale:        series.Stale,
+		Navs:         navs,
+	})
+}
+
+// upstreamErrorStatus memetakan error dari utils.UpstreamClient ke status HTTP
+func upstreamErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrCircuitOpen), errors.Is(err, utils.ErrUpstreamUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, utils.ErrUpstreamMalformed), errors.Is(err, utils.ErrUpstreamRejected):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
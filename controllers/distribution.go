@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"errors"
+	"golang/models"
+	"golang/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DistributionController struct {
+	DB *gorm.DB
+}
+
+func NewDistributionController(db *gorm.DB) *DistributionController {
+	return &DistributionController{DB: db}
+}
+
+// distributionInput adalah body POST /admin/mutual-funds/:id/distributions
+type distributionInput struct {
+	ExDate        string  `json:"ex_date" binding:"required"`
+	PayDate       string  `json:"pay_date"`
+	AmountPerUnit float64 `json:"amount_per_unit" binding:"required,gt=0"`
+	PayoutType    string  `json:"payout_type"`
+}
+
+// GetDistributions menampilkan riwayat dividen sebuah reksa dana
+func (dc *DistributionController) GetDistributions(c *gin.Context) {
+	fund, ok := findMutualFund(dc.DB, c)
+	if !ok {
+		return
+	}
+
+	dists, err := utils.FundDistributions(dc.DB, fund.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch distributions"})
+		return
+	}
+	c.JSON(http.StatusOK, dists)
+}
+
+// CreateDistribution menyimpan dividen sebuah reksa dana (ex-date yang sama diganti), lalu
+// membuat atau memperbarui DIVIDEND di ledger semua pemegangnya
+func (dc *DistributionController) CreateDistribution(c *gin.Context) {
+	fund, ok := findMutualFund(dc.DB, c)
+	if !ok {
+		return
+	}
+
+	var input distributionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	dist := models.FundDistribution{
+		MutualFundID:  fund.ID,
+		AmountPerUnit: input.AmountPerUnit,
+		PayoutType:    strings.ToUpper(input.PayoutType),
+		Source:        models.DistributionSourceAdmin,
+	}
+	if dist.PayoutType == "" {
+		dist.PayoutType = models.DistributionPayoutCash
+	}
+	if !models.ValidPayoutType(dist.PayoutType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payout_type must be CASH or REINVEST"})
+		return
+	}
+	exDate, err := time.Parse("2006-01-02", input.ExDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ex_date must be YYYY-MM-DD"})
+		return
+	}
+	dist.ExDate = exDate
+	if input.PayDate != "" {
+		payDate, err := time.Parse("2006-01-02", input.PayDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pay_date must be YYYY-MM-DD"})
+			return
+		}
+		if payDate.Before(exDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pay_date must not be before ex_date"})
+			return
+		}
+		dist.PayDate = &payDate
+	}
+
+	if err := utils.SaveFundDistribution(dc.DB, &dist); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save distribution"})
+		return
+	}
+	c.JSON(http.StatusCreated, dist)
+}
+
+// SyncDistributions mengambil dividen dari provider NAV reksa dana untuk rentang start_date sampai
+// end_date (default seluruh riwayat sampai hari ini). Dividen yang diinput admin tidak ditimpa.
+func (dc *DistributionController) SyncDistributions(c *gin.Context) {
+	fund, ok := findMutualFund(dc.DB, c)
+	if !ok {
+		return
+	}
+	start, end, ok := parseReturnRange(c)
+	if !ok {
+		return
+	}
+
+	dists, err := utils.SyncFundDistributions(dc.DB, fund, start, end)
+	if err != nil {
+		if errors.Is(err, utils.ErrNoDistributionProvider) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "NAV provider of this mutual fund has no distribution data"})
+			return
+		}
+		c.JSON(upstreamErrorStatus(err), gin.H{"error": "Failed to sync distributions", "detail": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"synced": len(dists), "distributions": dists})
+}
+
+// DeleteDistribution menghapus dividen beserta DIVIDEND yang dibuat darinya di ledger pemegangnya
+func (dc *DistributionController) DeleteDistribution(c *gin.Context) {
+	var dist models.FundDistribution
+	if err := dc.DB.First(&dist, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Distribution not found"})
+		return
+	}
+
+	if err := utils.DeleteFundDistribution(dc.DB, dist); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete distribution"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// findMutualFund membaca reksa dana dari path parameter id; jika gagal, error sudah ditulis ke response
+func findMutualFund(db *gorm.DB, c *gin.Context) (models.MutualFund, bool) {
+	var fund models.MutualFund
+	fundID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mutual fund ID"})
+		return fund, false
+	}
+	if err := db.First(&fund, fundID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mutual fund not found"})
+		return fund, false
+	}
+	return fund, true
+}
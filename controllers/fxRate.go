@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"golang/models"
+	"golang/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type FxRateController struct {
+	DB *gorm.DB
+}
+
+func NewFxRateController(db *gorm.DB) *FxRateController {
+	return &FxRateController{DB: db}
+}
+
+// fxRateInput adalah body POST /admin/fx-rates; Rate adalah rupiah per 1 unit Currency
+type fxRateInput struct {
+	Currency string  `json:"currency" binding:"required"`
+	Date     string  `json:"date" binding:"required"`
+	Rate     float64 `json:"rate" binding:"required,gt=0"`
+}
+
+// GetFxRates menampilkan kurs tersimpan sebuah mata uang (query parameter currency) untuk rentang
+// start_date sampai end_date
+func (fc *FxRateController) GetFxRates(c *gin.Context) {
+	currency, ok := fxCurrencyParam(c)
+	if !ok {
+		return
+	}
+	start, end, ok := parseReturnRange(c)
+	if !ok {
+		return
+	}
+
+	var rates []models.FxRate
+	if err := fc.DB.Where("currency = ? AND date BETWEEN ? AND ?", currency, start, end).
+		Order("date ASC").Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rates"})
+		return
+	}
+	c.JSON(http.StatusOK, rates)
+}
+
+// CreateFxRate menyimpan kurs manual (kurs tanggal yang sama diganti)
+func (fc *FxRateController) CreateFxRate(c *gin.Context) {
+	var input fxRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	currency, err := utils.NormalizeCurrency(input.Currency)
+	if err != nil || currency == models.DefaultCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be a 3-letter currency code other than IDR"})
+		return
+	}
+	date, err := time.Parse("2006-01-02", input.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+
+	rate := models.FxRate{Currency: currency, Date: date, Rate: input.Rate, Source: "admin", FetchedAt: time.Now()}
+	if err := utils.SaveFxRates(fc.DB, []models.FxRate{rate}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save FX rate"})
+		return
+	}
+	c.JSON(http.StatusCreated, rate)
+}
+
+// SyncFxRates mengambil kurs sebuah mata uang dari provider kurs untuk rentang start_date sampai end_date
+func (fc *FxRateController) SyncFxRates(c *gin.Context) {
+	currency, ok := fxCurrencyParam(c)
+	if !ok {
+		return
+	}
+	start, end, ok := parseReturnRange(c)
+	if !ok {
+		return
+	}
+	if start.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date is required"})
+		return
+	}
+
+	rates, err := utils.SyncFxRates(fc.DB, currency, start, end)
+	if err != nil {
+		if errors.Is(err, utils.ErrFxRateNotAvailable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No FX provider configured"})
+			return
+		}
+		c.JSON(upstreamErrorStatus(err), gin.H{"error": "Failed to sync FX rates", "detail": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"synced": len(rates), "rates": rates})
+}
+
+// fxCurrencyParam membaca query parameter currency (wajib, bukan IDR karena kurs disimpan terhadap rupiah)
+func fxCurrencyParam(c *gin.Context) (string, bool) {
+	currency, err := utils.NormalizeCurrency(c.Query("currency"))
+	if err != nil || currency == models.DefaultCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be a 3-letter currency code other than IDR"})
+		return "", false
+	}
+	return currency, true
+}
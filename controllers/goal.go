@@ -0,0 +1,304 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"golang/models"
+	"golang/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultGoalSimulations = 1000
+	maxGoalSimulations     = 10000
+	// defaultGoalLookbackYears adalah panjang riwayat NAV untuk menghitung return dan volatilitas
+	defaultGoalLookbackYears = 5
+)
+
+type GoalController struct {
+	DB *gorm.DB
+}
+
+func NewGoalController(db *gorm.DB) *GoalController {
+	return &GoalController{DB: db}
+}
+
+// goalInput adalah body POST/PUT /goals. Currency opsional; default base_currency portfolio default user.
+type goalInput struct {
+	Name         string    `json:"name" binding:"required"`
+	TargetAmount float64   `json:"target_amount" binding:"required"`
+	Currency     string    `json:"currency"`
+	TargetDate   time.Time `json:"target_date" binding:"required"`
+	Holdings     []struct {
+		MutualFundID        uint    `json:"mutual_fund_id" binding:"required"`
+		MonthlyContribution float64 `json:"monthly_contribution"`
+	} `json:"holdings" binding:"required"`
+}
+
+func (gc *GoalController) GetGoals(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	var goals []models.Goal
+	if err := gc.DB.Preload("Holdings").Where("user_id = ?", userID).Order("target_date ASC, id ASC").Find(&goals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goals"})
+		return
+	}
+	c.JSON(http.StatusOK, goals)
+}
+
+func (gc *GoalController) GetGoalByID(c *gin.Context) {
+	goal, ok := gc.findGoal(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, goal)
+}
+
+func (gc *GoalController) CreateGoal(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	goal := models.Goal{UserID: userID.(uint)}
+	if !gc.bindGoal(c, &goal) {
+		return
+	}
+
+	if err := gc.DB.Create(&goal).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create goal"})
+		return
+	}
+	c.JSON(http.StatusCreated, goal)
+}
+
+// UpdateGoal mengganti data goal beserta seluruh daftar kepemilikannya
+func (gc *GoalController) UpdateGoal(c *gin.Context) {
+	goal, ok := gc.findGoal(c)
+	if !ok {
+		return
+	}
+	if !gc.bindGoal(c, &goal) {
+		return
+	}
+
+	err := gc.DB.Transaction(func(dbtx *gorm.DB) error {
+		if err := dbtx.Where("goal_id = ?", goal.ID).Delete(&models.GoalHolding{}).Error; err != nil {
+			return err
+		}
+		for i := range goal.Holdings {
+			goal.Holdings[i].GoalID = goal.ID
+		}
+		if err := dbtx.Create(&goal.Holdings).Error; err != nil {
+			return err
+		}
+		return dbtx.Model(&goal).Select("name", "target_amount", "currency", "target_date").Updates(&goal).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update goal"})
+		return
+	}
+	c.JSON(http.StatusOK, goal)
+}
+
+func (gc *GoalController) DeleteGoal(c *gin.Context) {
+	goal, ok := gc.findGoal(c)
+	if !ok {
+		return
+	}
+
+	err := gc.DB.Transaction(func(dbtx *gorm.DB) error {
+		if err := dbtx.Where("goal_id = ?", goal.ID).Delete(&models.GoalHolding{}).Error; err != nil {
+			return err
+		}
+		return dbtx.Delete(&goal).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete goal"})
+		return
+	}
+	c.JSON(204, nil)
+}
+
+// GetProjection menangani GET /goals/:id/projection. Query parameter opsional:
+//   - simulations: jumlah simulasi (default 1000, maksimal 10000)
+//   - seed: seed RNG; default ID goal sehingga hasil sama untuk data yang sama
+//   - lookback_years: panjang riwayat NAV untuk statistik return (default 5)
+func (gc *GoalController) GetProjection(c *gin.Context) {
+	goal, ok := gc.findGoal(c)
+	if !ok {
+		return
+	}
+
+	simulations, err := strconv.Atoi(c.DefaultQuery("simulations", strconv.Itoa(defaultGoalSimulations)))
+	if err != nil || simulations < 1 || simulations > maxGoalSimulations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "simulations must be between 1 and 10000"})
+		return
+	}
+	seed := int64(goal.ID)
+	if v := c.Query("seed"); v != "" {
+		seed, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "seed must be an integer"})
+			return
+		}
+	}
+	lookback, err := strconv.Atoi(c.DefaultQuery("lookback_years", strconv.Itoa(defaultGoalLookbackYears)))
+	if err != nil || lookback < 1 || lookback > 30 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lookback_years must be between 1 and 30"})
+		return
+	}
+
+	now := time.Now()
+	if !goal.TargetDate.After(utils.DateOnly(now)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Goal target date has passed"})
+		return
+	}
+	if goal.TargetDate.After(now.AddDate(utils.MaxProjectionYears, 0, 0)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Goal target date must be within %d years to project", utils.MaxProjectionYears)})
+		return
+	}
+
+	// Nilai dan setoran setiap kepemilikan dalam mata uang reksa dananya, jadi diubah ke mata uang goal
+	currencies := make([]string, len(goal.Holdings))
+	for i, h := range goal.Holdings {
+		if currencies[i], err = utils.FundCurrency(gc.DB, h.MutualFundID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load mutual fund"})
+			return
+		}
+	}
+	fx, err := utils.LoadFxConverter(gc.DB, append(currencies, goal.Currency), now, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load FX rates"})
+		return
+	}
+
+	assets := make([]utils.SimulationAsset, 0, len(goal.Holdings))
+	for i, h := range goal.Holdings {
+		rate, err := fx.Rate(currencies[i], goal.Currency, now)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "FX rate is not available", "details": err.Error()})
+			return
+		}
+		asset := utils.SimulationAsset{MutualFundID: h.MutualFundID, MonthlyContribution: h.MonthlyContribution * rate}
+
+		txs, err := utils.LoadLedger(gc.DB, goal.UserID, 0, h.MutualFundID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+			return
+		}
+		latest, err := utils.LatestStoredNav(gc.DB, h.MutualFundID)
+		if err != nil && !errors.Is(err, utils.ErrNavNotAvailable) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NAV data"})
+			return
+		}
+		asset.CurrentValue = utils.BuildHolding(h.MutualFundID, txs, latest).CurrentValue * rate
+
+		asset.Stats, err = utils.FundReturnStats(gc.DB, h.MutualFundID, now.AddDate(-lookback, 0, 0))
+		if errors.Is(err, utils.ErrNotEnoughHistory) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Not enough NAV history to project this goal", "mutual_fund_id": h.MutualFundID})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute fund statistics"})
+			return
+		}
+		assets = append(assets, asset)
+	}
+
+	projection := utils.SimulateGoal(assets, goal.TargetAmount, now, goal.TargetDate, simulations, seed)
+	c.JSON(http.StatusOK, gin.H{
+		"goal":       goal,
+		"assets":     assets,
+		"projection": projection,
+	})
+}
+
+// bindGoal membaca dan memvalidasi body goal ke dalam goal; response error sudah dikirim jika gagal
+func (gc *GoalController) bindGoal(c *gin.Context, goal *models.Goal) bool {
+	var input goalInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return false
+	}
+	if input.TargetAmount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target_amount must be positive"})
+		return false
+	}
+	if len(input.Holdings) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one holding is required"})
+		return false
+	}
+
+	seen := make(map[uint]bool)
+	holdings := make([]models.GoalHolding, 0, len(input.Holdings))
+	for _, h := range input.Holdings {
+		if h.MonthlyContribution < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "monthly_contribution must not be negative"})
+			return false
+		}
+		if seen[h.MutualFundID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate mutual fund in holdings", "mutual_fund_id": h.MutualFundID})
+			return false
+		}
+		seen[h.MutualFundID] = true
+
+		var fund models.MutualFund
+		if err := gc.DB.First(&fund, h.MutualFundID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Mutual fund not found", "mutual_fund_id": h.MutualFundID})
+			return false
+		}
+		holdings = append(holdings, models.GoalHolding{GoalID: goal.ID, MutualFundID: h.MutualFundID, MonthlyContribution: h.MonthlyContribution})
+	}
+
+	switch {
+	case input.Currency != "":
+		currency, err := utils.NormalizeCurrency(input.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be a 3-letter currency code"})
+			return false
+		}
+		goal.Currency = currency
+	case goal.Currency == "":
+		portfolio, err := utils.DefaultPortfolio(gc.DB, goal.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load portfolio"})
+			return false
+		}
+		goal.Currency = portfolio.BaseCurrency
+		if goal.Currency == "" {
+			goal.Currency = models.DefaultCurrency
+		}
+	}
+
+	goal.Name = input.Name
+	goal.TargetAmount = input.TargetAmount
+	goal.TargetDate = utils.DateOnly(input.TargetDate)
+	goal.Holdings = holdings
+	return true
+}
+
+// findGoal mengambil goal :id milik user beserta kepemilikannya; response error sudah dikirim jika gagal
+func (gc *GoalController) findGoal(c *gin.Context) (models.Goal, bool) {
+	var goal models.Goal
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return goal, false
+	}
+	if err := gc.DB.Preload("Holdings").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return goal, false
+	}
+	return goal, true
+}
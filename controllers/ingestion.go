@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"errors"
+	"golang/jobs"
+	"golang/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type IngestionController struct {
+	DB *gorm.DB
+}
+
+func NewIngestionController(db *gorm.DB) *IngestionController {
+	return &IngestionController{DB: db}
+}
+
+// TriggerRun menjalankan ingestion NAV di background dan langsung mengembalikan run yang dibuat
+func (ic *IngestionController) TriggerRun(c *gin.Context) {
+	run, err := jobs.StartNavIngestion(ic.DB, jobs.TriggerManual, jobs.DefaultNavIngestionOptions())
+	if err != nil {
+		if errors.Is(err, jobs.ErrIngestionRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Ingestion is already running"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start ingestion", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+func (ic *IngestionController) GetRuns(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "30"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 30
+	}
+
+	var runs []models.IngestionRun
+	if err := ic.DB.Order("started_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ingestion runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
+func (ic *IngestionController) GetRunByID(c *gin.Context) {
+	id := c.Param("id")
+
+	var run models.IngestionRun
+	if err := ic.DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("mutual_fund_id ASC")
+	}).First(&run, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ingestion run not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"golang/models"
+	"golang/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MarketHolidayController struct {
+	DB *gorm.DB
+}
+
+func NewMarketHolidayController(db *gorm.DB) *MarketHolidayController {
+	return &MarketHolidayController{DB: db}
+}
+
+// marketHolidayInput adalah body POST /admin/market-holidays
+type marketHolidayInput struct {
+	Date string `json:"date" binding:"required"`
+	Name string `json:"name" binding:"required"`
+}
+
+// GetHolidays menampilkan hari libur bursa mulai query start_date (default hari ini)
+func (hc *MarketHolidayController) GetHolidays(c *gin.Context) {
+	start := utils.DateOnly(time.Now())
+	if v := c.Query("start_date"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must be YYYY-MM-DD"})
+			return
+		}
+		start = parsed
+	}
+
+	var holidays []models.MarketHoliday
+	if err := hc.DB.Where("date >= ?", start).Order("date ASC").Find(&holidays).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch market holidays"})
+		return
+	}
+	c.JSON(http.StatusOK, holidays)
+}
+
+// CreateHoliday menyimpan hari libur bursa (nama hari libur pada tanggal yang sama diganti)
+func (hc *MarketHolidayController) CreateHoliday(c *gin.Context) {
+	var input marketHolidayInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	date, err := time.Parse("2006-01-02", input.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+
+	holiday := models.MarketHoliday{Date: date, Name: input.Name}
+	if err := hc.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name"}),
+	}).Create(&holiday).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save market holiday"})
+		return
+	}
+	if err := hc.DB.Where("date = ?", date).First(&holiday).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save market holiday"})
+		return
+	}
+	c.JSON(http.StatusCreated, holiday)
+}
+
+// DeleteHoliday menghapus hari libur bursa :id
+func (hc *MarketHolidayController) DeleteHoliday(c *gin.Context) {
+	result := hc.DB.Delete(&models.MarketHoliday{}, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete market holiday"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Market holiday not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Market holiday deleted"})
+}
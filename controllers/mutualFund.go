@@ -1,9 +1,13 @@
 package controllers
 
 import (
+	"errors"
 	golang "golang/models"
+	"golang/utils"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -17,9 +21,59 @@ func NewMutualFundController(db *gorm.DB) *MutualFundController {
 	return &MutualFundController{DB: db}
 }
 
+// fundSortColumns adalah nilai sort yang diterima GET /mutual-funds
+var fundSortColumns = map[string]string{
+	"name":               "name",
+	"minimum_investment": "minimum_investment_idr",
+	"management_fee":     "management_fee_rate",
+	"custodian_fee":      "custodian_fee_rate",
+	"switching_fee":      "switching_fee_rate",
+}
+
+// GetAll menampilkan semua reksa dana. Query parameter opsional:
+//   - max_minimum_investment: batas minimum investasi dalam rupiah
+//   - max_management_fee, max_custodian_fee, max_switching_fee: batas biaya dalam desimal (0.01 = 1%)
+//   - sort: name, minimum_investment, management_fee, custodian_fee, switching_fee; awali dengan "-" untuk menurun
 func (mfc *MutualFundController) GetAll(c *gin.Context) {
+	query := mfc.DB
+	if v := c.Query("max_minimum_investment"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_minimum_investment must be an integer"})
+			return
+		}
+		query = query.Where("minimum_investment_idr <= ?", limit)
+	}
+	for _, param := range []string{"management_fee", "custodian_fee", "switching_fee"} {
+		v := c.Query("max_" + param)
+		if v == "" {
+			continue
+		}
+		limit, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_" + param + " must be a decimal number"})
+			return
+		}
+		query = query.Where(param+"_rate <= ?", limit)
+	}
+
+	order := "id ASC"
+	if sort := c.Query("sort"); sort != "" {
+		direction := "ASC"
+		if strings.HasPrefix(sort, "-") {
+			direction = "DESC"
+			sort = sort[1:]
+		}
+		column, ok := fundSortColumns[sort]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort column"})
+			return
+		}
+		order = column + " " + direction + " NULLS LAST, id ASC"
+	}
+
 	var funds []golang.MutualFund
-	if err := mfc.DB.Find(&funds).Error; err != nil {
+	if err := query.Order(order).Find(&funds).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mutual funds"})
 		return
 	}
@@ -45,6 +99,11 @@ func (mfc *MutualFundController) Create(c *gin.Context) {
 		ManagementFee string `json:"management_fee"`
 		CustodianFee  string `json:"custodian_fee"`
 		SwitchingFee  string `json:"switching_fee"`
+		NavProvider   string `json:"nav_provider"`
+		ExternalID    string `json:"external_id"`
+		InceptionDate string `json:"inception_date"`
+		Category      string `json:"category"`
+		Currency      string `json:"currency"`
 		Im            struct {
 			Name string `json:"name"`
 		} `json:"im"`
@@ -62,14 +121,71 @@ func (mfc *MutualFundController) Create(c *gin.Context) {
 
 	// Proses setiap item dalam array
 	for _, input := range inputs {
-		// Konversi PID dari string ke uint
-		pid, err := strconv.ParseUint(input.PID, 10, 32)
+		// Provider NAV default-nya Bareksa
+		providerName := input.NavProvider
+		if providerName == "" {
+			providerName = golang.NavProviderBareksa
+		}
+		if _, err := utils.GetNavProvider(providerName); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  "Invalid NAV provider",
+				"fund":   input.Name,
+				"detail": err.Error(),
+			})
+			return
+		}
+
+		// Konversi PID dari string ke uint (PID hanya ada untuk reksa dana Bareksa)
+		var pid uint64
+		if input.PID != "" {
+			var err error
+			pid, err = strconv.ParseUint(input.PID, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":  "Invalid PID format",
+					"pid":    input.PID,
+					"fund":   input.Name,
+					"detail": "PID must be a numeric string",
+				})
+				return
+			}
+		}
+
+		externalID := input.ExternalID
+		if externalID == "" && providerName == golang.NavProviderBareksa {
+			externalID = input.PID
+		}
+		if externalID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  "Missing external ID",
+				"fund":   input.Name,
+				"detail": "external_id (or pid for Bareksa funds) is required",
+			})
+			return
+		}
+
+		// Tanggal peluncuran opsional, dipakai sebagai awal backfill NAV
+		var inceptionDate *time.Time
+		if input.InceptionDate != "" {
+			parsed, err := time.Parse("2006-01-02", input.InceptionDate)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":  "Invalid inception_date format",
+					"fund":   input.Name,
+					"detail": "inception_date must be YYYY-MM-DD",
+				})
+				return
+			}
+			inceptionDate = &parsed
+		}
+
+		// Mata uang NAV, default rupiah
+		currency, err := utils.NormalizeCurrency(input.Currency)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
-				"error":  "Invalid PID format",
-				"pid":    input.PID,
+				"error":  "Invalid currency",
 				"fund":   input.Name,
-				"detail": "PID must be a numeric string",
+				"detail": "currency must be a 3-letter currency code",
 			})
 			return
 		}
@@ -83,6 +199,27 @@ func (mfc *MutualFundController) Create(c *gin.Context) {
 			ConsodiantFee:        input.CustodianFee,
 			SwitchingFee:         input.SwitchingFee,
 			InvestmentManagement: input.Im.Name,
+			Category:             strings.TrimSpace(input.Category),
+			NavProvider:          providerName,
+			ExternalID:           externalID,
+			InceptionDate:        inceptionDate,
+			Currency:             currency,
+		}
+
+		// Biaya dan minimum investasi harus bisa dibaca agar bisa dipakai untuk perhitungan
+		if err := utils.ApplyFundFees(&fund); err != nil {
+			var parseErr *utils.FeeParseError
+			field := ""
+			if errors.As(err, &parseErr) {
+				field = parseErr.Field
+			}
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  "Invalid fee format",
+				"fund":   input.Name,
+				"field":  field,
+				"detail": err.Error(),
+			})
+			return
 		}
 
 		funds = append(funds, fund)
@@ -102,4 +239,35 @@ func (mfc *MutualFundController) Create(c *gin.Context) {
 		"count":   len(funds),
 		"funds":   funds,
 	})
-}
\ No newline at end of file
+}
+
+// fundCurrencyInput adalah body PUT /admin/mutual-funds/:id/currency
+type fundCurrencyInput struct {
+	Currency string `json:"currency" binding:"required"`
+}
+
+// SetCurrency mengoreksi mata uang reksa dana; transaksi dan my_portfolios reksa dana itu ikut ditandai ulang
+func (mfc *MutualFundController) SetCurrency(c *gin.Context) {
+	fund, ok := findMutualFund(mfc.DB, c)
+	if !ok {
+		return
+	}
+
+	var input fundCurrencyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	currency, err := utils.NormalizeCurrency(input.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be a 3-letter currency code"})
+		return
+	}
+
+	change, err := utils.SetFundCurrency(mfc.DB, fund.ID, currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update mutual fund currency"})
+		return
+	}
+	c.JSON(http.StatusOK, change)
+}
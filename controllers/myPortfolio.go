@@ -1,7 +1,11 @@
 package controllers
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"golang/models"
 	"golang/utils"
 	"log"
 	"net/http"
@@ -12,17 +16,8 @@ import (
 	"gorm.io/gorm"
 )
 
-type MyPortfolio struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	MutualFundID uint      `gorm:"not null" json:"mutual_fund_id"`
-	Date       time.Time `gorm:"not null" json:"date"`
-	Value      float64   `gorm:"not null" json:"value"`
-	UserID     uint      `gorm:"not null" json:"user_id"`
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt  *time.Time `gorm:"index" json:"deleted_at,omitempty"`
-}
-
+// MyPortfolioController menyajikan endpoint /portfolio sebagai tampilan di atas ledger transaksi.
+// Setiap "portfolio entry" adalah satu baris tabel transactions.
 type MyPortfolioController struct {
 	DB *gorm.DB
 }
@@ -31,40 +26,64 @@ func NewMyPortfolioController(db *gorm.DB) *MyPortfolioController {
 	return &MyPortfolioController{DB: db}
 }
 
+// portfolioInput adalah body POST/PUT /portfolio, yaitu pembelian (BUY) sebesar value pada tanggal date
+type portfolioInput struct {
+	ID           uint      `json:"id"`
+	PortfolioID  uint      `json:"portfolio_id"`
+	MutualFundID uint      `json:"mutual_fund_id"`
+	Date         time.Time `json:"date"`
+	Value        float64   `json:"value"`
+	// Pointer supaya update bisa membedakan fee yang tidak dikirim dari fee 0
+	Fee *float64 `json:"fee"`
+}
+
+// GetPortfolio menampilkan semua transaksi user, atau transaksi satu portfolio jika query
+// parameter portfolio_id diisi
 func (mpc *MyPortfolioController) GetPortfolio(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
 		c.JSON(400, gin.H{"error": "User ID not found"})
 		return
 	}
+	portfolioID, ok := portfolioScope(mpc.DB, c, userID.(uint))
+	if !ok {
+		return
+	}
 
 	var results []map[string]interface{}
 
 	query := `
-	SELECT 
-		p.id, 
-		p.mutual_fund_id, 
-		p.date, 
-		p.value, 
-		p.user_id, 
-		p.created_at, 
+	SELECT
+		p.id,
+		p.portfolio_id,
+		p.mutual_fund_id,
+		p.type,
+		p.date,
+		p.amount AS value,
+		p.nav,
+		p.units,
+		p.fee,
+		p.linked_transaction_id,
+		p.user_id,
+		p.created_at,
 		p.updated_at,
 		json_build_object(
 			'id', m.id,
 			'name', m.name,
 			'pid', m.p_id
 		)::text AS mutual_fund
-	FROM 
-		my_portfolios p
-	JOIN 
+	FROM
+		transactions p
+	JOIN
 		mutual_funds m ON p.mutual_fund_id = m.id
-	WHERE 
+	WHERE
 		p.user_id = ? AND p.deleted_at IS NULL
-	ORDER BY 
-		p.date DESC
+		AND (? = 0 OR p.portfolio_id = ?)
+	ORDER BY
+		p.date DESC, p.id DESC
 	`
 
-	rows, err := mpc.DB.Raw(query, userID).Rows()
+	rows, err := mpc.DB.Raw(query, userID, portfolioID, portfolioID).Rows()
 	if err != nil {
 		log.Printf("Error querying portfolio: %v", err)
 		c.JSON(500, gin.H{"error": "Failed to fetch portfolio"})
@@ -94,35 +113,61 @@ func (mpc *MyPortfolioController) GetPortfolio(c *gin.Context) {
 }
 
 func (mpc *MyPortfolioController) CreatePortfolio(c *gin.Context) {
-	var newPortfolio MyPortfolio
-	if err := c.ShouldBindJSON(&newPortfolio); err != nil {
-    c.JSON(400, gin.H{
-        "error":   "Invalid input",
-        "details": err.Error(), // tampilkan penyebabnya
-    })
-    return
-}
+	var input portfolioInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(400, gin.H{
+			"error":   "Invalid input",
+			"details": err.Error(), // tampilkan penyebabnya
+		})
+		return
+	}
+	var fee float64
+	if input.Fee != nil {
+		fee = *input.Fee
+	}
+	if input.MutualFundID == 0 || input.Date.IsZero() || input.Value <= 0 || fee < 0 || fee >= input.Value {
+		c.JSON(400, gin.H{
+			"error":   "Invalid input",
+			"details": "mutual_fund_id, date and a positive value are required",
+		})
+		return
+	}
 
 	// Ambil ID user dari context
 	userID, exists := c.Get("userID")
-	log.Printf("Creating portfolio for mutual fund ID: %d", userID)
 	if !exists {
 		c.JSON(400, gin.H{"error": "User ID not found"})
 		return
 	}
-	newPortfolio.UserID = userID.(uint)
+	portfolioID, ok := targetPortfolio(mpc.DB, c, userID.(uint), input.PortfolioID)
+	if !ok {
+		return
+	}
+	log.Printf("Creating portfolio for mutual fund ID: %d", input.MutualFundID)
+
+	tx := models.Transaction{
+		UserID:       userID.(uint),
+		PortfolioID:  portfolioID,
+		MutualFundID: input.MutualFundID,
+		Type:         models.TransactionBuy,
+		Date:         utils.DateOnly(input.Date),
+		Amount:       input.Value,
+		Fee:          fee,
+	}
 
-	if err := mpc.DB.Create(&newPortfolio).Error; err != nil {
+	// Hitung unit dari NAV tanggal pembelian; jika NAV belum terbit unit dihitung belakangan
+	if err := utils.RecordTransaction(mpc.DB, &tx); err != nil {
+		log.Printf("Failed to create portfolio: %v", err)
 		c.JSON(500, gin.H{"error": "Failed to create portfolio"})
 		return
 	}
 
-	c.JSON(201, newPortfolio)
-}	
+	c.JSON(201, tx)
+}
 
 func (mpc *MyPortfolioController) UpdatePortfolio(c *gin.Context) {
-	var updatedPortfolio MyPortfolio
-	if err := c.ShouldBindJSON(&updatedPortfolio); err != nil {
+	var input portfolioInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(400, gin.H{"error": "Invalid input"})
 		return
 	}
@@ -133,345 +178,884 @@ func (mpc *MyPortfolioController) UpdatePortfolio(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "User ID not found"})
 		return
 	}
-	updatedPortfolio.UserID = userID.(uint)
 
-	if err := mpc.DB.Model(&MyPortfolio{}).Where("id = ? AND user_id = ? AND deleted_at IS NULL", updatedPortfolio.ID, updatedPortfolio.UserID).Updates(updatedPortfolio).Error; err != nil {
+	// Baris yang diubah selalu dari URL; id di body hanya boleh sama dengan URL
+	id := c.Param("id")
+	if input.ID != 0 && strconv.FormatUint(uint64(input.ID), 10) != id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id in body does not match the URL"})
+		return
+	}
+
+	var tx models.Transaction
+	if err := mpc.DB.Where("id = ? AND user_id = ? AND deleted_at IS NULL", id, userID).First(&tx).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+	if tx.DistributionID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Dividend entries follow the fund distribution and cannot be changed"})
+		return
+	}
+
+	// Kedua kaki switching harus tetap cocok, jadi switching hanya bisa dihapus lalu dicatat ulang
+	if tx.LinkedTransactionID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Switch legs cannot be changed individually; delete the switch and record it again"})
+		return
+	}
+
+	previous := tx
+	if input.PortfolioID != 0 {
+		portfolioID, ok := targetPortfolio(mpc.DB, c, tx.UserID, input.PortfolioID)
+		if !ok {
+			return
+		}
+		tx.PortfolioID = portfolioID
+	}
+	if input.MutualFundID != 0 && input.MutualFundID != tx.MutualFundID {
+		// Nilai transaksi selalu dalam mata uang reksa dananya
+		currency, err := utils.FundCurrency(mpc.DB, input.MutualFundID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Mutual fund not found"})
+			return
+		}
+		tx.MutualFundID, tx.Currency = input.MutualFundID, currency
+	}
+	if !input.Date.IsZero() {
+		tx.Date = utils.DateOnly(input.Date)
+	}
+	if input.Value != 0 && input.Value != tx.Amount {
+		tx.Amount = input.Value
+		if tx.Type == models.TransactionSell || tx.Type == models.TransactionSwitchOut {
+			tx.Units = 0
+		}
+	}
+	if input.Fee != nil {
+		tx.Fee = *input.Fee
+	}
+	// Penjualan pending yang dicatat dengan unit belum punya nilai, jadi fee hanya dibandingkan dengan nilai yang ada
+	if tx.Fee < 0 || ((tx.Type == models.TransactionBuy || tx.Amount > 0) && tx.Fee >= tx.Amount) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fee must be at least 0 and below the value"})
+		return
+	}
+
+	moved := tx.MutualFundID != previous.MutualFundID || !tx.Date.Equal(previous.Date)
+	if tx.Type == models.TransactionDividend && moved {
+		if err := utils.CheckManualDividend(mpc.DB, tx); err != nil {
+			if errors.Is(err, utils.ErrDistributionRecorded) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Dividend is already recorded from the fund distribution", "details": err.Error()})
+				return
+			}
+			c.JSON(500, gin.H{"error": "Failed to update portfolio :("})
+			return
+		}
+	}
+
+	// NAV dan unit hanya dihitung ulang jika tanggal, reksa dana, atau nilai berubah, supaya NAV dan unit
+	// yang diisi manual atau dari file broker tetap. NAV dividen yang diinvestasikan ulang tidak dihitung ulang.
+	if (moved || tx.Amount != previous.Amount) && tx.Type != models.TransactionDividend {
+		tx.Nav = 0
+		if err := utils.ApplyTradeNav(mpc.DB, &tx); err != nil && !errors.Is(err, utils.ErrNavNotAvailable) {
+			log.Printf("Units for portfolio %d left pending: %v", tx.ID, err)
+		}
+	}
+
+	// Perubahan dibatalkan jika membuat penjualan di ledger lama atau baru melebihi unit yang dimiliki
+	err := mpc.DB.Transaction(func(dbtx *gorm.DB) error {
+		if err := dbtx.Model(&tx).Select("portfolio_id", "mutual_fund_id", "currency", "date", "amount", "fee", "nav", "units").Updates(&tx).Error; err != nil {
+			return err
+		}
+		if err := utils.CheckLedgerUnits(dbtx, tx.UserID, previous.PortfolioID, previous.MutualFundID); err != nil {
+			return err
+		}
+		return utils.CheckLedgerUnits(dbtx, tx.UserID, tx.PortfolioID, tx.MutualFundID)
+	})
+	if errors.Is(err, utils.ErrInsufficientUnits) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Change would sell more units than held", "details": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to update portfolio :("})
 		return
 	}
 
-	c.JSON(200, updatedPortfolio)
-}	
+	if previous.PortfolioID != tx.PortfolioID || previous.MutualFundID != tx.MutualFundID {
+		mpc.ledgerChanged(tx.UserID, previous.PortfolioID, previous.MutualFundID, previous.Date)
+		mpc.ledgerChanged(tx.UserID, tx.PortfolioID, tx.MutualFundID, tx.Date)
+	} else if previous.Date.Before(tx.Date) {
+		mpc.ledgerChanged(tx.UserID, tx.PortfolioID, tx.MutualFundID, previous.Date)
+	} else {
+		mpc.ledgerChanged(tx.UserID, tx.PortfolioID, tx.MutualFundID, tx.Date)
+	}
+
+	c.JSON(200, tx)
+}
 
 func (mpc *MyPortfolioController) DeletePortfolio(c *gin.Context) {
 	id := c.Param("id")
-	
+
 	// Ambil ID user dari context
 	userID, exists := c.Get("userID")
 	if !exists {
 		c.JSON(400, gin.H{"error": "User ID not found"})
 		return
 	}
-	
-	if err := mpc.DB.Model(&MyPortfolio{}).Where("id = ? AND user_id = ?", id, userID).Update("deleted_at", time.Now()).Error; err != nil {
+
+	var tx models.Transaction
+	if err := mpc.DB.Where("id = ? AND user_id = ? AND deleted_at IS NULL", id, userID).First(&tx).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+	if tx.DistributionID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Dividend entries follow the fund distribution and cannot be changed"})
+		return
+	}
+
+	// Switch dihapus kedua kakinya sekaligus supaya SWITCH_OUT/SWITCH_IN tidak tertinggal sendiri
+	var linked *models.Transaction
+	if tx.LinkedTransactionID != nil {
+		var leg models.Transaction
+		if err := mpc.DB.Where("id = ? AND user_id = ? AND deleted_at IS NULL", *tx.LinkedTransactionID, tx.UserID).First(&leg).Error; err == nil {
+			linked = &leg
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(500, gin.H{"error": "Failed to delete portfolio"})
+			return
+		}
+	}
+
+	err := mpc.DB.Transaction(func(dbtx *gorm.DB) error {
+		now := time.Now()
+		if err := dbtx.Model(&tx).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		if linked != nil {
+			if err := dbtx.Model(linked).Update("deleted_at", now).Error; err != nil {
+				return err
+			}
+			if err := utils.CheckLedgerUnits(dbtx, linked.UserID, linked.PortfolioID, linked.MutualFundID); err != nil {
+				return err
+			}
+		}
+		// Menghapus pembelian tidak boleh membuat penjualan setelahnya melebihi unit yang dimiliki
+		return utils.CheckLedgerUnits(dbtx, tx.UserID, tx.PortfolioID, tx.MutualFundID)
+	})
+	if errors.Is(err, utils.ErrInsufficientUnits) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Deleting this entry would leave later sales without units", "details": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to delete portfolio"})
 		return
 	}
 
+	mpc.ledgerChanged(tx.UserID, tx.PortfolioID, tx.MutualFundID, tx.Date)
+	if linked != nil {
+		mpc.ledgerChanged(linked.UserID, linked.PortfolioID, linked.MutualFundID, linked.Date)
+	}
+
 	c.JSON(204, nil)
 }
 
+// ledgerChanged menghitung ulang pencocokan lot dan nilai harian setelah ledger berubah; kegagalan
+// hanya dicatat karena perubahan ledger sudah tersimpan dan akan disinkronkan lagi pada perubahan berikutnya
+func (mpc *MyPortfolioController) ledgerChanged(userID, portfolioID, fundID uint, from time.Time) {
+	if err := utils.LedgerChanged(mpc.DB, userID, portfolioID, fundID, from); err != nil {
+		log.Printf("Failed to refresh ledger for user %d portfolio %d fund %d: %v", userID, portfolioID, fundID, err)
+	}
+}
+
+// GetPortfolioByID menampilkan perkembangan nilai satu pembelian (BUY) dari tanggal pembelian sampai hari ini.
+// Akumulasi keuntungan adalah total return: termasuk dividen yang dibagikan atas unit pembelian tersebut.
+// Nilai dikonversi ke mata uang laporan (query parameter currency, default base_currency portfolio).
 func (mpc *MyPortfolioController) GetPortfolioByID(c *gin.Context) {
 	id := c.Param("id")
 
+	// Ambil ID user dari context
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+
 	// Ambil data portfolio berdasarkan ID
-	var fundData MyPortfolio
-	if err := mpc.DB.First(&fundData, id).Error; err != nil {
+	var fundData models.Transaction
+	if err := mpc.DB.Where("user_id = ? AND deleted_at IS NULL", userID).First(&fundData, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
 		return
 	}
-	log.Printf("Found portfolio: %+v", fundData)
+	if fundData.Type != models.TransactionBuy && fundData.Type != models.TransactionSwitchIn {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only purchase entries have a NAV history"})
+		return
+	}
+
+	// Pembelian yang NAV-nya baru terbit dihitung unitnya sekarang
+	entries := []models.Transaction{fundData}
+	if utils.FillPendingTransactions(mpc.DB, entries) > 0 {
+		mpc.ledgerChanged(fundData.UserID, fundData.PortfolioID, fundData.MutualFundID, fundData.Date)
+	}
+	fundData = entries[0]
 
 	cperiod := "custom"
 	// Mulai dari hari sebelumnya (-1 hari) untuk mendapatkan nilai awal
 	startdate := fundData.Date.AddDate(0, 0, -1).Format("2006-01-02")
 	enddate := time.Now().Format("2006-01-02")
 
-	body, err := utils.GetMutualFundNav(mpc.DB, fundData.MutualFundID, cperiod, startdate, enddate)
+	series, err := utils.GetMutualFundNav(mpc.DB, fundData.MutualFundID, cperiod, startdate, enddate)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":  "Failed to fetch NAV data from Bareksa",
+		c.JSON(upstreamErrorStatus(err), gin.H{
+			"error":  "Failed to fetch NAV data",
 			"detail": err.Error(),
 		})
 		return
 	}
 
-	type NAV struct {
-		Date  string `json:"date"`
-		Value string `json:"value"`
+	c.Header("X-Cache", series.CacheStatus)
+	if series.Stale {
+		c.Header("Warning", `110 - "NAV provider unavailable, serving stored data"`)
 	}
 
-	type FundData struct {
-		PName string `json:"pname"`
-		Nav   []NAV  `json:"nav"`
+	if len(series.Navs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "NAV data not found"})
+		return
 	}
 
-	type ResponseData struct {
-		Datas []FundData `json:"datas"`
+	type NavResult struct {
+		Date                     string  `json:"date"`
+		Value                    float64 `json:"value"`
+		KenaikanHariIni          float64 `json:"kenaikan_hari_ini"`
+		PersenKenaikanHariIni    float64 `json:"persen_kenaikan_hari_ini"`
+		KeuntunganHariIni        float64 `json:"keuntungan_hari_ini"`
+		PersenKeuntunganHariIni  float64 `json:"persen_keuntungan_hari_ini"`
+		AkumulasiKeuntungan      float64 `json:"akumulasi_keuntungan"`
+		AkumulasiKeuntunganBruto float64 `json:"akumulasi_keuntungan_bruto"`
+		AkumulasiDividen         float64 `json:"akumulasi_dividen"`
+		KeuntunganKurs           float64 `json:"keuntungan_kurs"`
+		TotalBiaya               float64 `json:"total_biaya"`
+		TotalBalance             float64 `json:"total_balance"`
 	}
 
-	type BareksaResponse struct {
-		Status bool         `json:"status"`
-		Data   ResponseData `json:"data"`
+	var fund models.MutualFund
+	if err := mpc.DB.First(&fund, fundData.MutualFundID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mutual fund not found"})
+		return
 	}
 
-	var response BareksaResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":  "Failed to parse NAV data",
-			"detail": err.Error(),
+	// Dividen yang dibagikan atas unit pembelian ini dihitung sebagai pendapatan (tunai atau unit baru),
+	// sehingga penurunan NAV pada ex-date tidak tercatat sebagai kerugian
+	dists, err := utils.FundDistributions(mpc.DB, fundData.MutualFundID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch distributions"})
+		return
+	}
+	entries = utils.WithDistributions(entries, dists, series.Navs)
+
+	currency, ok := reportingCurrency(mpc.DB, c, fundData.UserID, fundData.PortfolioID)
+	if !ok {
+		return
+	}
+	valuations, ok := convertValuations(mpc.DB, c, []utils.FundValuation{{
+		MutualFundID: fundData.MutualFundID,
+		ProductName:  series.ProductName,
+		Transactions: entries,
+		Navs:         series.Navs,
+		Days:         utils.BuildDailyValuation(entries, series.Navs),
+	}}, currency)
+	if !ok {
+		return
+	}
+	fv := valuations[0]
+	days := fv.Days
+	var results []NavResult
+	for _, day := range days {
+		results = append(results, NavResult{
+			Date:                     day.Date.Format("2006-01-02"),
+			Value:                    day.Nav,
+			KenaikanHariIni:          day.NavChange,
+			PersenKenaikanHariIni:    day.NavChangePercent,
+			KeuntunganHariIni:        day.DailyGain,
+			PersenKeuntunganHariIni:  day.DailyGainPercent,
+			AkumulasiKeuntungan:      day.TotalGain,
+			AkumulasiKeuntunganBruto: day.GrossTotalGain,
+			AkumulasiDividen:         day.Dividends,
+			KeuntunganKurs:           day.FxGain,
+			TotalBiaya:               day.Fees,
+			TotalBalance:             day.Value,
 		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"portfolio":    fundData,
+		"holding":      utils.BuildHolding(fundData.MutualFundID, fv.Transactions, &fv.Navs[len(fv.Navs)-1]),
+		"fees":         utils.BuildFeeReport(fund, fv.Transactions, days),
+		"nav_data":     results,
+		"product_name": series.ProductName,
+		"currency":     currency,
+	})
+}
+
+// GetAggregatedPortfolioByMutualFundID menampilkan nilai harian ledger user untuk satu reksa dana,
+// di semua portfolio atau satu portfolio (query parameter portfolio_id), dalam mata uang laporan
+// (query parameter currency, default base_currency portfolio)
+func (mpc *MyPortfolioController) GetAggregatedPortfolioByMutualFundID(c *gin.Context) {
+	// Parse mutual fund ID
+	mfID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mutual fund ID"})
 		return
 	}
 
-	if len(response.Data.Datas) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "NAV data not found in response"})
+	// Ambil ID user dari context
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
 		return
 	}
 
-	navRaw := response.Data.Datas[0].Nav
+	portfolioID, ok := portfolioScope(mpc.DB, c, userID.(uint))
+	if !ok {
+		return
+	}
 
-	modal := fundData.Value // Rp1 Miliar
+	// Ambil semua transaksi dengan mutual_fund_id yang sama untuk user ini
+	portfolios, err := utils.LoadLedger(mpc.DB, userID.(uint), portfolioID, uint(mfID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch portfolios"})
+		return
+	}
 
-	type NavResult struct {
-		Date                    string  `json:"date"`
-		Value                   float64 `json:"value"`
-		KenaikanHariIni         float64 `json:"kenaikan_hari_ini"`
-		PersenKenaikanHariIni   float64 `json:"persen_kenaikan_hari_ini"`
-		KeuntunganHariIni       float64 `json:"keuntungan_hari_ini"`
-		PersenKeuntunganHariIni float64 `json:"persen_keuntungan_hari_ini"`
-		AkumulasiKeuntungan     float64 `json:"akumulasi_keuntungan"`
-		TotalBalance            float64 `json:"total_balance"`
+	if len(portfolios) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No portfolios found for this mutual fund"})
+		return
 	}
 
-	var results []NavResult
-	var prevValue float64
-	var akumulasiKeuntungan float64
+	// Tentukan tanggal awal (dari transaksi pertama) dan tanggal akhir (hari ini)
+	// Mulai dari hari sebelumnya (-1 hari) untuk mendapatkan nilai awal
+	startDateStr := portfolios[0].Date.AddDate(0, 0, -1).Format("2006-01-02")
+	endDateStr := time.Now().Format("2006-01-02")
 
-	productName := response.Data.Datas[0].PName
+	series, err := utils.GetMutualFundNav(mpc.DB, uint(mfID), "custom", startDateStr, endDateStr)
+	if err != nil {
+		c.JSON(upstreamErrorStatus(err), gin.H{
+			"error":  "Failed to fetch NAV data",
+			"detail": err.Error(),
+		})
+		return
+	}
 
-	for i, nav := range navRaw {
-		val, err := strconv.ParseFloat(nav.Value, 64)
-		if err != nil {
-			continue
-		}
+	c.Header("X-Cache", series.CacheStatus)
+	if series.Stale {
+		c.Header("Warning", `110 - "NAV provider unavailable, serving stored data"`)
+	}
 
-		// Hari pertama (hari sebelum portfolio masuk) - simpan sebagai prevValue, tidak ditampilkan
-		if i == 0 {
-			prevValue = val
-			continue
-		}
+	if len(series.Navs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "NAV data not found"})
+		return
+	}
 
-		// Hitung kenaikan dan keuntungan
-		diff := val - prevValue
-		persen := 0.0
-		if prevValue != 0 {
-			persen = (diff / prevValue) * 100
-		}
-		rpGain := persen * modal / 100
-		akumulasiKeuntungan += rpGain
-		totalBalance := modal + akumulasiKeuntungan
+	type NavResult struct {
+		Date                     string  `json:"date"`
+		Value                    float64 `json:"value"`
+		KenaikanHariIni          float64 `json:"kenaikan_hari_ini"`
+		PersenKenaikanHariIni    float64 `json:"persen_kenaikan_hari_ini"`
+		TotalModal               float64 `json:"total_modal"`
+		Units                    float64 `json:"units"`
+		KeuntunganHariIni        float64 `json:"keuntungan_hari_ini"`
+		PersenKeuntunganHariIni  float64 `json:"persen_keuntungan_hari_ini"`
+		AkumulasiKeuntungan      float64 `json:"akumulasi_keuntungan"`
+		AkumulasiKeuntunganBruto float64 `json:"akumulasi_keuntungan_bruto"`
+		AkumulasiDividen         float64 `json:"akumulasi_dividen"`
+		KeuntunganKurs           float64 `json:"keuntungan_kurs"`
+		TotalBiaya               float64 `json:"total_biaya"`
+		TotalBalance             float64 `json:"total_balance"`
+	}
 
+	var fund models.MutualFund
+	if err := mpc.DB.First(&fund, mfID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mutual fund not found"})
+		return
+	}
+
+	// Nilai harian dibaca dari portfolio_valuations yang diperbarui saat NAV atau transaksi berubah
+	days, err := utils.StoredDailyValuations(mpc.DB, userID.(uint), portfolioID, uint(mfID), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load valuation"})
+		return
+	}
+	currency, ok := reportingCurrency(mpc.DB, c, userID.(uint), portfolioID)
+	if !ok {
+		return
+	}
+	valuations, ok := convertValuations(mpc.DB, c, []utils.FundValuation{{
+		MutualFundID: uint(mfID),
+		ProductName:  series.ProductName,
+		Transactions: portfolios,
+		Navs:         series.Navs,
+		Days:         days,
+	}}, currency)
+	if !ok {
+		return
+	}
+	fv := valuations[0]
+	days = fv.Days
+	var results []NavResult
+	for _, day := range days {
 		results = append(results, NavResult{
-			Date:                    nav.Date,
-			Value:                   val,
-			KenaikanHariIni:         diff,
-			PersenKenaikanHariIni:   persen,
-			KeuntunganHariIni:       rpGain,
-			PersenKeuntunganHariIni: persen,
-			AkumulasiKeuntungan:     akumulasiKeuntungan,
-			TotalBalance:            totalBalance,
+			Date:                     day.Date.Format("2006-01-02"),
+			Value:                    day.Nav,
+			KenaikanHariIni:          day.NavChange,
+			PersenKenaikanHariIni:    day.NavChangePercent,
+			TotalModal:               day.CostBasis,
+			Units:                    day.Units,
+			KeuntunganHariIni:        day.DailyGain,
+			PersenKeuntunganHariIni:  day.DailyGainPercent,
+			AkumulasiKeuntungan:      day.TotalGain,
+			AkumulasiKeuntunganBruto: day.GrossTotalGain,
+			AkumulasiDividen:         day.Dividends,
+			KeuntunganKurs:           day.FxGain,
+			TotalBiaya:               day.Fees,
+			TotalBalance:             day.Value,
 		})
-
-		prevValue = val
 	}
 
+	holding := utils.BuildHolding(uint(mfID), fv.Transactions, &fv.Navs[len(fv.Navs)-1])
+
 	c.JSON(http.StatusOK, gin.H{
-		"portfolio":    fundData,
+		"portfolios":   portfolios,
+		"holding":      holding,
+		"fees":         utils.BuildFeeReport(fund, fv.Transactions, days),
 		"nav_data":     results,
-		"product_name": productName,
+		"product_name": series.ProductName,
+		"total_modal":  holding.TotalInvested,
+		"currency":     currency,
 	})
 }
 
-func (mpc *MyPortfolioController) GetAggregatedPortfolioByMutualFundID(c *gin.Context) {
-	mutualFundID := c.Param("id")
+// redeemInput adalah body POST /portfolio/mutual-fund/:id/redeem. Isi salah satu dari units,
+// amount, atau all=true untuk menjual seluruh unit.
+type redeemInput struct {
+	PortfolioID uint      `json:"portfolio_id"`
+	Date        time.Time `json:"date" binding:"required"`
+	Units       float64   `json:"units"`
+	Amount      float64   `json:"amount"`
+	All         bool      `json:"all"`
+	Fee         float64   `json:"fee"`
+	CostMethod  string    `json:"cost_method"`
+	Note        string    `json:"note"`
+}
+
+// RedeemPortfolio mencatat penjualan (SELL) sebagian atau seluruh unit satu reksa dana.
+// Penjualan dicocokkan dengan lot pembelian memakai FIFO (default) atau AVERAGE.
+func (mpc *MyPortfolioController) RedeemPortfolio(c *gin.Context) {
+	mfID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mutual fund ID"})
+		return
+	}
+
+	var input redeemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	if input.Units < 0 || input.Amount < 0 || input.Fee < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "units, amount and fee must not be negative"})
+		return
+	}
+	if !input.All && input.Units == 0 && input.Amount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "One of units, amount or all is required"})
+		return
+	}
+	if input.CostMethod == "" {
+		input.CostMethod = models.CostMethodFIFO
+	}
+	if !models.ValidCostMethod(input.CostMethod) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cost_method must be FIFO or AVERAGE"})
+		return
+	}
 
-	// Ambil ID user dari context
 	userID, exists := c.Get("userID")
 	if !exists {
 		c.JSON(400, gin.H{"error": "User ID not found"})
 		return
 	}
 
-	// Ambil semua portfolio dengan mutual_fund_id yang sama untuk user ini
-	var portfolios []MyPortfolio
-	if err := mpc.DB.Where("mutual_fund_id = ? AND user_id = ? AND deleted_at IS NULL", mutualFundID, userID).Order("date ASC").Find(&portfolios).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch portfolios"})
+	portfolioID, ok := targetPortfolio(mpc.DB, c, userID.(uint), input.PortfolioID)
+	if !ok {
 		return
 	}
 
-	if len(portfolios) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No portfolios found for this mutual fund"})
+	tx := models.Transaction{
+		UserID:       userID.(uint),
+		PortfolioID:  portfolioID,
+		MutualFundID: uint(mfID),
+		Type:         models.TransactionSell,
+		Date:         utils.DateOnly(input.Date),
+		Units:        input.Units,
+		Amount:       input.Amount,
+		Fee:          input.Fee,
+		CostMethod:   input.CostMethod,
+		Note:         input.Note,
+	}
+
+	if input.All {
+		held, err := utils.UnitsHeld(mpc.DB, tx.UserID, tx.PortfolioID, tx.MutualFundID, tx.Date)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load holdings"})
+			return
+		}
+		if held <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No units held for this mutual fund"})
+			return
+		}
+		tx.Units, tx.Amount = held, 0
+	}
+
+	if err := utils.RecordTransaction(mpc.DB, &tx); err != nil {
+		respondLedgerError(c, err)
 		return
 	}
 
-	// Tentukan tanggal awal (dari portfolio pertama) dan tanggal akhir (hari ini)
-	// Mulai dari hari sebelumnya (-1 hari) untuk mendapatkan nilai awal
-	startDate := portfolios[0].Date.AddDate(0, 0, -1)
-	endDate := time.Now()
+	// Ambil ulang agar cost_basis dan realized_gain hasil pencocokan lot ikut dikembalikan
+	mpc.DB.First(&tx, tx.ID)
 
-	// Fetch NAV data
-	cperiod := "custom"
-	startDateStr := startDate.Format("2006-01-02")
-	endDateStr := endDate.Format("2006-01-02")
+	var matches []models.LotMatch
+	if err := mpc.DB.Where("sell_transaction_id = ?", tx.ID).Order("id ASC").Find(&matches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lot matches"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"transaction": tx,
+		"lot_matches": matches,
+	})
+}
 
-	// Parse mutual fund ID for API call
-	mfID, err := strconv.ParseUint(mutualFundID, 10, 32)
+// GetLotsByMutualFundID menampilkan lot pembelian yang masih tersisa serta laba/rugi yang sudah
+// direalisasikan untuk satu reksa dana, dengan lot dinilai memakai NAV terakhir yang tersimpan
+func (mpc *MyPortfolioController) GetLotsByMutualFundID(c *gin.Context) {
+	mfID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mutual fund ID"})
 		return
 	}
 
-	body, err := utils.GetMutualFundNav(mpc.DB, uint(mfID), cperiod, startDateStr, endDateStr)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":  "Failed to fetch NAV data from Bareksa",
-			"detail": err.Error(),
-		})
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
 		return
 	}
 
-	type NAV struct {
-		Date  string `json:"date"`
-		Value string `json:"value"`
+	portfolioID, ok := portfolioScope(mpc.DB, c, userID.(uint))
+	if !ok {
+		return
 	}
 
-	type FundData struct {
-		PName string `json:"pname"`
-		Nav   []NAV  `json:"nav"`
+	txs, err := utils.LoadLedger(mpc.DB, userID.(uint), portfolioID, uint(mfID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch portfolios"})
+		return
+	}
+	if len(txs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No portfolios found for this mutual fund"})
+		return
 	}
 
-	type ResponseData struct {
-		Datas []FundData `json:"datas"`
+	latest, err := utils.LatestStoredNav(mpc.DB, uint(mfID))
+	if err != nil && !errors.Is(err, utils.ErrNavNotAvailable) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NAV data"})
+		return
 	}
+	holding := utils.BuildHolding(uint(mfID), txs, latest)
 
-	type BareksaResponse struct {
-		Status bool         `json:"status"`
-		Data   ResponseData `json:"data"`
+	matchQuery := mpc.DB.Where("user_id = ? AND mutual_fund_id = ?", userID, mfID)
+	if portfolioID != 0 {
+		matchQuery = matchQuery.Where("portfolio_id = ?", portfolioID)
+	}
+	var matches []models.LotMatch
+	if err := matchQuery.Order("sell_transaction_id ASC, id ASC").Find(&matches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lot matches"})
+		return
 	}
 
-	var response BareksaResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":  "Failed to parse NAV data",
-			"detail": err.Error(),
-		})
+	c.JSON(http.StatusOK, gin.H{
+		"mutual_fund_id":  mfID,
+		"units":           holding.Units,
+		"total_invested":  holding.TotalInvested,
+		"unrealized_gain": holding.UnrealizedGain,
+		"realized_gain":   holding.RealizedGain,
+		"lots":            holding.Lots,
+		"lot_matches":     matches,
+	})
+}
+
+// GetPortfolioSummary menampilkan dashboard gabungan semua reksa dana milik user (atau satu
+// portfolio lewat query parameter portfolio_id): total modal,
+// nilai saat ini, perubahan hari ini, keuntungan sejak awal, alokasi per reksa dana / manajer
+// investasi / kategori, dan deret nilai harian gabungan. Nilai dikonversi ke mata uang laporan (query
+// parameter currency, default base_currency portfolio) dengan keuntungan kurs ditampilkan terpisah.
+func (mpc *MyPortfolioController) GetPortfolioSummary(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
 		return
 	}
 
-	if len(response.Data.Datas) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "NAV data not found in response"})
+	portfolioID, ok := portfolioScope(mpc.DB, c, userID.(uint))
+	if !ok {
 		return
 	}
 
-	navRaw := response.Data.Datas[0].Nav
-	productName := response.Data.Datas[0].PName
+	valuations, err := utils.LoadPortfolioValuations(mpc.DB, userID.(uint), portfolioID, time.Now())
+	if err != nil {
+		c.JSON(upstreamErrorStatus(err), gin.H{"error": "Failed to load valuation", "detail": err.Error()})
+		return
+	}
+	currency, ok := reportingCurrency(mpc.DB, c, userID.(uint), portfolioID)
+	if !ok {
+		return
+	}
+	if valuations, ok = convertValuations(mpc.DB, c, valuations, currency); !ok {
+		return
+	}
 
-	// Buat map untuk mencari portfolio berdasarkan tanggal
-	portfoliosByDate := make(map[string]float64) // date -> modal amount
-	for _, portfolio := range portfolios {
-		dateKey := portfolio.Date.Format("2006-01-02")
-		portfoliosByDate[dateKey] += portfolio.Value
+	fundIDs := make([]uint, 0, len(valuations))
+	stale := false
+	for _, fv := range valuations {
+		fundIDs = append(fundIDs, fv.MutualFundID)
+		stale = stale || fv.Stale
+	}
+	var fundRows []models.MutualFund
+	if len(fundIDs) > 0 {
+		if err := mpc.DB.Where("id IN ?", fundIDs).Find(&fundRows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mutual funds"})
+			return
+		}
+	}
+	funds := make(map[uint]models.MutualFund, len(fundRows))
+	for _, fund := range fundRows {
+		funds[fund.ID] = fund
 	}
 
-	type NavResult struct {
-		Date                    string  `json:"date"`
-		Value                   float64 `json:"value"`
-		KenaikanHariIni         float64 `json:"kenaikan_hari_ini"`
-		PersenKenaikanHariIni   float64 `json:"persen_kenaikan_hari_ini"`
-		TotalModal              float64 `json:"total_modal"`
-		KeuntunganHariIni       float64 `json:"keuntungan_hari_ini"`
-		PersenKeuntunganHariIni float64 `json:"persen_keuntungan_hari_ini"`
-		AkumulasiKeuntungan     float64 `json:"akumulasi_keuntungan"`
-		TotalBalance            float64 `json:"total_balance"`
+	if stale {
+		c.Header("Warning", `110 - "NAV provider unavailable, serving stored data"`)
 	}
 
-	var results []NavResult
-	var prevValue float64
-	var totalModal float64
-	
-	// Akumulasi keuntungan per tanggal masuk portfolio
-	akumulasiPerEntry := make(map[string]float64)
-
-	for i, nav := range navRaw {
-		val, err := strconv.ParseFloat(nav.Value, 64)
+	summary := utils.BuildPortfolioSummary(valuations, funds)
+	summary.Currency = currency
+
+	type DailyResult struct {
+		Date                string  `json:"date"`
+		TotalModal          float64 `json:"total_modal"`
+		KeuntunganHariIni   float64 `json:"keuntungan_hari_ini"`
+		AkumulasiKeuntungan float64 `json:"akumulasi_keuntungan"`
+		AkumulasiDividen    float64 `json:"akumulasi_dividen"`
+		KeuntunganKurs      float64 `json:"keuntungan_kurs"`
+		TotalBalance        float64 `json:"total_balance"`
+	}
+	daily := make([]DailyResult, 0, len(summary.Daily))
+	for _, day := range summary.Daily {
+		daily = append(daily, DailyResult{
+			Date:                day.Date.Format("2006-01-02"),
+			TotalModal:          day.CostBasis,
+			KeuntunganHariIni:   day.DailyGain,
+			AkumulasiKeuntungan: day.TotalGain,
+			AkumulasiDividen:    day.Dividends,
+			KeuntunganKurs:      day.FxGain,
+			TotalBalance:        day.Value,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary":  summary,
+		"nav_data": daily,
+	})
+}
+
+// ExportStatement menangani GET /portfolio/statement: laporan periode start_date sampai end_date
+// (default awal bulan berjalan sampai hari ini) dalam format csv, xlsx, atau pdf. Nilai dihitung dari
+// portfolio_valuations yang sama dengan tampilan agregat per reksa dana. Query parameter opsional
+// portfolio_id dan mutual_fund_id membatasi isi laporan; currency mengganti mata uang laporan
+// (default base_currency portfolio).
+func (mpc *MyPortfolioController) ExportStatement(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+	portfolioID, ok := portfolioScope(mpc.DB, c, userID.(uint))
+	if !ok {
+		return
+	}
+	start, end, ok := parseReturnRange(c)
+	if !ok {
+		return
+	}
+	if start.IsZero() {
+		start = time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	format := c.DefaultQuery("format", utils.StatementFormatPDF)
+
+	var valuations []utils.FundValuation
+	if v := c.Query("mutual_fund_id"); v != "" {
+		fundID, err := strconv.ParseUint(v, 10, 32)
 		if err != nil {
-			continue
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mutual fund ID"})
+			return
 		}
-
-		navDate, err := time.Parse("2006-01-02", nav.Date)
+		fv, err := utils.LoadFundValuation(mpc.DB, userID.(uint), portfolioID, uint(fundID), end)
 		if err != nil {
-			continue
+			c.JSON(upstreamErrorStatus(err), gin.H{"error": "Failed to load valuation", "detail": err.Error()})
+			return
 		}
-
-		// Hari pertama (hari sebelum portfolio pertama masuk) - skip tampilan, hanya simpan prevValue
-		if i == 0 {
-			prevValue = val
-			continue
+		if fv != nil {
+			valuations = append(valuations, *fv)
 		}
-
-		// Check if ada portfolio baru masuk hari ini
-		dateKey := navDate.Format("2006-01-02")
-		if newModalAmount, exists := portfoliosByDate[dateKey]; exists {
-			totalModal += newModalAmount
-			akumulasiPerEntry[dateKey] = 0.0
-			log.Printf("New portfolio entry on %s with value %f, total modal now: %f", dateKey, newModalAmount, totalModal)
+	} else {
+		var err error
+		valuations, err = utils.LoadPortfolioValuations(mpc.DB, userID.(uint), portfolioID, end)
+		if err != nil {
+			c.JSON(upstreamErrorStatus(err), gin.H{"error": "Failed to load valuation", "detail": err.Error()})
+			return
 		}
+	}
 
-		// Hitung persen kenaikan hari ini
-		diff := val - prevValue
-		persen := 0.0
-		if prevValue != 0 {
-			persen = (diff / prevValue) * 100
-		}
+	currency, ok := reportingCurrency(mpc.DB, c, userID.(uint), portfolioID)
+	if !ok {
+		return
+	}
+	if valuations, ok = convertValuations(mpc.DB, c, valuations, currency); !ok {
+		return
+	}
 
-		// Hitung keuntungan dan akumulasi untuk setiap portfolio yang sudah aktif
-		totalModalToday := 0.0
-		keuntunganHariIni := 0.0
-		totalAkumulasi := 0.0
-		
-		for entryDate, entryModal := range portfoliosByDate {
-			parsedEntryDate, _ := time.Parse("2006-01-02", entryDate)
-			
-			// Portfolio yang masuk sebelum atau pada hari ini ikut dihitung
-			if parsedEntryDate.Before(navDate) || parsedEntryDate.Equal(navDate) {
-				totalModalToday += entryModal
-				
-				// Keuntungan hari ini untuk portfolio ini
-				rpGain := persen * entryModal / 100
-				keuntunganHariIni += rpGain
-				
-				// Update akumulasi untuk portfolio ini
-				akumulasiPerEntry[entryDate] += rpGain
-				totalAkumulasi += akumulasiPerEntry[entryDate]
-			}
-		}
+	names, stale, err := mpc.fundNames(valuations)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mutual funds"})
+		return
+	}
 
-		totalBalance := totalModalToday + totalAkumulasi
+	statement := utils.BuildStatement(valuations, names, start, end)
+	statement.PortfolioName = mpc.portfolioName(portfolioID)
+	statement.Currency = currency
 
-		results = append(results, NavResult{
-			Date:                    nav.Date,
-			Value:                   val,
-			KenaikanHariIni:         diff,
-			PersenKenaikanHariIni:   persen,
-			TotalModal:              totalModalToday,
-			KeuntunganHariIni:       keuntunganHariIni,
-			PersenKeuntunganHariIni: persen,
-			AkumulasiKeuntungan:     totalAkumulasi,
-			TotalBalance:            totalBalance,
-		})
+	var buf bytes.Buffer
+	if err := utils.WriteStatement(&buf, statement, format); err != nil {
+		if errors.Is(err, utils.ErrUnsupportedFormat) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, xlsx or pdf"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render statement"})
+		return
+	}
+
+	if stale {
+		c.Header("Warning", `110 - "NAV provider unavailable, serving stored data"`)
+	}
+	filename := fmt.Sprintf("statement-%s-%s.%s", statement.StartDate, statement.EndDate, format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, utils.StatementContentType(format), buf.Bytes())
+}
 
-		prevValue = val
+// fundNames mengembalikan nama reksa dana untuk setiap valuasi dan apakah ada NAV yang disajikan
+// dari data tersimpan karena provider gagal
+func (mpc *MyPortfolioController) fundNames(valuations []utils.FundValuation) (map[uint]string, bool, error) {
+	fundIDs := make([]uint, 0, len(valuations))
+	stale := false
+	for _, fv := range valuations {
+		fundIDs = append(fundIDs, fv.MutualFundID)
+		stale = stale || fv.Stale
+	}
+	var funds []models.MutualFund
+	if len(fundIDs) > 0 {
+		if err := mpc.DB.Select("id", "name").Where("id IN ?", fundIDs).Find(&funds).Error; err != nil {
+			return nil, stale, err
+		}
+	}
+	names := make(map[uint]string, len(funds))
+	for _, fund := range funds {
+		names[fund.ID] = fund.Name
 	}
+	return names, stale, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"portfolios":   portfolios,
-		"nav_data":     results,
-		"product_name": productName,
-		"total_modal":  totalModal,
-	})
+// portfolioName mengembalikan nama portfolio untuk judul laporan; 0 berarti semua portfolio
+func (mpc *MyPortfolioController) portfolioName(portfolioID uint) string {
+	if portfolioID != 0 {
+		var portfolio models.Portfolio
+		if err := mpc.DB.Select("name").First(&portfolio, portfolioID).Error; err == nil {
+			return portfolio.Name
+		}
+	}
+	return "Semua Portfolio"
 }
 
+// GetAnnualReport menangani GET /portfolio/annual-report: pembelian, penjualan, keuntungan
+// terealisasi, dividen, dan nilai akhir tahun per reksa dana untuk tahun year (default tahun lalu).
+// format=json (default), csv, xlsx, atau pdf; query parameter portfolio_id dan currency opsional.
+func (mpc *MyPortfolioController) GetAnnualReport(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+	portfolioID, ok := portfolioScope(mpc.DB, c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	year := now.Year() - 1
+	if v := c.Query("year"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1900 || parsed > now.Year() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "year must be a past or current year"})
+			return
+		}
+		year = parsed
+	}
+	format := c.DefaultQuery("format", "json")
 
+	// Nilai akhir tahun berjalan memakai NAV terakhir sampai hari ini
+	end := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if end.After(now) {
+		end = utils.DateOnly(now)
+	}
+	valuations, err := utils.LoadPortfolioValuations(mpc.DB, userID.(uint), portfolioID, end)
+	if err != nil {
+		c.JSON(upstreamErrorStatus(err), gin.H{"error": "Failed to load valuation", "detail": err.Error()})
+		return
+	}
+	currency, ok := reportingCurrency(mpc.DB, c, userID.(uint), portfolioID)
+	if !ok {
+		return
+	}
+	if valuations, ok = convertValuations(mpc.DB, c, valuations, currency); !ok {
+		return
+	}
+	names, stale, err := mpc.fundNames(valuations)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mutual funds"})
+		return
+	}
+	if stale {
+		c.Header("Warning", `110 - "NAV provider unavailable, serving stored data"`)
+	}
 
+	report := utils.BuildAnnualReport(valuations, names, year)
+	report.PortfolioName = mpc.portfolioName(portfolioID)
+	report.Currency = currency
+	if format == "json" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
 
+	var buf bytes.Buffer
+	if err := utils.WriteAnnualReport(&buf, report, format); err != nil {
+		if errors.Is(err, utils.ErrUnsupportedFormat) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv, xlsx or pdf"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render annual report"})
+		return
+	}
+	filename := fmt.Sprintf("annual-report-%d.%s", year, format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, utils.StatementContentType(format), buf.Bytes())
+}
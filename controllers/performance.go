@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"golang/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PerformanceController struct {
+	DB *gorm.DB
+}
+
+func NewPerformanceController(db *gorm.DB) *PerformanceController {
+	return &PerformanceController{DB: db}
+}
+
+// parseReturnRange membaca start_date dan end_date (YYYY-MM-DD, opsional). Tanpa start_date
+// rentang dimulai dari transaksi pertama; tanpa end_date sampai hari ini.
+func parseReturnRange(c *gin.Context) (time.Time, time.Time, bool) {
+	start := time.Time{}
+	end := utils.DateOnly(time.Now())
+	if v := c.Query("start_date"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must be YYYY-MM-DD"})
+			return start, end, false
+		}
+		start = parsed
+	}
+	if v := c.Query("end_date"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must be YYYY-MM-DD"})
+			return start, end, false
+		}
+		end = parsed
+	}
+	if end.Before(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+		return start, end, false
+	}
+	return start, end, true
+}
+
+// firstValuationDate mengembalikan tanggal awal rentang: start jika diisi, atau hari pertama deret
+func firstValuationDate(start time.Time, days []utils.DailyValuation) time.Time {
+	if start.IsZero() && len(days) > 0 {
+		return days[0].Date
+	}
+	return start
+}
+
+// GetFundReturns menangani GET /portfolio/mutual-fund/:id/returns: XIRR dan TWR satu reksa dana
+// dalam mata uang laporan (query parameter currency)
+func (pc *PerformanceController) GetFundReturns(c *gin.Context) {
+	mfID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mutual fund ID"})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	portfolioID, ok := portfolioScope(pc.DB, c, userID.(uint))
+	if !ok {
+		return
+	}
+	start, end, ok := parseReturnRange(c)
+	if !ok {
+		return
+	}
+
+	fv, err := utils.LoadFundValuation(pc.DB, userID.(uint), portfolioID, uint(mfID), end)
+	if err != nil {
+		c.JSON(upstreamErrorStatus(err), gin.H{"error": "Failed to load valuation", "detail": err.Error()})
+		return
+	}
+	if fv == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No portfolios found for this mutual fund"})
+		return
+	}
+	if fv.Stale {
+		c.Header("Warning", `110 - "NAV provider unavailable, serving stored data"`)
+	}
+	currency, ok := reportingCurrency(pc.DB, c, userID.(uint), portfolioID)
+	if !ok {
+		return
+	}
+	converted, ok := convertValuations(pc.DB, c, []utils.FundValuation{*fv}, currency)
+	if !ok {
+		return
+	}
+	days := converted[0].Days
+
+	c.JSON(http.StatusOK, gin.H{
+		"mutual_fund_id": fv.MutualFundID,
+		"product_name":   fv.ProductName,
+		"currency":       currency,
+		"returns":        utils.ComputeReturns(days, firstValuationDate(start, days), end),
+	})
+}
+
+// GetPortfolioReturns menangani GET /portfolio/returns: XIRR dan TWR seluruh kepemilikan user
+// (atau satu portfolio lewat query parameter portfolio_id) beserta rincian per reksa dana,
+// dalam mata uang laporan (query parameter currency)
+func (pc *PerformanceController) GetPortfolioReturns(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	portfolioID, ok := portfolioScope(pc.DB, c, userID.(uint))
+	if !ok {
+		return
+	}
+	start, end, ok := parseReturnRange(c)
+	if !ok {
+		return
+	}
+
+	valuations, err := utils.LoadPortfolioValuations(pc.DB, userID.(uint), portfolioID, end)
+	if err != nil {
+		c.JSON(upstreamErrorStatus(err), gin.H{"error": "Failed to load valuation", "detail": err.Error()})
+		return
+	}
+	currency, ok := reportingCurrency(pc.DB, c, userID.(uint), portfolioID)
+	if !ok {
+		return
+	}
+	valuations, ok = convertValuations(pc.DB, c, valuations, currency)
+	if !ok {
+		return
+	}
+
+	type fundReturns struct {
+		MutualFundID uint                `json:"mutual_fund_id"`
+		ProductName  string              `json:"product_name"`
+		Returns      utils.ReturnMetrics `json:"returns"`
+	}
+
+	funds := make([]fundReturns, 0, len(valuations))
+	series := make([][]utils.DailyValuation, 0, len(valuations))
+	stale := false
+	for _, fv := range valuations {
+		series = append(series, fv.Days)
+		stale = stale || fv.Stale
+	}
+	combined := utils.CombineDailyValuations(series)
+	from := firstValuationDate(start, combined)
+	for _, fv := range valuations {
+		funds = append(funds, fundReturns{
+			MutualFundID: fv.MutualFundID,
+			ProductName:  fv.ProductName,
+			Returns:      utils.ComputeReturns(fv.Days, from, end),
+		})
+	}
+	if stale {
+		c.Header("Warning", `110 - "NAV provider unavailable, serving stored data"`)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"currency": currency,
+		"returns":  utils.ComputeReturns(combined, from, end),
+		"funds":    funds,
+	})
+}
@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"errors"
+	"golang/models"
+	"golang/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PortfolioController mengelola portfolio bernama milik user (misalnya dana pensiun, dana
+// pendidikan). Semua transaksi, rencana berkala, dan tampilan ledger berada di dalam satu portfolio.
+type PortfolioController struct {
+	DB *gorm.DB
+}
+
+func NewPortfolioController(db *gorm.DB) *PortfolioController {
+	return &PortfolioController{DB: db}
+}
+
+// portfolioAccountInput adalah body POST/PUT /portfolios
+type portfolioAccountInput struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	BaseCurrency string `json:"base_currency"`
+}
+
+// GetPortfolios menampilkan semua portfolio user; portfolio default dibuat jika belum ada
+func (pc *PortfolioController) GetPortfolios(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+	if _, err := utils.DefaultPortfolio(pc.DB, userID.(uint)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch portfolios"})
+		return
+	}
+
+	var portfolios []models.Portfolio
+	if err := pc.DB.Where("user_id = ?", userID).Order("is_default DESC, id ASC").Find(&portfolios).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch portfolios"})
+		return
+	}
+	c.JSON(http.StatusOK, portfolios)
+}
+
+func (pc *PortfolioController) CreatePortfolio(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	var input portfolioAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	portfolio := models.Portfolio{UserID: userID.(uint)}
+	if !applyPortfolioInput(c, &portfolio, input) {
+		return
+	}
+	if portfolio.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	// Pastikan portfolio default ada lebih dulu agar ledger lama tidak pindah ke portfolio baru
+	if _, err := utils.DefaultPortfolio(pc.DB, portfolio.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create portfolio"})
+		return
+	}
+
+	if err := pc.DB.Create(&portfolio).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "duplicate key") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Portfolio name already used"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create portfolio"})
+		return
+	}
+	c.JSON(http.StatusCreated, portfolio)
+}
+
+func (pc *PortfolioController) UpdatePortfolio(c *gin.Context) {
+	portfolio, ok := pc.findPortfolio(c)
+	if !ok {
+		return
+	}
+
+	var input portfolioAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	if !applyPortfolioInput(c, portfolio, input) {
+		return
+	}
+
+	if err := pc.DB.Model(portfolio).Select("name", "description", "base_currency").Updates(portfolio).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "duplicate key") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Portfolio name already used"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update portfolio"})
+		return
+	}
+	c.JSON(http.StatusOK, portfolio)
+}
+
+// DeletePortfolio menghapus portfolio yang sudah kosong. Portfolio default dan portfolio yang
+// masih punya transaksi atau rencana berkala aktif tidak bisa dihapus.
+func (pc *PortfolioController) DeletePortfolio(c *gin.Context) {
+	portfolio, ok := pc.findPortfolio(c)
+	if !ok {
+		return
+	}
+	if portfolio.IsDefault {
+		c.JSON(http.StatusConflict, gin.H{"error": "Default portfolio cannot be deleted"})
+		return
+	}
+
+	var txCount, planCount int64
+	if err := pc.DB.Model(&models.Transaction{}).
+		Where("portfolio_id = ? AND deleted_at IS NULL", portfolio.ID).Count(&txCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete portfolio"})
+		return
+	}
+	if err := pc.DB.Model(&models.RecurringPlan{}).
+		Where("portfolio_id = ? AND status IN ?", portfolio.ID, []string{models.PlanStatusActive, models.PlanStatusPaused}).Count(&planCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete portfolio"})
+		return
+	}
+	if txCount > 0 || planCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":        "Portfolio is not empty",
+			"transactions": txCount,
+			"active_plans": planCount,
+		})
+		return
+	}
+
+	if err := pc.DB.Delete(portfolio).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete portfolio"})
+		return
+	}
+	c.JSON(204, nil)
+}
+
+func (pc *PortfolioController) findPortfolio(c *gin.Context) (*models.Portfolio, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return nil, false
+	}
+	var portfolio models.Portfolio
+	if err := pc.DB.Where("user_id = ?", userID).First(&portfolio, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return nil, false
+	}
+	return &portfolio, true
+}
+
+// applyPortfolioInput menyalin field yang diisi ke portfolio; base_currency harus kode 3 huruf
+func applyPortfolioInput(c *gin.Context, portfolio *models.Portfolio, input portfolioAccountInput) bool {
+	if name := strings.TrimSpace(input.Name); name != "" {
+		portfolio.Name = name
+	}
+	if input.Description != "" {
+		portfolio.Description = strings.TrimSpace(input.Description)
+	}
+	if input.BaseCurrency != "" {
+		currency, err := utils.NormalizeCurrency(input.BaseCurrency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "base_currency must be a 3-letter currency code"})
+			return false
+		}
+		portfolio.BaseCurrency = currency
+	}
+	if portfolio.BaseCurrency == "" {
+		portfolio.BaseCurrency = models.DefaultCurrency
+	}
+	return true
+}
+
+// portfolioScope membaca query parameter portfolio_id untuk tampilan ledger. Tanpa parameter
+// hasilnya 0, yaitu gabungan semua portfolio user. Menulis respons error jika portfolio tidak valid.
+func portfolioScope(db *gorm.DB, c *gin.Context, userID uint) (uint, bool) {
+	raw := c.Query("portfolio_id")
+	if raw == "" {
+		return 0, true
+	}
+	return ownedPortfolio(db, c, userID, raw)
+}
+
+// targetPortfolio menentukan portfolio tujuan transaksi baru: requested dari body, lalu query
+// parameter portfolio_id, lalu portfolio default user
+func targetPortfolio(db *gorm.DB, c *gin.Context, userID, requested uint) (uint, bool) {
+	if requested != 0 {
+		return ownedPortfolio(db, c, userID, strconv.FormatUint(uint64(requested), 10))
+	}
+	if raw := c.Query("portfolio_id"); raw != "" {
+		return ownedPortfolio(db, c, userID, raw)
+	}
+	portfolio, err := utils.DefaultPortfolio(db, userID)
+	if err != nil {
+		log.Printf("Failed to load default portfolio for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load portfolio"})
+		return 0, false
+	}
+	return portfolio.ID, true
+}
+
+func ownedPortfolio(db *gorm.DB, c *gin.Context, userID uint, raw string) (uint, bool) {
+	id, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid portfolio_id"})
+		return 0, false
+	}
+	var count int64
+	if err := db.Model(&models.Portfolio{}).Where("id = ? AND user_id = ?", id, userID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load portfolio"})
+		return 0, false
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// reportingCurrency menentukan mata uang laporan: query parameter currency, lalu base_currency
+// portfolio yang dipilih (portfolioID 0 berarti portfolio default user)
+func reportingCurrency(db *gorm.DB, c *gin.Context, userID, portfolioID uint) (string, bool) {
+	if raw := c.Query("currency"); raw != "" {
+		currency, err := utils.NormalizeCurrency(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be a 3-letter currency code"})
+			return "", false
+		}
+		return currency, true
+	}
+
+	var portfolio models.Portfolio
+	if portfolioID != 0 {
+		if err := db.Select("id", "base_currency").First(&portfolio, portfolioID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load portfolio"})
+			return "", false
+		}
+	} else {
+		defaultPortfolio, err := utils.DefaultPortfolio(db, userID)
+		if err != nil {
+			log.Printf("Failed to load default portfolio for user %d: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load portfolio"})
+			return "", false
+		}
+		portfolio = *defaultPortfolio
+	}
+	if portfolio.BaseCurrency == "" {
+		return models.DefaultCurrency, true
+	}
+	return portfolio.BaseCurrency, true
+}
+
+// convertValuations mengubah valuations ke mata uang laporan; jika gagal, error sudah ditulis ke response
+func convertValuations(db *gorm.DB, c *gin.Context, valuations []utils.FundValuation, currency string) ([]utils.FundValuation, bool) {
+	converted, err := utils.ConvertValuations(db, valuations, currency)
+	if err != nil {
+		if errors.Is(err, utils.ErrFxRateNotAvailable) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "FX rate is not available", "detail": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to convert valuation"})
+		return nil, false
+	}
+	return converted, true
+}
@@ -0,0 +1,339 @@
+package controllers
+
+import (
+	"golang/models"
+	"golang/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// upcomingPlanCount adalah jumlah jadwal berikutnya yang ditampilkan per rencana di daftar
+const upcomingPlanCount = 3
+
+type RecurringPlanController struct {
+	DB *gorm.DB
+}
+
+func NewRecurringPlanController(db *gorm.DB) *RecurringPlanController {
+	return &RecurringPlanController{DB: db}
+}
+
+type recurringPlanInput struct {
+	PortfolioID  uint       `json:"portfolio_id"`
+	MutualFundID uint       `json:"mutual_fund_id" binding:"required"`
+	Amount       float64    `json:"amount" binding:"required"`
+	Fee          float64    `json:"fee"`
+	Frequency    string     `json:"frequency"`
+	DayOfMonth   int        `json:"day_of_month" binding:"required"`
+	StartDate    time.Time  `json:"start_date" binding:"required"`
+	EndDate      *time.Time `json:"end_date"`
+}
+
+type recurringPlanResponse struct {
+	models.RecurringPlan
+	Upcoming []utils.PlanExecutionPreview `json:"upcoming"`
+}
+
+// GetPlans menampilkan semua rencana investasi berkala milik user beserta jadwal terdekatnya
+func (rpc *RecurringPlanController) GetPlans(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	portfolioID, ok := portfolioScope(rpc.DB, c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	query := rpc.DB.Where("user_id = ?", userID)
+	if portfolioID != 0 {
+		query = query.Where("portfolio_id = ?", portfolioID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var plans []models.RecurringPlan
+	if err := query.Order("id ASC").Find(&plans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recurring plans"})
+		return
+	}
+
+	from := time.Now()
+	for _, plan := range plans {
+		if plan.NextRunDate.Before(from) {
+			from = plan.NextRunDate
+		}
+	}
+	holidays, ok := rpc.holidays(c, from)
+	if !ok {
+		return
+	}
+
+	results := make([]recurringPlanResponse, 0, len(plans))
+	for _, plan := range plans {
+		results = append(results, recurringPlanResponse{RecurringPlan: plan, Upcoming: utils.UpcomingPlanExecutions(plan, upcomingPlanCount, holidays)})
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// GetPlanByID menampilkan satu rencana beserta riwayat eksekusinya
+func (rpc *RecurringPlanController) GetPlanByID(c *gin.Context) {
+	plan, ok := rpc.findPlan(c)
+	if !ok {
+		return
+	}
+
+	var executions []models.RecurringPlanExecution
+	if err := rpc.DB.Where("plan_id = ?", plan.ID).Order("scheduled_date DESC").Find(&executions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch plan executions"})
+		return
+	}
+
+	response, ok := rpc.planResponse(c, plan)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"plan":       response,
+		"executions": executions,
+	})
+}
+
+func (rpc *RecurringPlanController) CreatePlan(c *gin.Context) {
+	var input recurringPlanInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	frequency := strings.ToUpper(input.Frequency)
+	if frequency == "" {
+		frequency = models.PlanFrequencyMonthly
+	}
+	if models.PlanFrequencyMonths(frequency) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "frequency must be MONTHLY or QUARTERLY"})
+		return
+	}
+	if input.DayOfMonth < 1 || input.DayOfMonth > 31 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "day_of_month must be between 1 and 31"})
+		return
+	}
+	if input.Amount <= 0 || input.Fee < 0 || input.Fee >= input.Amount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive and larger than fee"})
+		return
+	}
+	if input.EndDate != nil && input.EndDate.Before(input.StartDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+		return
+	}
+
+	var fund models.MutualFund
+	if err := rpc.DB.First(&fund, input.MutualFundID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mutual fund not found"})
+		return
+	}
+
+	portfolioID, ok := targetPortfolio(rpc.DB, c, userID.(uint), input.PortfolioID)
+	if !ok {
+		return
+	}
+
+	plan := models.RecurringPlan{
+		UserID:       userID.(uint),
+		PortfolioID:  portfolioID,
+		MutualFundID: fund.ID,
+		Amount:       input.Amount,
+		Fee:          input.Fee,
+		Frequency:    frequency,
+		DayOfMonth:   input.DayOfMonth,
+		StartDate:    utils.DateOnly(input.StartDate),
+		Status:       models.PlanStatusActive,
+	}
+	if input.EndDate != nil {
+		end := utils.DateOnly(*input.EndDate)
+		plan.EndDate = &end
+	}
+	plan.NextRunDate = utils.FirstPlanDate(plan)
+	if utils.PlanEnded(plan, plan.NextRunDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Plan has no execution between start_date and end_date"})
+		return
+	}
+
+	if err := rpc.DB.Create(&plan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recurring plan"})
+		return
+	}
+
+	if response, ok := rpc.planResponse(c, plan); ok {
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// GetUpcoming menampilkan jadwal eksekusi berikutnya; query count (default 12, maksimal 60)
+func (rpc *RecurringPlanController) GetUpcoming(c *gin.Context) {
+	plan, ok := rpc.findPlan(c)
+	if !ok {
+		return
+	}
+
+	count, err := strconv.Atoi(c.DefaultQuery("count", "12"))
+	if err != nil || count < 1 || count > 60 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "count must be between 1 and 60"})
+		return
+	}
+
+	holidays, ok := rpc.holidays(c, plan.NextRunDate)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, utils.UpcomingPlanExecutions(plan, count, holidays))
+}
+
+func (rpc *RecurringPlanController) PausePlan(c *gin.Context) {
+	plan, ok := rpc.findPlan(c)
+	if !ok {
+		return
+	}
+	if plan.Status != models.PlanStatusActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only active plans can be paused"})
+		return
+	}
+
+	plan.Status = models.PlanStatusPaused
+	if err := rpc.DB.Model(&plan).Update("status", plan.Status).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pause recurring plan"})
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+// ResumePlan mengaktifkan kembali rencana yang dijeda. Jatuh tempo selama dijeda tidak dieksekusi susulan.
+func (rpc *RecurringPlanController) ResumePlan(c *gin.Context) {
+	plan, ok := rpc.findPlan(c)
+	if !ok {
+		return
+	}
+	if plan.Status != models.PlanStatusPaused {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only paused plans can be resumed"})
+		return
+	}
+
+	// "Hari ini" mengikuti zona waktu scheduler, bukan zona waktu server
+	loc, err := utils.PlanLocation()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load plan time zone"})
+		return
+	}
+	holidays, ok := rpc.holidays(c, plan.NextRunDate)
+	if !ok {
+		return
+	}
+	today := utils.LocalDate(time.Now(), loc)
+	for utils.NextBusinessDay(plan.NextRunDate, holidays).Before(today) {
+		plan.NextRunDate = utils.NextPlanDate(plan, plan.NextRunDate)
+	}
+	plan.Status = models.PlanStatusActive
+	if utils.PlanEnded(plan, plan.NextRunDate) {
+		plan.Status = models.PlanStatusCompleted
+	}
+	// Melanjutkan rencana yang dijeda karena gagal berulang memulai hitungan kegagalan dari awal
+	plan.FailureCount, plan.RetryAt, plan.LastError = 0, nil, ""
+
+	if err := rpc.DB.Model(&plan).Select("status", "next_run_date", "failure_count", "retry_at", "last_error").Updates(&plan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resume recurring plan"})
+		return
+	}
+	if response, ok := rpc.planResponse(c, plan); ok {
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// SkipPlan melewati jatuh tempo berikutnya
+func (rpc *RecurringPlanController) SkipPlan(c *gin.Context) {
+	plan, ok := rpc.findPlan(c)
+	if !ok {
+		return
+	}
+
+	execution, err := utils.SkipPlanExecution(rpc.DB, &plan)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to skip plan execution", "details": err.Error()})
+		return
+	}
+
+	response, ok := rpc.planResponse(c, plan)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"skipped": execution,
+		"plan":    response,
+	})
+}
+
+// CancelPlan menghentikan rencana secara permanen; pembelian yang sudah dibuat tetap ada
+func (rpc *RecurringPlanController) CancelPlan(c *gin.Context) {
+	plan, ok := rpc.findPlan(c)
+	if !ok {
+		return
+	}
+	if plan.Status == models.PlanStatusCancelled || plan.Status == models.PlanStatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Plan is already " + plan.Status})
+		return
+	}
+
+	plan.Status = models.PlanStatusCancelled
+	if err := rpc.DB.Model(&plan).Update("status", plan.Status).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel recurring plan"})
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+// findPlan mengambil rencana :id milik user; response error sudah dikirim jika gagal
+func (rpc *RecurringPlanController) findPlan(c *gin.Context) (models.RecurringPlan, bool) {
+	var plan models.RecurringPlan
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return plan, false
+	}
+	if err := rpc.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&plan).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recurring plan not found"})
+		return plan, false
+	}
+	return plan, true
+}
+
+// holidays membaca hari libur bursa mulai from; response error sudah dikirim jika gagal
+func (rpc *RecurringPlanController) holidays(c *gin.Context, from time.Time) (utils.HolidayCalendar, bool) {
+	holidays, err := utils.LoadHolidayCalendar(rpc.DB, from)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch market holidays"})
+		return nil, false
+	}
+	return holidays, true
+}
+
+// planResponse menyertakan jadwal eksekusi terdekat rencana; response error sudah dikirim jika gagal
+func (rpc *RecurringPlanController) planResponse(c *gin.Context, plan models.RecurringPlan) (recurringPlanResponse, bool) {
+	holidays, ok := rpc.holidays(c, plan.NextRunDate)
+	if !ok {
+		return recurringPlanResponse{}, false
+	}
+	return recurringPlanResponse{RecurringPlan: plan, Upcoming: utils.UpcomingPlanExecutions(plan, upcomingPlanCount, holidays)}, true
+}
@@ -0,0 +1,420 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"golang/models"
+	"golang/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TransactionController struct {
+	DB *gorm.DB
+}
+
+func NewTransactionController(db *gorm.DB) *TransactionController {
+	return &TransactionController{DB: db}
+}
+
+// transactionInput adalah body POST /transactions. Lihat models.Transaction untuk arti Amount dan Units.
+// Currency opsional; jika diisi harus sama dengan mata uang reksa dana.
+type transactionInput struct {
+	Type           models.TransactionType `json:"type" binding:"required"`
+	PortfolioID    uint                   `json:"portfolio_id"`
+	MutualFundID   uint                   `json:"mutual_fund_id" binding:"required"`
+	Date           time.Time              `json:"date" binding:"required"`
+	SettlementDate *time.Time             `json:"settlement_date"`
+	Currency       string                 `json:"currency"`
+	Amount         float64                `json:"amount"`
+	Units          float64                `json:"units"`
+	Nav            float64                `json:"nav"`
+	Fee            float64                `json:"fee"`
+	CostMethod     string                 `json:"cost_method"`
+	Note           string                 `json:"note"`
+}
+
+func (tc *TransactionController) GetTransactions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	portfolioID, ok := portfolioScope(tc.DB, c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	query := tc.DB.Where("user_id = ? AND deleted_at IS NULL", userID)
+	if portfolioID != 0 {
+		query = query.Where("portfolio_id = ?", portfolioID)
+	}
+	if fundID := c.Query("mutual_fund_id"); fundID != "" {
+		query = query.Where("mutual_fund_id = ?", fundID)
+	}
+	if txType := c.Query("type"); txType != "" {
+		query = query.Where("type = ?", txType)
+	}
+
+	var txs []models.Transaction
+	if err := query.Order("date DESC, id DESC").Find(&txs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, txs)
+}
+
+func (tc *TransactionController) CreateTransaction(c *gin.Context) {
+	var input transactionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	if !models.ValidTransactionType(input.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction type"})
+		return
+	}
+	if input.Amount < 0 || input.Units < 0 || input.Nav < 0 || input.Fee < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount, units, nav and fee must not be negative"})
+		return
+	}
+	if input.Amount == 0 && input.Units == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either amount or units is required"})
+		return
+	}
+
+	var fund models.MutualFund
+	if err := tc.DB.First(&fund, input.MutualFundID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mutual fund not found"})
+		return
+	}
+
+	portfolioID, ok := targetPortfolio(tc.DB, c, userID.(uint), input.PortfolioID)
+	if !ok {
+		return
+	}
+
+	currency := ""
+	if input.Currency != "" {
+		normalized, err := utils.NormalizeCurrency(input.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be a 3-letter currency code"})
+			return
+		}
+		currency = normalized
+	}
+
+	tx := models.Transaction{
+		UserID:       userID.(uint),
+		PortfolioID:  portfolioID,
+		MutualFundID: input.MutualFundID,
+		Type:         input.Type,
+		Date:         utils.DateOnly(input.Date),
+		Currency:     currency,
+		Amount:       input.Amount,
+		Units:        input.Units,
+		Nav:          input.Nav,
+		Fee:          input.Fee,
+		Note:         input.Note,
+	}
+	if input.SettlementDate != nil {
+		settlement := utils.DateOnly(*input.SettlementDate)
+		tx.SettlementDate = &settlement
+	}
+
+	if input.CostMethod != "" {
+		if input.Type != models.TransactionSell && input.Type != models.TransactionSwitchOut {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cost_method only applies to SELL and SWITCH_OUT"})
+			return
+		}
+		if !models.ValidCostMethod(input.CostMethod) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cost_method must be FIFO or AVERAGE"})
+			return
+		}
+		tx.CostMethod = input.CostMethod
+	}
+
+	if err := utils.RecordTransaction(tc.DB, &tx); err != nil {
+		respondLedgerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tx)
+}
+
+// switchInput adalah body POST /transactions/switch. Isi salah satu dari units, amount
+// (nilai bruto di reksa dana asal), atau all=true.
+type switchInput struct {
+	PortfolioID      uint       `json:"portfolio_id"`
+	FromMutualFundID uint       `json:"from_mutual_fund_id" binding:"required"`
+	ToMutualFundID   uint       `json:"to_mutual_fund_id" binding:"required"`
+	Date             time.Time  `json:"date" binding:"required"`
+	SettlementDate   *time.Time `json:"settlement_date"`
+	Units            float64    `json:"units"`
+	Amount           float64    `json:"amount"`
+	All              bool       `json:"all"`
+	CostMethod       string     `json:"cost_method"`
+	Note             string     `json:"note"`
+}
+
+// SwitchFunds memindahkan kepemilikan dari satu reksa dana ke reksa dana lain di dalam satu
+// portfolio (portfolio_id, default portfolio utama). Biaya switching
+// diambil dari SwitchingFeeRate reksa dana asal.
+func (tc *TransactionController) SwitchFunds(c *gin.Context) {
+	var input switchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	if input.Units < 0 || input.Amount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "units and amount must not be negative"})
+		return
+	}
+	if !input.All && input.Units == 0 && input.Amount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "One of units, amount or all is required"})
+		return
+	}
+	if input.SettlementDate != nil && input.SettlementDate.Before(input.Date) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "settlement_date must not be before date"})
+		return
+	}
+	if input.CostMethod != "" && !models.ValidCostMethod(input.CostMethod) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cost_method must be FIFO or AVERAGE"})
+		return
+	}
+
+	portfolioID, ok := targetPortfolio(tc.DB, c, userID.(uint), input.PortfolioID)
+	if !ok {
+		return
+	}
+
+	result, err := utils.SwitchFunds(tc.DB, utils.SwitchRequest{
+		UserID:         userID.(uint),
+		PortfolioID:    portfolioID,
+		FromFundID:     input.FromMutualFundID,
+		ToFundID:       input.ToMutualFundID,
+		Date:           input.Date,
+		SettlementDate: input.SettlementDate,
+		Units:          input.Units,
+		Amount:         input.Amount,
+		All:            input.All,
+		CostMethod:     input.CostMethod,
+		Note:           input.Note,
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mutual fund not found", "details": err.Error()})
+		return
+	case errors.Is(err, utils.ErrSameFund):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, utils.ErrNavNotAvailable):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "NAV of the source fund for the switch date is not available yet"})
+		return
+	case errors.Is(err, utils.ErrInvalidFee):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Switching fee of the source fund cannot be read", "details": err.Error()})
+		return
+	default:
+		respondLedgerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// respondLedgerError memetakan error dari utils.RecordTransaction ke response HTTP
+func respondLedgerError(c *gin.Context, err error) {
+	if errors.Is(err, utils.ErrInsufficientUnits) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient units", "details": err.Error()})
+		return
+	}
+	if errors.Is(err, utils.ErrCurrencyMismatch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Currency does not match the mutual fund", "details": err.Error()})
+		return
+	}
+	if errors.Is(err, utils.ErrFxRateNotAvailable) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "FX rate is not available", "details": err.Error()})
+		return
+	}
+	if errors.Is(err, utils.ErrDistributionRecorded) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Dividend is already recorded from the fund distribution", "details": err.Error()})
+		return
+	}
+	log.Printf("Failed to record transaction: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
+}
+
+// GetHoldings menampilkan kepemilikan per reksa dana yang diturunkan dari ledger, dinilai dengan NAV
+// terakhir yang tersimpan. Query parameter portfolio_id membatasi ke satu portfolio.
+func (tc *TransactionController) GetHoldings(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+	portfolioID, ok := portfolioScope(tc.DB, c, userID.(uint))
+	if !ok {
+		return
+	}
+
+	txs, err := utils.LoadLedger(tc.DB, userID.(uint), portfolioID, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+		return
+	}
+
+	// Kelompokkan ledger per reksa dana dengan urutan kemunculan yang tetap
+	var fundIDs []uint
+	byFund := make(map[uint][]models.Transaction)
+	for _, tx := range txs {
+		if _, ok := byFund[tx.MutualFundID]; !ok {
+			fundIDs = append(fundIDs, tx.MutualFundID)
+		}
+		byFund[tx.MutualFundID] = append(byFund[tx.MutualFundID], tx)
+	}
+
+	holdings := make([]utils.Holding, 0, len(fundIDs))
+	for _, fundID := range fundIDs {
+		latest, err := utils.LatestStoredNav(tc.DB, fundID)
+		if err != nil && !errors.Is(err, utils.ErrNavNotAvailable) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NAV data"})
+			return
+		}
+		holdings = append(holdings, utils.BuildHolding(fundID, byFund[fundID], latest))
+	}
+
+	c.JSON(http.StatusOK, holdings)
+}
+
+// maxImportFileSize membatasi ukuran file CSV yang diunggah ke /transactions/import
+const maxImportFileSize = 5 << 20
+
+// GetImportSources menampilkan sumber impor CSV yang dikenali beserta mapping kolomnya
+func (tc *TransactionController) GetImportSources(c *gin.Context) {
+	c.JSON(http.StatusOK, utils.ImportSources())
+}
+
+// ImportTransactions menangani POST /transactions/import (multipart/form-data):
+//   - file: file CSV ekspor dari sumber
+//   - source: nama mapping kolom (lihat GET /transactions/import/sources)
+//   - portfolio_id: portfolio tujuan, default portfolio utama
+//   - fund_map: JSON {"<key>": <mutual_fund_id>} untuk mengonfirmasi reksa dana yang ambiguous/unmatched
+//   - dry_run: default true; hanya menampilkan pratinjau (duplikat, baris yang belum cocok)
+//
+// Dengan dry_run=false semua baris ready disimpan dalam satu transaksi database; duplikat dilewati.
+func (tc *TransactionController) ImportTransactions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(400, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	mapping, err := utils.GetImportMapping(c.PostForm("source"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import source", "detail": err.Error()})
+		return
+	}
+
+	var requested uint64
+	if v := c.PostForm("portfolio_id"); v != "" {
+		requested, err = strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid portfolio_id"})
+			return
+		}
+	}
+	portfolioID, ok := targetPortfolio(tc.DB, c, userID.(uint), uint(requested))
+	if !ok {
+		return
+	}
+
+	confirmed := map[string]uint{}
+	if v := c.PostForm("fund_map"); v != "" {
+		if err := json.Unmarshal([]byte(v), &confirmed); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fund_map must be a JSON object of key to mutual fund ID"})
+			return
+		}
+	}
+	dryRun := true
+	if v := c.PostForm("dry_run"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+			return
+		}
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if header.Size > maxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	rows, err := utils.ParseImportCSV(file, mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV file", "detail": err.Error()})
+		return
+	}
+	preview, err := utils.PreviewImport(tc.DB, userID.(uint), portfolioID, mapping.Source, rows, confirmed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to preview import"})
+		return
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":      true,
+			"portfolio_id": portfolioID,
+			"can_commit":   preview.CanCommit(),
+			"preview":      preview,
+		})
+		return
+	}
+
+	record, err := utils.CommitImport(tc.DB, userID.(uint), portfolioID, header.Filename, preview)
+	switch {
+	case err == nil:
+	case errors.Is(err, utils.ErrImportNotReady):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "preview": preview})
+		return
+	default:
+		respondLedgerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"import":  record,
+		"preview": preview,
+	})
+}
@@ -0,0 +1,245 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"golang/models"
+	"golang/utils"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrBackfillRunning dikembalikan jika backfill untuk reksa dana yang sama masih berjalan
+var ErrBackfillRunning = errors.New("nav backfill for this fund is already running")
+
+// NavBackfillOptions mengatur backfill riwayat NAV satu reksa dana
+type NavBackfillOptions struct {
+	// Tanggal awal; jika kosong memakai mutual_funds.inception_date atau checkpoint sebelumnya
+	InceptionDate *time.Time
+	// Panjang satu potongan rentang tanggal yang diminta ke provider
+	ChunkDays int
+	// Abaikan checkpoint dan mulai lagi dari tanggal awal
+	Restart bool
+}
+
+// navTolerance adalah selisih NAV yang masih dianggap sama
+const navTolerance = 1e-6
+
+var (
+	backfillMu      sync.Mutex
+	backfillRunning = map[uint]bool{}
+)
+
+// RunNavBackfill menjalankan backfill secara sinkron sampai selesai atau gagal
+func RunNavBackfill(db *gorm.DB, fundID uint, opts NavBackfillOptions) (*models.BackfillCheckpoint, error) {
+	fund, checkpoint, err := beginNavBackfill(db, fundID, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = executeNavBackfill(db, fund, checkpoint, opts)
+	return checkpoint, err
+}
+
+// StartNavBackfill menyiapkan checkpoint lalu menjalankan backfill di background
+func StartNavBackfill(db *gorm.DB, fundID uint, opts NavBackfillOptions) (*models.BackfillCheckpoint, error) {
+	fund, checkpoint, err := beginNavBackfill(db, fundID, opts)
+	if err != nil {
+		return nil, err
+	}
+	started := *checkpoint
+	go func() {
+		if err := executeNavBackfill(db, fund, checkpoint, opts); err != nil {
+			log.Printf("NAV backfill fund %d failed: %v", fundID, err)
+		}
+	}()
+	return &started, nil
+}
+
+func beginNavBackfill(db *gorm.DB, fundID uint, opts NavBackfillOptions) (models.MutualFund, *models.BackfillCheckpoint, error) {
+	var fund models.MutualFund
+	if err := db.First(&fund, fundID).Error; err != nil {
+		return fund, nil, fmt.Errorf("mutual fund not found: %w", err)
+	}
+
+	backfillMu.Lock()
+	if backfillRunning[fundID] {
+		backfillMu.Unlock()
+		return fund, nil, ErrBackfillRunning
+	}
+	backfillRunning[fundID] = true
+	backfillMu.Unlock()
+
+	checkpoint, err := loadBackfillCheckpoint(db, &fund, opts)
+	if err != nil {
+		releaseNavBackfill(fundID)
+		return fund, nil, err
+	}
+	return fund, checkpoint, nil
+}
+
+func releaseNavBackfill(fundID uint) {
+	backfillMu.Lock()
+	defer backfillMu.Unlock()
+	delete(backfillRunning, fundID)
+}
+
+// loadBackfillCheckpoint membuat checkpoint baru atau melanjutkan yang lama
+func loadBackfillCheckpoint(db *gorm.DB, fund *models.MutualFund, opts NavBackfillOptions) (*models.BackfillCheckpoint, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var checkpoint models.BackfillCheckpoint
+	err := db.Where("mutual_fund_id = ?", fund.ID).First(&checkpoint).Error
+	exists := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+
+	inception := opts.InceptionDate
+	if inception == nil {
+		inception = fund.InceptionDate
+	}
+	if inception == nil && exists {
+		inception = &checkpoint.InceptionDate
+	}
+	if inception == nil {
+		return nil, fmt.Errorf("inception date of mutual fund %d is unknown", fund.ID)
+	}
+
+	// Simpan tanggal peluncuran ke data reksa dana jika sebelumnya belum ada
+	if fund.InceptionDate == nil {
+		if err := db.Model(fund).Update("inception_date", *inception).Error; err != nil {
+			return nil, fmt.Errorf("failed to save inception date: %w", err)
+		}
+		fund.InceptionDate = inception
+	}
+
+	if !exists || opts.Restart || !inception.Equal(checkpoint.InceptionDate) {
+		checkpoint = models.BackfillCheckpoint{
+			ID:            checkpoint.ID,
+			MutualFundID:  fund.ID,
+			InceptionDate: *inception,
+			NextDate:      *inception,
+		}
+	}
+	checkpoint.EndDate = today
+	checkpoint.Status = models.BackfillStatusRunning
+	checkpoint.LastError = ""
+
+	if err := db.Save(&checkpoint).Error; err != nil {
+		return nil, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return &checkpoint, nil
+}
+
+func executeNavBackfill(db *gorm.DB, fund models.MutualFund, checkpoint *models.BackfillCheckpoint, opts NavBackfillOptions) error {
+	defer releaseNavBackfill(fund.ID)
+
+	chunkDays := opts.ChunkDays
+	if chunkDays < 1 {
+		chunkDays = 90
+	}
+
+	provider, err := utils.NavProviderFor(fund)
+	if err != nil {
+		return failNavBackfill(db, checkpoint, err)
+	}
+
+	for !checkpoint.NextDate.After(checkpoint.EndDate) {
+		chunkEnd := checkpoint.NextDate.AddDate(0, 0, chunkDays-1)
+		if chunkEnd.After(checkpoint.EndDate) {
+			chunkEnd = checkpoint.EndDate
+		}
+
+		points, err := provider.FetchNav(fund, checkpoint.NextDate, chunkEnd)
+		if err != nil {
+			return failNavBackfill(db, checkpoint, err)
+		}
+
+		filled, skipped, inconsistent, err := storeBackfillChunk(db, fund.ID, provider.Name(), checkpoint.NextDate, chunkEnd, points)
+		if err != nil {
+			return failNavBackfill(db, checkpoint, err)
+		}
+
+		checkpoint.Filled += filled
+		checkpoint.Skipped += skipped
+		checkpoint.Inconsistent += inconsistent
+		checkpoint.NextDate = chunkEnd.AddDate(0, 0, 1)
+		if err := db.Save(checkpoint).Error; err != nil {
+			return fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+	}
+
+	checkpoint.Status = models.BackfillStatusCompleted
+	if err := db.Save(checkpoint).Error; err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	log.Printf("NAV backfill fund %d completed: %d filled, %d skipped, %d inconsistent",
+		fund.ID, checkpoint.Filled, checkpoint.Skipped, checkpoint.Inconsistent)
+	return nil
+}
+
+// storeBackfillChunk membandingkan NAV dari provider dengan yang sudah tersimpan lalu
+// menyimpan tanggal yang belum ada. NAV yang sudah ada tidak ditimpa.
+func storeBackfillChunk(db *gorm.DB, fundID uint, source string, start, end time.Time, points []utils.NavPoint) (filled, skipped, inconsistent int, err error) {
+	var existing []models.NavPrice
+	if err := db.Where("mutual_fund_id = ? AND date BETWEEN ? AND ?", fundID, start, end).Find(&existing).Error; err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to read stored NAV: %w", err)
+	}
+
+	stored := make(map[string]float64, len(existing))
+	for _, nav := range existing {
+		stored[nav.Date.Format("2006-01-02")] = nav.Nav
+	}
+
+	now := time.Now()
+	var navs []models.NavPrice
+	for _, p := range points {
+		val, ok := stored[p.Date.Format("2006-01-02")]
+		switch {
+		case !ok:
+			navs = append(navs, models.NavPrice{
+				MutualFundID: fundID,
+				Date:         p.Date,
+				Nav:          p.Nav,
+				Source:       source,
+				FetchedAt:    now,
+			})
+		case math.Abs(val-p.Nav) <= navTolerance:
+			skipped++
+		default:
+			inconsistent++
+			log.Printf("NAV backfill fund %d: stored NAV %f on %s differs from provider %f", fundID, val, p.Date.Format("2006-01-02"), p.Nav)
+		}
+	}
+
+	if len(navs) > 0 {
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&navs)
+		if result.Error != nil {
+			return 0, 0, 0, fmt.Errorf("failed to store NAV: %w", result.Error)
+		}
+		filled = int(result.RowsAffected)
+		skipped += len(navs) - filled
+		if filled > 0 {
+			utils.InvalidateNavCache(fundID)
+			utils.QueueFundValuationRefresh(db, fundID, navs[0].Date, false)
+		}
+	}
+
+	return filled, skipped, inconsistent, nil
+}
+
+func failNavBackfill(db *gorm.DB, checkpoint *models.BackfillCheckpoint, cause error) error {
+	checkpoint.Status = models.BackfillStatusFailed
+	checkpoint.LastError = cause.Error()
+	if err := db.Save(checkpoint).Error; err != nil {
+		log.Printf("NAV backfill fund %d: failed to save checkpoint: %v", checkpoint.MutualFundID, err)
+	}
+	return cause
+}
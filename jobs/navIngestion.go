@@ -0,0 +1,233 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"golang/models"
+	"golang/utils"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrIngestionRunning dikembalikan jika masih ada run lain yang berjalan (di instance mana pun)
+var ErrIngestionRunning = errors.New("nav ingestion is already running")
+
+const (
+	// ingestionLockKey adalah kunci advisory lock Postgres untuk mengklaim run ingestion
+	ingestionLockKey = 7301
+	// staleIngestionAfter: run "running" tanpa heartbeat selama ini dianggap mati (proses crash)
+	staleIngestionAfter = 30 * time.Minute
+)
+
+// NavIngestionOptions mengatur jalannya ingestion NAV harian
+type NavIngestionOptions struct {
+	// Jumlah reksa dana yang diproses bersamaan
+	Concurrency int
+	// Jumlah percobaan per reksa dana sebelum dianggap gagal
+	MaxAttempts int
+	// Jeda dasar antar percobaan, dikalikan nomor percobaan
+	RetryDelay time.Duration
+	// Berapa hari ke belakang yang diambil ulang, supaya NAV yang terlambat terbit tetap masuk
+	LookbackDays int
+}
+
+// DefaultNavIngestionOptions membaca NAV_INGEST_CONCURRENCY, NAV_INGEST_MAX_ATTEMPTS dan NAV_INGEST_LOOKBACK_DAYS
+func DefaultNavIngestionOptions() NavIngestionOptions {
+	return NavIngestionOptions{
+		Concurrency:  envInt("NAV_INGEST_CONCURRENCY", 4),
+		MaxAttempts:  envInt("NAV_INGEST_MAX_ATTEMPTS", 3),
+		RetryDelay:   5 * time.Second,
+		LookbackDays: envInt("NAV_INGEST_LOOKBACK_DAYS", 7),
+	}
+}
+
+// RunNavIngestion menjalankan satu putaran ingestion secara sinkron dan mengembalikan hasilnya
+func RunNavIngestion(db *gorm.DB, trigger string, opts NavIngestionOptions) (*models.IngestionRun, error) {
+	run, err := beginNavIngestion(db, trigger)
+	if err != nil {
+		return nil, err
+	}
+	executeNavIngestion(db, run, opts)
+	return run, nil
+}
+
+// StartNavIngestion membuat run baru lalu menjalankannya di background
+func StartNavIngestion(db *gorm.DB, trigger string, opts NavIngestionOptions) (*models.IngestionRun, error) {
+	run, err := beginNavIngestion(db, trigger)
+	if err != nil {
+		return nil, err
+	}
+	started := *run
+	go executeNavIngestion(db, run, opts)
+	return &started, nil
+}
+
+// beginNavIngestion mengklaim run baru secara atomik di database, sehingga hanya satu ingestion
+// berjalan di semua instance. Run lama yang heartbeat-nya berhenti ditandai gagal dulu.
+func beginNavIngestion(db *gorm.DB, trigger string) (*models.IngestionRun, error) {
+	now := time.Now()
+	run := &models.IngestionRun{
+		Trigger:     trigger,
+		Status:      models.IngestionStatusRunning,
+		StartedAt:   now,
+		HeartbeatAt: now,
+	}
+	err := db.Transaction(func(dbtx *gorm.DB) error {
+		if err := dbtx.Exec("SELECT pg_advisory_xact_lock(?)", ingestionLockKey).Error; err != nil {
+			return err
+		}
+		if err := failStaleIngestionRuns(dbtx, now); err != nil {
+			return err
+		}
+		var running int64
+		if err := dbtx.Model(&models.IngestionRun{}).Where("status = ?", models.IngestionStatusRunning).Count(&running).Error; err != nil {
+			return err
+		}
+		if running > 0 {
+			return ErrIngestionRunning
+		}
+		return dbtx.Create(run).Error
+	})
+	if errors.Is(err, ErrIngestionRunning) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ingestion run: %w", err)
+	}
+	return run, nil
+}
+
+// FailStaleIngestionRuns menandai gagal run "running" yang ditinggalkan proses yang crash, dipanggil saat startup
+func FailStaleIngestionRuns(db *gorm.DB) error {
+	return failStaleIngestionRuns(db, time.Now())
+}
+
+func failStaleIngestionRuns(db *gorm.DB, now time.Time) error {
+	result := db.Model(&models.IngestionRun{}).
+		Where("status = ? AND heartbeat_at < ?", models.IngestionStatusRunning, now.Add(-staleIngestionAfter)).
+		Updates(map[string]interface{}{"status": models.IngestionStatusFailed, "finished_at": now})
+	if result.RowsAffected > 0 {
+		log.Printf("NAV ingestion: marked %d stale run(s) as failed", result.RowsAffected)
+	}
+	return result.Error
+}
+
+func executeNavIngestion(db *gorm.DB, run *models.IngestionRun, opts NavIngestionOptions) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+
+	var funds []models.MutualFund
+	if err := db.Order("id ASC").Find(&funds).Error; err != nil {
+		log.Printf("NAV ingestion #%d: failed to load mutual funds: %v", run.ID, err)
+		finishNavIngestion(db, run, err)
+		return
+	}
+	run.TotalFunds = len(funds)
+
+	end := time.Now()
+	start := end.AddDate(0, 0, -opts.LookbackDays)
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, opts.Concurrency)
+	)
+
+	for _, fund := range funds {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(fund models.MutualFund) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			item := ingestFund(db, fund, start, end, opts)
+			item.RunID = run.ID
+			if err := db.Create(&item).Error; err != nil {
+				log.Printf("NAV ingestion #%d: failed to save result for fund %d: %v", run.ID, fund.ID, err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if item.Status == models.IngestionStatusSuccess {
+				run.Succeeded++
+			} else {
+				run.Failed++
+			}
+			run.HeartbeatAt = time.Now()
+			if err := db.Model(run).Update("heartbeat_at", run.HeartbeatAt).Error; err != nil {
+				log.Printf("NAV ingestion #%d: failed to update heartbeat: %v", run.ID, err)
+			}
+		}(fund)
+	}
+	wg.Wait()
+
+	finishNavIngestion(db, run, nil)
+	log.Printf("NAV ingestion #%d finished: %d/%d succeeded in %dms", run.ID, run.Succeeded, run.TotalFunds, run.DurationMs)
+}
+
+// ingestFund mengambil NAV terbaru satu reksa dana dengan retry
+func ingestFund(db *gorm.DB, fund models.MutualFund, start, end time.Time, opts NavIngestionOptions) models.IngestionRunItem {
+	began := time.Now()
+	item := models.IngestionRunItem{MutualFundID: fund.ID}
+
+	var lastErr error
+	for attempt := 1; attempt <= opts.MaxAttempts; attempt++ {
+		item.Attempts = attempt
+		navs, err := utils.SyncNav(db, fund, start, end)
+		if err == nil {
+			item.Status = models.IngestionStatusSuccess
+			item.NavCount = len(navs)
+			lastErr = nil
+			break
+		}
+		lastErr = err
+		log.Printf("NAV ingestion: fund %d attempt %d failed: %v", fund.ID, attempt, err)
+		if attempt < opts.MaxAttempts {
+			time.Sleep(time.Duration(attempt) * opts.RetryDelay)
+		}
+	}
+
+	if lastErr != nil {
+		item.Status = models.IngestionStatusFailed
+		item.Error = lastErr.Error()
+	}
+	item.DurationMs = time.Since(began).Milliseconds()
+	return item
+}
+
+func finishNavIngestion(db *gorm.DB, run *models.IngestionRun, runErr error) {
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.DurationMs = finished.Sub(run.StartedAt).Milliseconds()
+
+	switch {
+	case runErr != nil:
+		run.Status = models.IngestionStatusFailed
+	case run.Failed == 0:
+		run.Status = models.IngestionStatusSuccess
+	case run.Succeeded == 0:
+		run.Status = models.IngestionStatusFailed
+	default:
+		run.Status = models.IngestionStatusPartial
+	}
+
+	if err := db.Model(run).Select("status", "finished_at", "duration_ms", "total_funds", "succeeded", "failed").Updates(run).Error; err != nil {
+		log.Printf("NAV ingestion #%d: failed to update run: %v", run.ID, err)
+	}
+}
+
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
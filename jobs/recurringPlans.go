@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"golang/models"
+	"golang/utils"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// recurringPlanInterval adalah jeda antar pemeriksaan rencana investasi berkala yang jatuh tempo
+const recurringPlanInterval = time.Hour
+
+const (
+	// maxPlanFailures adalah jumlah kegagalan berturut-turut sebelum rencana dijeda
+	maxPlanFailures = 5
+	// maxPlanRetryDelay membatasi jeda percobaan ulang rencana yang gagal
+	maxPlanRetryDelay = 24 * time.Hour
+)
+
+// RunRecurringPlans mengeksekusi semua rencana aktif yang jatuh tempo sampai today (tanggal di
+// zona waktu loc). Rencana yang gagal dicoba lagi dengan jeda yang terus berlipat (lihat
+// recordPlanFailure) dan dijeda setelah maxPlanFailures kali gagal berturut-turut.
+func RunRecurringPlans(db *gorm.DB, now time.Time, loc *time.Location) (int, error) {
+	today := utils.LocalDate(now, loc)
+
+	// NextRunDate adalah tanggal sebelum digeser ke hari bursa, jadi cukup ambil yang <= today
+	var plans []models.RecurringPlan
+	if err := db.Where("status = ? AND next_run_date <= ? AND (retry_at IS NULL OR retry_at <= ?)", models.PlanStatusActive, today, now).
+		Order("id ASC").Find(&plans).Error; err != nil {
+		return 0, err
+	}
+
+	executed := 0
+	for i := range plans {
+		plan := &plans[i]
+		executions, err := utils.ExecuteDuePlan(db, plan, today)
+		executed += len(executions)
+		if err != nil {
+			log.Printf("Recurring plan %d: execution failed: %v", plan.ID, err)
+			if err := recordPlanFailure(db, plan, now, err); err != nil {
+				log.Printf("Recurring plan %d: failed to record failure: %v", plan.ID, err)
+			}
+			continue
+		}
+		if plan.FailureCount > 0 {
+			if err := db.Model(plan).Select("failure_count", "retry_at", "last_error").
+				Updates(map[string]interface{}{"failure_count": 0, "retry_at": nil, "last_error": ""}).Error; err != nil {
+				log.Printf("Recurring plan %d: failed to reset failures: %v", plan.ID, err)
+			}
+		}
+	}
+	return executed, nil
+}
+
+// recordPlanFailure mencatat kegagalan eksekusi rencana. Percobaan berikutnya ditunda
+// recurringPlanInterval x 2^(kegagalan-1), paling lama maxPlanRetryDelay; setelah maxPlanFailures
+// kali gagal rencana dijeda sampai user melanjutkannya.
+func recordPlanFailure(db *gorm.DB, plan *models.RecurringPlan, now time.Time, cause error) error {
+	failures := plan.FailureCount + 1
+
+	updates := map[string]interface{}{"failure_count": failures, "last_error": cause.Error()}
+	if failures >= maxPlanFailures {
+		updates["status"] = models.PlanStatusPaused
+		updates["retry_at"] = nil
+		log.Printf("Recurring plan %d: paused after %d consecutive failures", plan.ID, failures)
+	} else {
+		delay := recurringPlanInterval << (failures - 1)
+		if delay > maxPlanRetryDelay {
+			delay = maxPlanRetryDelay
+		}
+		updates["retry_at"] = now.Add(delay)
+	}
+	return db.Model(&models.RecurringPlan{}).Where("id = ?", plan.ID).Updates(updates).Error
+}
+
+// StartRecurringPlanScheduler memeriksa rencana investasi berkala saat start dan setiap jam sampai ctx dibatalkan
+func StartRecurringPlanScheduler(ctx context.Context, db *gorm.DB, loc *time.Location) {
+	go func() {
+		ticker := time.NewTicker(recurringPlanInterval)
+		defer ticker.Stop()
+		for {
+			if n, err := RunRecurringPlans(db, time.Now(), loc); err != nil {
+				log.Printf("Recurring plan scheduler: %v", err)
+			} else if n > 0 {
+				log.Printf("Recurring plan scheduler: created %d purchases", n)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
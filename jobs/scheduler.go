@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"golang/utils"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TriggerScheduler = "scheduler"
+	TriggerManual    = "manual"
+	TriggerCLI       = "cli"
+)
+
+// StartNavScheduler menjalankan ingestion harian di background sampai ctx dibatalkan
+func StartNavScheduler(ctx context.Context, db *gorm.DB, schedule utils.NavSchedule, opts NavIngestionOptions) {
+	go func() {
+		for {
+			next := schedule.Next(time.Now())
+			log.Printf("NAV scheduler: next ingestion at %s", next.Format(time.RFC3339))
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if _, err := RunNavIngestion(db, TriggerScheduler, opts); err != nil {
+				if errors.Is(err, ErrIngestionRunning) {
+					log.Printf("NAV scheduler: skipped, previous run still in progress")
+					continue
+				}
+				log.Printf("NAV scheduler: ingestion failed: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"golang/utils"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// valuationRefreshInterval adalah jeda antar pemeriksaan antrean perhitungan ulang portfolio_valuations
+const valuationRefreshInterval = 15 * time.Second
+
+// StartValuationRefreshWorker mengerjakan antrean valuation_refreshes (diisi saat NAV atau distribusi
+// sebuah reksa dana ditulis) saat start dan setiap valuationRefreshInterval sampai ctx dibatalkan
+func StartValuationRefreshWorker(ctx context.Context, db *gorm.DB) {
+	go func() {
+		ticker := time.NewTicker(valuationRefreshInterval)
+		defer ticker.Stop()
+		for {
+			if n, err := utils.ProcessValuationRefreshes(db); err != nil {
+				log.Printf("Valuation refresh worker: %v", err)
+			} else if n > 0 {
+				log.Printf("Valuation refresh worker: refreshed %d funds", n)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"golang/jobs"
 	"golang/routes"
+	"golang/utils"
 	"log"
 	"os"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -15,12 +22,68 @@ func main() {
 		log.Println("Warning: .env file not found, skip loading")
 	}
 
+	utils.RegisterNavProvidersFromEnv()
+	utils.RegisterImportMappingsFromEnv()
+	utils.RegisterFxProviderFromEnv()
+	db := routes.ConnectDatabase()
+
+	// Run ingestion yang ditinggalkan proses sebelumnya (crash) ditandai gagal
+	if err := jobs.FailStaleIngestionRuns(db); err != nil {
+		log.Printf("Failed to clean up stale ingestion runs: %v", err)
+	}
+
+	// Subcommand: `./main ingest` menjalankan satu kali ingestion NAV lalu keluar,
+	// `./main backfill -fund <id> [-from YYYY-MM-DD] [-restart]` mengisi riwayat NAV sebuah reksa dana
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "ingest":
+			run, err := jobs.RunNavIngestion(db, jobs.TriggerCLI, jobs.DefaultNavIngestionOptions())
+			if err != nil {
+				log.Fatal("Ingestion failed: ", err)
+			}
+			log.Printf("Ingestion #%d %s: %d succeeded, %d failed", run.ID, run.Status, run.Succeeded, run.Failed)
+			if _, err := utils.ProcessValuationRefreshes(db); err != nil {
+				log.Printf("Failed to refresh portfolio valuations: %v", err)
+			}
+			if run.Failed > 0 {
+				os.Exit(1)
+			}
+			return
+		case "backfill":
+			runBackfillCommand(db, os.Args[2:])
+			return
+		default:
+			log.Fatalf("Unknown command %q", os.Args[1])
+		}
+	}
+
+	// Jalankan scheduler ingestion NAV harian di dalam server (nonaktifkan dengan NAV_SCHEDULER_ENABLED=false)
+	if os.Getenv("NAV_SCHEDULER_ENABLED") != "false" {
+		schedule, err := utils.DefaultNavSchedule()
+		if err != nil {
+			log.Fatal("Invalid NAV schedule: ", err)
+		}
+		jobs.StartNavScheduler(context.Background(), db, schedule, jobs.DefaultNavIngestionOptions())
+	}
+
+	// Perhitungan ulang portfolio_valuations setelah NAV atau distribusi berubah
+	jobs.StartValuationRefreshWorker(context.Background(), db)
+
+	// Eksekusi rencana investasi berkala (nonaktifkan dengan RECURRING_PLANS_ENABLED=false)
+	if os.Getenv("RECURRING_PLANS_ENABLED") != "false" {
+		loc, err := utils.PlanLocation()
+		if err != nil {
+			log.Fatal("Failed to load time zone: ", err)
+		}
+		jobs.StartRecurringPlanScheduler(context.Background(), db, loc)
+	}
+
 	// Set Gin debug mode (harus sebelum SetupRouter)
 	os.Setenv("GIN_MODE", "debug")
 	gin.SetMode(gin.DebugMode)
 
 	// Setup router with debug + recovery middleware
-	router := routes.SetupRouter()
+	router := routes.SetupRouter(db)
 	// router.Use(routes.RecoveryWithDebug()) // Tambahkan recovery custom
 
 	// Run server
@@ -28,3 +91,35 @@ func main() {
 		log.Fatal("Failed to start server: ", err)
 	}
 }
+
+func runBackfillCommand(db *gorm.DB, args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fundID := fs.Uint("fund", 0, "mutual fund ID")
+	from := fs.String("from", "", "inception date (YYYY-MM-DD), defaults to the stored inception date")
+	chunkDays := fs.Int("chunk-days", 90, "days per upstream request")
+	restart := fs.Bool("restart", false, "ignore the saved checkpoint and start over")
+	fs.Parse(args)
+
+	if *fundID == 0 {
+		log.Fatal("backfill: -fund is required")
+	}
+
+	opts := jobs.NavBackfillOptions{ChunkDays: *chunkDays, Restart: *restart}
+	if *from != "" {
+		inception, err := time.Parse("2006-01-02", *from)
+		if err != nil {
+			log.Fatal("backfill: invalid -from date: ", err)
+		}
+		opts.InceptionDate = &inception
+	}
+
+	checkpoint, err := jobs.RunNavBackfill(db, uint(*fundID), opts)
+	if err != nil {
+		log.Fatal("Backfill failed: ", err)
+	}
+	log.Printf("Backfill fund %d %s: %d filled, %d skipped, %d inconsistent",
+		checkpoint.MutualFundID, checkpoint.Status, checkpoint.Filled, checkpoint.Skipped, checkpoint.Inconsistent)
+	if _, err := utils.ProcessValuationRefreshes(db); err != nil {
+		log.Printf("Failed to refresh portfolio valuations: %v", err)
+	}
+}
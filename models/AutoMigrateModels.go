@@ -3,10 +3,34 @@ package models
 import "gorm.io/gorm"
 
 func AutoMigrateModels(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		// &User{},
-		// &MutualFund{},
-		// &MyPortfolio{},
+		&MutualFund{},
+		&Portfolio{},
+		&MyPortfolio{},
+		&NavPrice{},
+		&NavFetch{},
+		&FundDistribution{},
+		&FxRate{},
+		&FxFetch{},
+		&IngestionRun{},
+		&IngestionRunItem{},
+		&BackfillCheckpoint{},
+		&TransactionImport{},
+		&Transaction{},
+		&LotMatch{},
+		&PortfolioValuation{},
+		&ValuationRefresh{},
+		&MarketHoliday{},
+		&RecurringPlan{},
+		&RecurringPlanExecution{},
+		&Goal{},
+		&GoalHolding{},
 		// Tambahkan model lain di sini kalau ada
-	)
+	); err != nil {
+		return err
+	}
+
+	// Reksa dana lama hanya punya PID Bareksa, salin ke external_id
+	return db.Exec(`UPDATE mutual_funds SET external_id = p_id::text WHERE external_id = '' AND nav_provider = ? AND p_id <> 0`, NavProviderBareksa).Error
 }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+const (
+	BackfillStatusRunning   = "running"
+	BackfillStatusCompleted = "completed"
+	BackfillStatusFailed    = "failed"
+)
+
+// BackfillCheckpoint menyimpan progres backfill riwayat NAV per reksa dana,
+// sehingga backfill yang terputus bisa dilanjutkan dari NextDate.
+type BackfillCheckpoint struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	MutualFundID  uint      `gorm:"not null;uniqueIndex" json:"mutual_fund_id"`
+	InceptionDate time.Time `gorm:"type:date;not null" json:"inception_date"`
+	EndDate       time.Time `gorm:"type:date;not null" json:"end_date"`
+	NextDate      time.Time `gorm:"type:date;not null" json:"next_date"`
+	Status        string    `gorm:"type:varchar(20);not null" json:"status"`
+	// Filled: tanggal baru yang tersimpan, Skipped: sudah ada dengan nilai sama,
+	// Inconsistent: sudah ada tapi nilainya berbeda dengan provider (nilai lama dipertahankan)
+	Filled       int       `json:"filled"`
+	Skipped      int       `json:"skipped"`
+	Inconsistent int       `json:"inconsistent"`
+	LastError    string    `json:"last_error,omitempty"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
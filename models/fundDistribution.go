@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+const (
+	// Dividen dibayarkan tunai ke pemegang unit
+	DistributionPayoutCash = "CASH"
+	// Dividen diinvestasikan ulang menjadi unit baru
+	DistributionPayoutReinvest = "REINVEST"
+
+	DistributionSourceAdmin = "admin"
+)
+
+// FundDistribution adalah pembagian dividen sebuah reksa dana. Pemegang unit sebelum ExDate
+// menerima AmountPerUnit rupiah per unit; pada ExDate NAV turun sebesar nilai tersebut.
+// Setiap distribusi menghasilkan transaksi DIVIDEND di ledger pemegangnya (lihat
+// utils.SyncDistributionTransactions).
+type FundDistribution struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	MutualFundID uint      `gorm:"not null;uniqueIndex:idx_fund_distributions_fund_ex_date,priority:1" json:"mutual_fund_id"`
+	ExDate       time.Time `gorm:"type:date;not null;uniqueIndex:idx_fund_distributions_fund_ex_date,priority:2" json:"ex_date"`
+	// Tanggal pembayaran; dividen yang diinvestasikan ulang memakai NAV tanggal ini (default ExDate)
+	PayDate       *time.Time `gorm:"type:date" json:"pay_date,omitempty"`
+	AmountPerUnit float64    `gorm:"not null" json:"amount_per_unit"`
+	PayoutType    string     `gorm:"type:varchar(10);not null;default:'CASH'" json:"payout_type"`
+	// admin untuk input manual, atau nama provider NAV
+	Source    string    `gorm:"type:varchar(32);not null;default:'admin'" json:"source"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ValidPayoutType mengecek apakah t salah satu jenis pembayaran dividen yang dikenal
+func ValidPayoutType(t string) bool {
+	return t == DistributionPayoutCash || t == DistributionPayoutReinvest
+}
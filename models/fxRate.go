@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// DefaultCurrency adalah mata uang reksa dana dan portfolio yang tidak menyebutkan mata uangnya
+const DefaultCurrency = "IDR"
+
+// FxRate adalah kurs satu mata uang terhadap rupiah pada satu tanggal: 1 Currency = Rate IDR.
+// Konversi antar dua mata uang lain dihitung lewat rupiah (lihat utils.FxConverter).
+type FxRate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Currency  string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_fx_rates_currency_date" json:"currency"`
+	Date      time.Time `gorm:"type:date;not null;uniqueIndex:idx_fx_rates_currency_date" json:"date"`
+	Rate      float64   `gorm:"not null" json:"rate"`
+	Source    string    `gorm:"type:varchar(32);not null" json:"source"`
+	FetchedAt time.Time `gorm:"not null" json:"fetched_at"`
+}
+
+// FxFetch mencatat rentang tanggal yang sudah diminta ke provider kurs untuk satu mata uang. Hari kerja
+// tanpa kurs di rentang ini (libur) tidak diminta ulang setelah harinya lewat; kurs terakhir yang
+// tersimpan dipakai untuk tanggal tersebut.
+type FxFetch struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Currency  string    `gorm:"type:varchar(3);not null;index" json:"currency"`
+	StartDate time.Time `gorm:"type:date;not null" json:"start_date"`
+	EndDate   time.Time `gorm:"type:date;not null" json:"end_date"`
+	FetchedAt time.Time `gorm:"not null" json:"fetched_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Goal adalah target keuangan user, misalnya "Rumah 2030: Rp 500 juta", yang dikaitkan dengan
+// kepemilikan reksa dana dan setoran bulanan rencananya. TargetAmount dalam mata uang Currency.
+type Goal struct {
+	ID           uint          `gorm:"primaryKey" json:"id"`
+	UserID       uint          `gorm:"not null;index" json:"user_id"`
+	Name         string        `gorm:"not null" json:"name"`
+	TargetAmount float64       `gorm:"not null" json:"target_amount"`
+	Currency     string        `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	TargetDate   time.Time     `gorm:"type:date;not null" json:"target_date"`
+	Holdings     []GoalHolding `gorm:"constraint:OnDelete:CASCADE" json:"holdings"`
+	CreatedAt    time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// GoalHolding mengaitkan satu reksa dana milik user ke sebuah goal. Nilai kepemilikan saat ini
+// dihitung dari ledger; MonthlyContribution adalah rencana setoran tambahan per bulan dalam mata uang
+// reksa dananya.
+type GoalHolding struct {
+	ID                  uint    `gorm:"primaryKey" json:"id"`
+	GoalID              uint    `gorm:"not null;uniqueIndex:idx_goal_holdings_goal_fund,priority:1" json:"goal_id"`
+	MutualFundID        uint    `gorm:"not null;uniqueIndex:idx_goal_holdings_goal_fund,priority:2" json:"mutual_fund_id"`
+	MonthlyContribution float64 `gorm:"not null;default:0" json:"monthly_contribution"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+const (
+	IngestionStatusRunning = "running"
+	IngestionStatusSuccess = "success"
+	IngestionStatusPartial = "partial"
+	IngestionStatusFailed  = "failed"
+)
+
+// IngestionRun mencatat satu putaran pengambilan NAV terbaru untuk semua reksa dana
+type IngestionRun struct {
+	ID         uint               `gorm:"primaryKey" json:"id"`
+	Trigger    string             `gorm:"type:varchar(20);not null" json:"trigger"`
+	Status     string             `gorm:"type:varchar(20);not null;index" json:"status"`
+	StartedAt  time.Time          `gorm:"not null;index" json:"started_at"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+	DurationMs int64              `json:"duration_ms"`
+	TotalFunds int                `json:"total_funds"`
+	Succeeded  int                `json:"succeeded"`
+	Failed     int                `json:"failed"`
+	Items      []IngestionRunItem `gorm:"foreignKey:RunID" json:"items,omitempty"`
+
+	// Diperbarui selama run berjalan; run "running" yang lama tidak diperbarui dianggap mati
+	HeartbeatAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"heartbeat_at"`
+}
+
+// IngestionRunItem adalah hasil ingestion untuk satu reksa dana dalam sebuah run
+type IngestionRunItem struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	RunID        uint      `gorm:"not null;index" json:"run_id"`
+	MutualFundID uint      `gorm:"not null;index" json:"mutual_fund_id"`
+	Status       string    `gorm:"type:varchar(20);not null" json:"status"`
+	Attempts     int       `json:"attempts"`
+	NavCount     int       `json:"nav_count"`
+	Error        string    `json:"error,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+const (
+	CostMethodFIFO    = "FIFO"
+	CostMethodAverage = "AVERAGE"
+)
+
+// LotMatch mencatat berapa unit dari sebuah lot pembelian yang terpakai oleh sebuah penjualan,
+// beserta biaya perolehan unit tersebut. Dibangun ulang dari ledger setiap kali ledger berubah.
+type LotMatch struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	UserID            uint      `gorm:"not null;index" json:"user_id"`
+	PortfolioID       uint      `gorm:"not null;default:0;index" json:"portfolio_id"`
+	MutualFundID      uint      `gorm:"not null;index" json:"mutual_fund_id"`
+	SellTransactionID uint      `gorm:"not null;index" json:"sell_transaction_id"`
+	BuyTransactionID  uint      `gorm:"not null;index" json:"buy_transaction_id"`
+	Units             float64   `gorm:"not null" json:"units"`
+	CostBasis         float64   `gorm:"not null" json:"cost_basis"`
+	Proceeds          float64   `gorm:"not null" json:"proceeds"`
+	RealizedGain      float64   `gorm:"not null" json:"realized_gain"`
+	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ValidCostMethod memeriksa metode perhitungan biaya perolehan untuk penjualan
+func ValidCostMethod(method string) bool {
+	return method == CostMethodFIFO || method == CostMethodAverage
+}
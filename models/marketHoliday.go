@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// MarketHoliday adalah hari libur bursa yang jatuh pada hari kerja: NAV tidak terbit dan pembelian
+// rencana berkala digeser ke hari bursa berikutnya (lihat utils.HolidayCalendar)
+type MarketHoliday struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Date      time.Time `gorm:"type:date;not null;uniqueIndex" json:"date"`
+	Name      string    `gorm:"type:varchar(128);not null" json:"name"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
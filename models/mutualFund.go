@@ -1,5 +1,27 @@
 package models
 
+import "time"
+
+const (
+	NavProviderBareksa = "bareksa"
+	NavProviderFile    = "file"
+)
+
+// FeeRate adalah biaya reksa dana dalam bentuk desimal (1% = 0.01). Rate nil berarti biaya
+// tidak dicantumkan ("-" atau kosong); IsMax bernilai true jika teks aslinya batas atas ("Max 2%").
+type FeeRate struct {
+	Rate  *float64 `gorm:"type:numeric(9,6)" json:"rate"`
+	IsMax bool     `gorm:"not null;default:false" json:"is_max"`
+}
+
+// Decimal mengembalikan tarif biaya untuk perhitungan; biaya yang tidak dicantumkan dianggap 0
+func (f FeeRate) Decimal() float64 {
+	if f.Rate == nil {
+		return 0
+	}
+	return *f.Rate
+}
+
 type MutualFund struct {
 	ID          uint           `gorm:"primaryKey" json:"id"`
 	PID			uint           `gorm:"not null" json:"pid"`
@@ -9,4 +31,21 @@ type MutualFund struct {
 	ConsodiantFee string `gorm:"not null" json:"consodionist_fee"`
 	SwitchingFee string `gorm:"not null" json:"switching_fee"`
 	InvestmentManagement string `gorm:"not null" json:"investment_management"`
-}
\ No newline at end of file
+	// Jenis reksa dana, misalnya pasar uang, pendapatan tetap, campuran, saham
+	Category string `gorm:"type:varchar(64);not null;default:''" json:"category"`
+	// Hasil parsing kolom teks di atas; teks aslinya tetap disimpan untuk audit.
+	// Minimum investasi dalam rupiah, nil jika tidak dicantumkan.
+	MinimumInvestmentIDR *int64  `json:"minimum_investment_idr"`
+	ManagementFeeRate    FeeRate `gorm:"embedded;embeddedPrefix:management_fee_" json:"management_fee_rate"`
+	CustodianFeeRate     FeeRate `gorm:"embedded;embeddedPrefix:custodian_fee_" json:"custodian_fee_rate"`
+	SwitchingFeeRate     FeeRate `gorm:"embedded;embeddedPrefix:switching_fee_" json:"switching_fee_rate"`
+	// Waktu teks biaya terakhir di-parse ke kolom bertipe; kosong untuk reksa dana lama yang belum dimigrasi
+	FeesParsedAt *time.Time `json:"fees_parsed_at,omitempty"`
+	// Sumber data NAV (lihat utils.NavProvider) dan ID reksa dana di sumber tersebut
+	NavProvider string `gorm:"type:varchar(32);not null;default:'bareksa'" json:"nav_provider"`
+	ExternalID  string `gorm:"type:varchar(64);not null;default:''" json:"external_id"`
+	// Mata uang NAV reksa dana (kode ISO 4217), misalnya USD untuk reksa dana global
+	Currency string `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	// Tanggal peluncuran reksa dana, awal backfill riwayat NAV
+	InceptionDate *time.Time `gorm:"type:date" json:"inception_date,omitempty"`
+}
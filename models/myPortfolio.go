@@ -9,7 +9,12 @@ type MyPortfolio struct {
 	MutualFundID      uint      `gorm:"not null" json:"mutual_fund_id"`
 	Date              time.Time `gorm:"not null" json:"date"`
 	Value             float64   `gorm:"not null" json:"value"`
+	// Mata uang Value, sama dengan mata uang reksa dana
+	Currency          string    `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
 	UserID            uint      `gorm:"not null" json:"user_id"`
+	// NAV yang dipakai saat pembelian dan unit yang didapat (value / nav), 0 jika NAV belum terbit
+	Nav               float64   `gorm:"not null;default:0" json:"nav"`
+	Units             float64   `gorm:"not null;default:0" json:"units"`
 	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt         time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 	DeletedAt         *time.Time `gorm:"index" json:"deleted_at,omitempty"`
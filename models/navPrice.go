@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// NavPrice menyimpan satu titik NAV harian untuk sebuah reksa dana.
+// Kombinasi (mutual_fund_id, date) unik sehingga data yang sama tidak tersimpan dua kali.
+type NavPrice struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	MutualFundID uint      `gorm:"not null;uniqueIndex:idx_nav_prices_fund_date" json:"mutual_fund_id"`
+	Date         time.Time `gorm:"type:date;not null;uniqueIndex:idx_nav_prices_fund_date" json:"date"`
+	Nav          float64   `gorm:"not null" json:"nav"`
+	Source       string    `gorm:"type:varchar(32);not null" json:"source"`
+	FetchedAt    time.Time `gorm:"not null" json:"fetched_at"`
+}
+
+// NavFetch mencatat rentang tanggal yang sudah diminta ke provider NAV sebuah reksa dana. Hari kerja
+// tanpa NAV di rentang ini (misalnya libur bursa) tidak diminta ulang setelah harinya lewat.
+type NavFetch struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	MutualFundID uint      `gorm:"not null;index" json:"mutual_fund_id"`
+	StartDate    time.Time `gorm:"type:date;not null" json:"start_date"`
+	EndDate      time.Time `gorm:"type:date;not null" json:"end_date"`
+	FetchedAt    time.Time `gorm:"not null" json:"fetched_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// DefaultPortfolioName adalah nama portfolio yang dibuat otomatis untuk setiap user
+const DefaultPortfolioName = "Portfolio Utama"
+
+// Portfolio adalah wadah kepemilikan milik user (misalnya dana pensiun atau dana pendidikan).
+// Setiap transaksi berada di tepat satu portfolio.
+type Portfolio struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"not null;uniqueIndex:idx_portfolios_user_name,priority:1" json:"user_id"`
+	Name         string    `gorm:"not null;uniqueIndex:idx_portfolios_user_name,priority:2" json:"name"`
+	Description  string    `gorm:"not null;default:''" json:"description"`
+	BaseCurrency string    `gorm:"type:varchar(3);not null;default:'IDR'" json:"base_currency"`
+	IsDefault    bool      `gorm:"not null;default:false" json:"is_default"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
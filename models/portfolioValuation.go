@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// PortfolioValuation adalah nilai kepemilikan satu portfolio user di satu reksa dana pada satu tanggal NAV.
+// Tabel ini turunan dari transactions dan nav_prices, diperbarui setiap kali keduanya berubah
+// (lihat utils.RefreshPortfolioValuations).
+type PortfolioValuation struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"not null;uniqueIndex:idx_portfolio_valuations_user_portfolio_fund_date,priority:1" json:"user_id"`
+	PortfolioID  uint      `gorm:"not null;default:0;uniqueIndex:idx_portfolio_valuations_user_portfolio_fund_date,priority:2" json:"portfolio_id"`
+	MutualFundID uint      `gorm:"not null;uniqueIndex:idx_portfolio_valuations_user_portfolio_fund_date,priority:3;index" json:"mutual_fund_id"`
+	Date         time.Time `gorm:"type:date;not null;uniqueIndex:idx_portfolio_valuations_user_portfolio_fund_date,priority:4" json:"date"`
+	Nav          float64   `gorm:"not null" json:"nav"`
+	Units        float64   `gorm:"not null" json:"units"`
+	CostBasis    float64   `gorm:"not null" json:"cost_basis"`
+	Value        float64   `gorm:"not null" json:"value"`
+	CashIn       float64   `gorm:"not null" json:"cash_in"`
+	CashOut      float64   `gorm:"not null" json:"cash_out"`
+	DailyGain    float64   `gorm:"not null" json:"daily_gain"`
+	TotalGain    float64   `gorm:"not null" json:"total_gain"`
+	Fees         float64   `gorm:"not null" json:"fees"`
+	Dividends    float64   `gorm:"not null;default:0" json:"dividends"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ValuationRefresh adalah antrean perhitungan ulang portfolio_valuations semua pemegang satu reksa dana
+// mulai FromDate, diproses di background (lihat utils.ProcessValuationRefreshes). Satu baris per reksa
+// dana: permintaan berikutnya hanya memundurkan FromDate. ApplyDistributions bernilai true jika DIVIDEND
+// hasil distribusi juga perlu disinkronkan (lihat utils.ApplyFundDistributions). Baris yang sedang atau
+// gagal diproses punya LockedUntil dan baru diambil lagi setelah waktu itu lewat.
+type ValuationRefresh struct {
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	MutualFundID       uint       `gorm:"not null;uniqueIndex" json:"mutual_fund_id"`
+	FromDate           time.Time  `gorm:"type:date;not null" json:"from_date"`
+	ApplyDistributions bool       `gorm:"not null;default:false" json:"apply_distributions"`
+	QueuedAt           time.Time  `gorm:"not null" json:"queued_at"`
+	LockedUntil        *time.Time `json:"locked_until,omitempty"`
+	Attempts           int        `gorm:"not null;default:0" json:"attempts"`
+	LastError          string     `gorm:"type:text" json:"last_error,omitempty"`
+}
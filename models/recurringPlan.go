@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+const (
+	PlanFrequencyMonthly   = "MONTHLY"
+	PlanFrequencyQuarterly = "QUARTERLY"
+
+	PlanStatusActive    = "active"
+	PlanStatusPaused    = "paused"
+	PlanStatusCancelled = "cancelled"
+	PlanStatusCompleted = "completed"
+
+	PlanExecutionExecuted = "executed"
+	PlanExecutionSkipped  = "skipped"
+)
+
+// PlanFrequencyMonths mengembalikan jarak antar eksekusi dalam bulan, 0 jika frekuensi tidak dikenal
+func PlanFrequencyMonths(frequency string) int {
+	switch frequency {
+	case PlanFrequencyMonthly:
+		return 1
+	case PlanFrequencyQuarterly:
+		return 3
+	}
+	return 0
+}
+
+// RecurringPlan adalah rencana investasi berkala (auto-invest). Scheduler membuat transaksi BUY
+// setiap jatuh tempo; jika jatuh di akhir pekan atau hari libur bursa, pembelian digeser ke hari bursa berikutnya.
+type RecurringPlan struct {
+	ID           uint    `gorm:"primaryKey" json:"id"`
+	UserID       uint    `gorm:"not null;index" json:"user_id"`
+	PortfolioID  uint    `gorm:"not null;default:0;index" json:"portfolio_id"`
+	MutualFundID uint    `gorm:"not null;index" json:"mutual_fund_id"`
+	Amount       float64 `gorm:"not null" json:"amount"`
+	Fee          float64 `gorm:"not null;default:0" json:"fee"`
+	Frequency    string  `gorm:"type:varchar(16);not null" json:"frequency"`
+	// Tanggal jatuh tempo dalam bulan (1-31); bulan yang lebih pendek memakai tanggal terakhirnya
+	DayOfMonth int        `gorm:"not null" json:"day_of_month"`
+	StartDate  time.Time  `gorm:"type:date;not null" json:"start_date"`
+	EndDate    *time.Time `gorm:"type:date" json:"end_date,omitempty"`
+	Status     string     `gorm:"type:varchar(16);not null;index" json:"status"`
+	// Jatuh tempo berikutnya (sebelum digeser ke hari bursa)
+	NextRunDate time.Time  `gorm:"type:date;not null;index" json:"next_run_date"`
+	LastRunDate *time.Time `gorm:"type:date" json:"last_run_date,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	// Kegagalan eksekusi berturut-turut. Scheduler menunda percobaan berikutnya sampai RetryAt dan
+	// menjeda rencana setelah terlalu sering gagal; LastError adalah pesan kegagalan terakhir.
+	FailureCount int        `gorm:"not null;default:0" json:"failure_count"`
+	RetryAt      *time.Time `json:"retry_at,omitempty"`
+	LastError    string     `gorm:"type:text" json:"last_error,omitempty"`
+}
+
+// RecurringPlanExecution mencatat setiap jatuh tempo rencana, baik yang dieksekusi maupun dilewati
+type RecurringPlanExecution struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	PlanID        uint      `gorm:"not null;uniqueIndex:idx_plan_executions_plan_date,priority:1" json:"plan_id"`
+	ScheduledDate time.Time `gorm:"type:date;not null;uniqueIndex:idx_plan_executions_plan_date,priority:2" json:"scheduled_date"`
+	ExecutionDate time.Time `gorm:"type:date;not null" json:"execution_date"`
+	Status        string    `gorm:"type:varchar(16);not null" json:"status"`
+	TransactionID *uint     `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+type TransactionType string
+
+const (
+	TransactionBuy       TransactionType = "BUY"
+	TransactionSell      TransactionType = "SELL"
+	TransactionSwitchOut TransactionType = "SWITCH_OUT"
+	TransactionSwitchIn  TransactionType = "SWITCH_IN"
+	TransactionDividend  TransactionType = "DIVIDEND"
+)
+
+// ValidTransactionType mengecek apakah t salah satu tipe transaksi yang dikenal
+func ValidTransactionType(t TransactionType) bool {
+	switch t {
+	case TransactionBuy, TransactionSell, TransactionSwitchOut, TransactionSwitchIn, TransactionDividend:
+		return true
+	}
+	return false
+}
+
+// Transaction adalah satu baris ledger portfolio. Semua kepemilikan dihitung dari ledger ini.
+//
+// Amount, Nav dan Fee selalu dalam mata uang reksa dana (Currency) dan positif:
+//   - BUY / SWITCH_IN: uang yang masuk ke reksa dana, termasuk Fee; units = (Amount - Fee) / Nav
+//   - SELL / SWITCH_OUT: hasil penjualan bruto = units × Nav; uang yang diterima = Amount - Fee
+//   - DIVIDEND: dividen yang diterima; jika Units > 0 dividen diinvestasikan ulang dengan NAV Nav
+//
+// Units juga selalu positif, arahnya ditentukan oleh Type (lihat SignedUnits).
+type Transaction struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	UserID         uint            `gorm:"not null;index" json:"user_id"`
+	PortfolioID    uint            `gorm:"not null;default:0;index" json:"portfolio_id"`
+	MutualFundID   uint            `gorm:"not null;index" json:"mutual_fund_id"`
+	Type           TransactionType `gorm:"type:varchar(16);not null" json:"type"`
+	Date           time.Time       `gorm:"type:date;not null" json:"date"`
+	SettlementDate *time.Time      `gorm:"type:date" json:"settlement_date,omitempty"`
+	// Mata uang Amount, Nav dan Fee, sama dengan mata uang reksa dana
+	Currency string  `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	Amount   float64 `gorm:"not null;default:0" json:"amount"`
+	Units    float64 `gorm:"not null;default:0" json:"units"`
+	// NAV yang dipakai; 0 berarti NAV tanggal transaksi belum terbit (pending)
+	Nav float64 `gorm:"not null;default:0" json:"nav"`
+	Fee float64 `gorm:"not null;default:0" json:"fee"`
+	// Khusus SELL / SWITCH_OUT: metode pencocokan lot (FIFO atau AVERAGE), biaya perolehan
+	// unit yang dijual dan keuntungan terealisasi (hasil bersih - biaya perolehan)
+	CostMethod   string  `gorm:"type:varchar(10)" json:"cost_method,omitempty"`
+	CostBasis    float64 `gorm:"not null;default:0" json:"cost_basis"`
+	RealizedGain float64 `gorm:"not null;default:0" json:"realized_gain"`
+	// Pasangan transaksi untuk SWITCH_OUT / SWITCH_IN
+	LinkedTransactionID *uint `gorm:"index" json:"linked_transaction_id,omitempty"`
+	// ID my_portfolios asal untuk data hasil migrasi
+	LegacyPortfolioID *uint `gorm:"uniqueIndex" json:"legacy_portfolio_id,omitempty"`
+	// ID transaction_imports untuk transaksi hasil impor CSV
+	ImportID *uint `gorm:"index" json:"import_id,omitempty"`
+	// ID fund_distributions untuk DIVIDEND yang dibuat otomatis dari distribusi reksa dana
+	DistributionID *uint      `gorm:"index" json:"distribution_id,omitempty"`
+	Note           string     `json:"note,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt      *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// SignedUnits mengembalikan perubahan unit: positif untuk unit masuk, negatif untuk unit keluar
+func (t Transaction) SignedUnits() float64 {
+	switch t.Type {
+	case TransactionSell, TransactionSwitchOut:
+		return -t.Units
+	}
+	return t.Units
+}
+
+// Pending bernilai true jika NAV transaksi belum diketahui sehingga unit/nilainya belum final
+func (t Transaction) Pending() bool {
+	return t.Type != TransactionDividend && t.Nav <= 0
+}
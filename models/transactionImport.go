@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// TransactionImport mencatat satu impor riwayat transaksi dari file CSV (Bareksa, Bibit, aplikasi bank).
+// Transaksi hasil impor menyimpan ID ini di transactions.import_id.
+type TransactionImport struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	PortfolioID uint      `gorm:"not null;index" json:"portfolio_id"`
+	Source      string    `gorm:"type:varchar(32);not null" json:"source"`
+	FileName    string    `gorm:"not null;default:''" json:"file_name"`
+	TotalRows   int       `json:"total_rows"`
+	Imported    int       `json:"imported"`
+	Duplicates  int       `json:"duplicates"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
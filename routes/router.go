@@ -4,6 +4,7 @@ import (
 	"golang/controllers"
 	"golang/middlewares"
 	"golang/models"
+	"golang/utils"
 	"log"
 	"os"
 	"time"
@@ -16,7 +17,8 @@ import (
 	"gorm.io/gorm"
 )
 
-func SetupRouter() *gin.Engine {
+// ConnectDatabase membuka koneksi PostgreSQL dan menjalankan migrasi
+func ConnectDatabase() *gorm.DB {
 	// Connect to PostgreSQL
 	dbURL := os.Getenv("DATABASE_URL")
 	if dbURL == "" {
@@ -32,6 +34,25 @@ func SetupRouter() *gin.Engine {
 		log.Fatal("Migration failed: ", err)
 	}
 
+	// Isi kolom biaya bertipe untuk reksa dana lama
+	if err := utils.MigrateFundFees(db); err != nil {
+		log.Fatal("Mutual fund fee migration failed: ", err)
+	}
+
+	// Pindahkan data my_portfolios lama ke ledger transaksi
+	if err := utils.MigrateLegacyPortfolios(db); err != nil {
+		log.Fatal("Portfolio ledger migration failed: ", err)
+	}
+
+	// Ledger lama tanpa portfolio masuk ke portfolio default user
+	if err := utils.MigratePortfolios(db); err != nil {
+		log.Fatal("Portfolio assignment migration failed: ", err)
+	}
+
+	return db
+}
+
+func SetupRouter(db *gorm.DB) *gin.Engine {
 	// Gunakan hanya satu router
 	router := gin.Default()
 
@@ -40,7 +61,7 @@ func SetupRouter() *gin.Engine {
 		AllowOrigins:     []string{"http://localhost:8080"}, // ubah dari "*" agar support credentials
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "X-Cache", "Warning"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -52,12 +73,30 @@ func SetupRouter() *gin.Engine {
 		DB:       0,
 	})
 
+	// Cache response NAV di Redis, TTL mengikuti jadwal terbit NAV
+	schedule, err := utils.DefaultNavSchedule()
+	if err != nil {
+		log.Fatal("Invalid NAV schedule: ", err)
+	}
+	utils.InitNavCache(rdb, schedule)
+
 	// Inisialisasi controller
 	authController := controllers.NewAuthController(db, rdb)
 	userController := controllers.UserController{}
 	mutualFundController := controllers.NewMutualFundController(db)
-	bareksaController := controllers.NewBareksaController()
+	navController := controllers.NewNavController(db)
 	MyPortfolioController := controllers.NewMyPortfolioController(db)
+	portfolioController := controllers.NewPortfolioController(db)
+	transactionController := controllers.NewTransactionController(db)
+	performanceController := controllers.NewPerformanceController(db)
+	recurringPlanController := controllers.NewRecurringPlanController(db)
+	goalController := controllers.NewGoalController(db)
+	ingestionController := controllers.NewIngestionController(db)
+	backfillController := controllers.NewBackfillController(db)
+	marketHolidayController := controllers.NewMarketHolidayController(db)
+	distributionController := controllers.NewDistributionController(db)
+	fxRateController := controllers.NewFxRateController(db)
+	backtestController := controllers.NewBacktestController(db)
 
 	// Public routes
 	router.POST("/register", authController.Register)
@@ -71,13 +110,48 @@ func SetupRouter() *gin.Engine {
 		auth.GET("/mutual-funds", mutualFundController.GetAll)
 		auth.GET("/mutual-funds/:id", mutualFundController.GetByID)
 		auth.POST("/mutual-funds", mutualFundController.Create)
-		auth.GET("/mutual-fund-nav", bareksaController.GetMutualFundNav)
+		auth.GET("/mutual-funds/:id/distributions", distributionController.GetDistributions)
+		auth.GET("/mutual-funds/:id/backtest", backtestController.GetBacktest)
+		auth.GET("/mutual-fund-nav", navController.GetMutualFundNav)
+		auth.GET("/market-holidays", marketHolidayController.GetHolidays)
+		auth.GET("/fx-rates", fxRateController.GetFxRates)
+		auth.GET("/portfolios", portfolioController.GetPortfolios)
+		auth.POST("/portfolios", portfolioController.CreatePortfolio)
+		auth.PUT("/portfolios/:id", portfolioController.UpdatePortfolio)
+		auth.DELETE("/portfolios/:id", portfolioController.DeletePortfolio)
 		auth.GET("/portfolio", MyPortfolioController.GetPortfolio)
+		auth.GET("/portfolio/summary", MyPortfolioController.GetPortfolioSummary)
+		auth.GET("/portfolio/statement", MyPortfolioController.ExportStatement)
+		auth.GET("/portfolio/annual-report", MyPortfolioController.GetAnnualReport)
 		auth.POST("/portfolio", MyPortfolioController.CreatePortfolio)
 		auth.PUT("/portfolio/:id", MyPortfolioController.UpdatePortfolio)
 		auth.DELETE("/portfolio/:id", MyPortfolioController.DeletePortfolio)
 		auth.GET("/portfolio/:id/nav", MyPortfolioController.GetPortfolioByID)
 		auth.GET("/portfolio/mutual-fund/:id/aggregated", MyPortfolioController.GetAggregatedPortfolioByMutualFundID)
+		auth.GET("/portfolio/mutual-fund/:id/lots", MyPortfolioController.GetLotsByMutualFundID)
+		auth.POST("/portfolio/mutual-fund/:id/redeem", MyPortfolioController.RedeemPortfolio)
+		auth.GET("/portfolio/mutual-fund/:id/returns", performanceController.GetFundReturns)
+		auth.GET("/portfolio/returns", performanceController.GetPortfolioReturns)
+		auth.GET("/transactions", transactionController.GetTransactions)
+		auth.POST("/transactions", transactionController.CreateTransaction)
+		auth.POST("/transactions/switch", transactionController.SwitchFunds)
+		auth.GET("/transactions/import/sources", transactionController.GetImportSources)
+		auth.POST("/transactions/import", transactionController.ImportTransactions)
+		auth.GET("/holdings", transactionController.GetHoldings)
+		auth.GET("/recurring-plans", recurringPlanController.GetPlans)
+		auth.POST("/recurring-plans", recurringPlanController.CreatePlan)
+		auth.GET("/recurring-plans/:id", recurringPlanController.GetPlanByID)
+		auth.GET("/recurring-plans/:id/upcoming", recurringPlanController.GetUpcoming)
+		auth.POST("/recurring-plans/:id/pause", recurringPlanController.PausePlan)
+		auth.POST("/recurring-plans/:id/resume", recurringPlanController.ResumePlan)
+		auth.POST("/recurring-plans/:id/skip", recurringPlanController.SkipPlan)
+		auth.POST("/recurring-plans/:id/cancel", recurringPlanController.CancelPlan)
+		auth.GET("/goals", goalController.GetGoals)
+		auth.POST("/goals", goalController.CreateGoal)
+		auth.GET("/goals/:id", goalController.GetGoalByID)
+		auth.PUT("/goals/:id", goalController.UpdateGoal)
+		auth.DELETE("/goals/:id", goalController.DeleteGoal)
+		auth.GET("/goals/:id/projection", goalController.GetProjection)
 		auth.POST("/logout", authController.Logout)
 	}
 
@@ -86,6 +160,19 @@ func SetupRouter() *gin.Engine {
 	admin.Use(middlewares.AuthMiddleware(), middlewares.RoleMiddleware(models.Admin))
 	{
 		admin.GET("/dashboard", userController.AdminEndpoint)
+		admin.POST("/ingestion/runs", ingestionController.TriggerRun)
+		admin.GET("/ingestion/runs", ingestionController.GetRuns)
+		admin.GET("/ingestion/runs/:id", ingestionController.GetRunByID)
+		admin.PUT("/mutual-funds/:id/currency", mutualFundController.SetCurrency)
+		admin.POST("/mutual-funds/:id/backfill", backfillController.StartBackfill)
+		admin.GET("/mutual-funds/:id/backfill", backfillController.GetBackfill)
+		admin.POST("/market-holidays", marketHolidayController.CreateHoliday)
+		admin.DELETE("/market-holidays/:id", marketHolidayController.DeleteHoliday)
+		admin.POST("/mutual-funds/:id/distributions", distributionController.CreateDistribution)
+		admin.POST("/mutual-funds/:id/distributions/sync", distributionController.SyncDistributions)
+		admin.DELETE("/distributions/:id", distributionController.DeleteDistribution)
+		admin.POST("/fx-rates", fxRateController.CreateFxRate)
+		admin.POST("/fx-rates/sync", fxRateController.SyncFxRates)
 	}
 
 	return router
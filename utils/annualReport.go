@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"fmt"
+	"golang/models"
+	"io"
+	"strconv"
+	"time"
+)
+
+// AnnualFundReport adalah ringkasan satu reksa dana dalam satu tahun kalender
+type AnnualFundReport struct {
+	MutualFundID  uint    `json:"mutual_fund_id"`
+	Name          string  `json:"name"`
+	Purchases     float64 `json:"purchases"`
+	PurchaseUnits float64 `json:"purchase_units"`
+	// Hasil penjualan bersih (setelah biaya) dan biaya perolehan unit yang dijual
+	Redemptions    float64 `json:"redemptions"`
+	RedeemedUnits  float64 `json:"redeemed_units"`
+	RedeemedCost   float64 `json:"redeemed_cost"`
+	RealizedGain   float64 `json:"realized_gain"`
+	Dividends      float64 `json:"dividends"`
+	Fees           float64 `json:"fees"`
+	YearEndUnits   float64 `json:"year_end_units"`
+	YearEndNav     float64 `json:"year_end_nav"`
+	YearEndNavDate string  `json:"year_end_nav_date"`
+	YearEndValue   float64 `json:"year_end_value"`
+	YearEndCost    float64 `json:"year_end_cost_basis"`
+	UnrealizedGain float64 `json:"unrealized_gain"`
+	// Bagian keuntungan tahun ini yang berasal dari perubahan kurs
+	FxGain float64 `json:"fx_gain"`
+}
+
+// AnnualReport adalah laporan tahunan keuntungan terealisasi dan dividen milik user
+type AnnualReport struct {
+	Year          int                `json:"year"`
+	PortfolioName string             `json:"portfolio_name"`
+	Currency      string             `json:"currency"`
+	GeneratedAt   time.Time          `json:"generated_at"`
+	Purchases     float64            `json:"purchases"`
+	Redemptions   float64            `json:"redemptions"`
+	RealizedGain  float64            `json:"realized_gain"`
+	Dividends     float64            `json:"dividends"`
+	Fees          float64            `json:"fees"`
+	FxGain        float64            `json:"fx_gain"`
+	YearEndValue  float64            `json:"year_end_value"`
+	Funds         []AnnualFundReport `json:"funds"`
+}
+
+// BuildAnnualReport menyusun laporan tahun year dari ledger dan nilai harian setiap reksa dana
+// (lihat LoadPortfolioValuations dengan end = 31 Desember). Penjualan SELL dan SWITCH_OUT
+// dihitung sebagai penjualan; BUY dan SWITCH_IN sebagai pembelian. Transaksi pending diabaikan.
+func BuildAnnualReport(valuations []FundValuation, names map[uint]string, year int) AnnualReport {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	report := AnnualReport{Year: year, GeneratedAt: time.Now(), Funds: []AnnualFundReport{}}
+
+	for _, fv := range valuations {
+		name := names[fv.MutualFundID]
+		if name == "" {
+			name = fv.ProductName
+		}
+		item := AnnualFundReport{MutualFundID: fv.MutualFundID, Name: name}
+
+		active := false
+		for _, tx := range fv.Transactions {
+			if tx.Pending() || tx.Date.Before(start) || tx.Date.After(end) {
+				continue
+			}
+			active = true
+			item.Fees += tx.Fee
+			switch tx.Type {
+			case models.TransactionBuy, models.TransactionSwitchIn:
+				item.Purchases += tx.Amount
+				item.PurchaseUnits += tx.Units
+			case models.TransactionSell, models.TransactionSwitchOut:
+				item.Redemptions += tx.Amount - tx.Fee
+				item.RedeemedUnits += tx.Units
+				item.RedeemedCost += tx.CostBasis
+				item.RealizedGain += tx.RealizedGain
+			case models.TransactionDividend:
+				item.Dividends += tx.Amount
+			}
+		}
+
+		var openingFxGain float64
+		for _, day := range fv.Days {
+			if day.Date.After(end) {
+				break
+			}
+			if day.Date.Before(start) {
+				openingFxGain = day.FxGain
+			}
+			item.FxGain = day.FxGain - openingFxGain
+			item.YearEndUnits = day.Units
+			item.YearEndNav = day.Nav
+			item.YearEndNavDate = day.Date.Format(dateLayout)
+			item.YearEndValue = day.Value
+			item.YearEndCost = day.CostBasis
+		}
+		item.UnrealizedGain = item.YearEndValue - item.YearEndCost
+
+		// Reksa dana tanpa transaksi dan tanpa kepemilikan di tahun ini tidak dilaporkan
+		if !active && item.YearEndUnits <= unitEpsilon {
+			continue
+		}
+		report.Funds = append(report.Funds, item)
+		report.Purchases += item.Purchases
+		report.Redemptions += item.Redemptions
+		report.RealizedGain += item.RealizedGain
+		report.Dividends += item.Dividends
+		report.Fees += item.Fees
+		report.FxGain += item.FxGain
+		report.YearEndValue += item.YearEndValue
+	}
+	return report
+}
+
+// WriteAnnualReport menulis laporan tahunan ke w dalam format csv, xlsx, atau pdf
+func WriteAnnualReport(w io.Writer, r AnnualReport, format string) error {
+	title := fmt.Sprintf("Laporan Tahunan %d - %s", r.Year, r.PortfolioName)
+	return writeTables(w, format, title, annualReportTables(r))
+}
+
+func annualReportTables(r AnnualReport) []statementTable {
+	summary := statementTable{
+		Title:  "Ringkasan " + strconv.Itoa(r.Year),
+		Header: []string{"Keterangan", "Nilai"},
+		Rows: [][]any{
+			{"Portfolio", r.PortfolioName},
+			{"Mata uang", r.Currency},
+			{"Tahun", strconv.Itoa(r.Year)},
+			{"Dibuat", r.GeneratedAt.Format("2006-01-02 15:04")},
+			{"Pembelian", statementMoney(r.Purchases)},
+			{"Penjualan bersih", statementMoney(r.Redemptions)},
+			{"Keuntungan terealisasi", statementMoney(r.RealizedGain)},
+			{"Dividen diterima", statementMoney(r.Dividends)},
+			{"Biaya transaksi", statementMoney(r.Fees)},
+			{"Selisih kurs", statementMoney(r.FxGain)},
+			{"Nilai akhir tahun", statementMoney(r.YearEndValue)},
+		},
+	}
+
+	funds := statementTable{
+		Title: "Per Reksa Dana",
+		Header: []string{"Reksa Dana", "Pembelian", "Unit Dibeli", "Penjualan Bersih", "Unit Dijual",
+			"Biaya Perolehan Dijual", "Keuntungan Terealisasi", "Dividen", "Biaya", "Unit Akhir Tahun",
+			"NAV Akhir Tahun", "Tanggal NAV", "Nilai Akhir Tahun", "Keuntungan Belum Terealisasi"},
+	}
+	for _, f := range r.Funds {
+		funds.Rows = append(funds.Rows, []any{f.Name, statementMoney(f.Purchases), statementUnits(f.PurchaseUnits),
+			statementMoney(f.Redemptions), statementUnits(f.RedeemedUnits), statementMoney(f.RedeemedCost),
+			statementMoney(f.RealizedGain), statementMoney(f.Dividends), statementMoney(f.Fees),
+			statementUnits(f.YearEndUnits), statementUnits(f.YearEndNav), f.YearEndNavDate,
+			statementMoney(f.YearEndValue), statementMoney(f.UnrealizedGain)})
+	}
+	return []statementTable{summary, funds}
+}
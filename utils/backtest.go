@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"golang/models"
+	"sort"
+	"time"
+)
+
+// Strategi backtest yang didukung
+const (
+	BacktestLumpSum        = "lump_sum"
+	BacktestMonthlyDCA     = "monthly_dca"
+	BacktestValueAveraging = "value_averaging"
+)
+
+// BacktestStrategies adalah urutan strategi yang dijalankan jika strategi tidak dipilih
+var BacktestStrategies = []string{BacktestLumpSum, BacktestMonthlyDCA, BacktestValueAveraging}
+
+func ValidBacktestStrategy(strategy string) bool {
+	for _, s := range BacktestStrategies {
+		if s == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// BacktestPoint adalah satu titik equity curve backtest
+type BacktestPoint struct {
+	Date  string  `json:"date"`
+	Nav   float64 `json:"nav"`
+	Units float64 `json:"units"`
+	// Total uang yang sudah disetor sampai tanggal ini
+	Invested float64 `json:"invested"`
+	Value    float64 `json:"value"`
+	// Penurunan dari puncak indeks time-weighted (desimal negatif, -0.1 = turun 10%)
+	Drawdown float64 `json:"drawdown"`
+}
+
+// BacktestResult adalah hasil satu strategi backtest
+type BacktestResult struct {
+	Strategy     string  `json:"strategy"`
+	Installments int     `json:"installments"`
+	Invested     float64 `json:"invested"`
+	// Bagian dana yang tidak terpakai (hanya value averaging, jika nilai sudah di atas target)
+	CashRemaining float64 `json:"cash_remaining"`
+	FinalValue    float64 `json:"final_value"`
+	// Dividen yang dibagikan tunai selama backtest (tidak termasuk di FinalValue)
+	DividendsPaid float64 `json:"dividends_paid"`
+	// Biaya pembelian yang dipotong dari setoran
+	FeesPaid float64       `json:"fees_paid"`
+	Returns  ReturnMetrics `json:"returns"`
+	// Penurunan terbesar dari puncak ke lembah indeks time-weighted, sehingga setoran baru tidak
+	// menutupi kerugian
+	MaxDrawdown       float64         `json:"max_drawdown"`
+	MaxDrawdownPeak   string          `json:"max_drawdown_peak,omitempty"`
+	MaxDrawdownTrough string          `json:"max_drawdown_trough,omitempty"`
+	EquityCurve       []BacktestPoint `json:"equity_curve"`
+}
+
+// RunBacktest mensimulasikan investasi amount di reksa dana fund dengan strategi strategy pada deret NAV
+// navs (terurut) untuk rentang [start, end], termasuk distribusi dividen dists. Setiap pembelian dipotong
+// biaya feeRate (desimal) dari nilainya, sama seperti ApplyTradeNav. Ledger hanya dibuat di memori; tidak
+// ada transaksi atau portfolio yang disimpan.
+//
+// Lump sum membeli seluruh amount pada NAV pertama. Monthly DCA membagi amount rata ke setiap bulan,
+// dieksekusi pada NAV pertama pada atau setelah tanggal yang sama dengan start di bulan itu. Value averaging
+// memakai jadwal yang sama dengan target nilai naik rata sampai amount di angsuran terakhir: setiap bulan
+// hanya membeli kekurangan dari target setelah biaya (tanpa menjual), dibatasi sisa dana.
+func RunBacktest(fund models.MutualFund, navs []models.NavPrice, dists []models.FundDistribution, amount, feeRate float64, start, end time.Time, strategy string) (BacktestResult, error) {
+	result := BacktestResult{Strategy: strategy, EquityCurve: []BacktestPoint{}}
+	start, end = DateOnly(start), DateOnly(end)
+
+	var series []models.NavPrice
+	for _, nav := range navs {
+		if nav.Nav > 0 && !nav.Date.Before(start) && !nav.Date.After(end) {
+			series = append(series, nav)
+		}
+	}
+	if len(series) == 0 {
+		return result, ErrNotEnoughHistory
+	}
+
+	schedule := []int{0}
+	if strategy != BacktestLumpSum {
+		schedule = monthlyNavIndexes(series, start, end)
+	}
+	result.Installments = len(schedule)
+
+	buy := func(nav models.NavPrice, value float64) models.Transaction {
+		fee := value * feeRate
+		return models.Transaction{
+			Type:         models.TransactionBuy,
+			MutualFundID: fund.ID,
+			Currency:     fund.Currency,
+			Date:         nav.Date,
+			Nav:          nav.Nav,
+			Units:        (value - fee) / nav.Nav,
+			Amount:       value,
+			Fee:          fee,
+		}
+	}
+	var txs []models.Transaction
+	remaining := amount
+	for k, i := range schedule {
+		nav := series[i]
+		var value float64
+		switch strategy {
+		case BacktestLumpSum:
+			value = amount
+		case BacktestMonthlyDCA:
+			value = amount / float64(len(schedule))
+		case BacktestValueAveraging:
+			target := amount * float64(k+1) / float64(len(schedule))
+			var held []models.Transaction
+			for _, tx := range WithDistributions(txs, dists, series) {
+				if !tx.Date.After(nav.Date) {
+					held = append(held, tx)
+				}
+			}
+			value = (target - BuildPosition(held).Units*nav.Nav) / (1 - feeRate)
+		}
+		if value > remaining {
+			value = remaining
+		}
+		if value <= 0 {
+			continue
+		}
+		txs = append(txs, buy(nav, value))
+		remaining -= value
+	}
+	result.CashRemaining = remaining
+	if len(txs) == 0 {
+		return result, nil
+	}
+
+	txs = WithDistributions(txs, dists, series)
+	days := BuildDailyValuation(txs, series)
+	pos := BuildPosition(txs)
+	result.Invested = pos.CashIn
+	result.DividendsPaid = pos.DividendsPaid
+	result.FeesPaid = pos.Fees
+	result.Returns = ComputeReturns(days, days[0].Date, end)
+	result.FinalValue = result.Returns.EndValue
+
+	var (
+		invested, prevValue float64
+		index, peak         = 1.0, 1.0
+		peakDate            time.Time
+	)
+	for _, day := range days {
+		index *= twrFactor(prevValue, day)
+		if index >= peak {
+			peak, peakDate = index, day.Date
+		}
+		drawdown := index/peak - 1
+		if drawdown < result.MaxDrawdown {
+			result.MaxDrawdown = drawdown
+			result.MaxDrawdownPeak = peakDate.Format(dateLayout)
+			result.MaxDrawdownTrough = day.Date.Format(dateLayout)
+		}
+		invested += day.CashIn
+		prevValue = day.Value
+
+		result.EquityCurve = append(result.EquityCurve, BacktestPoint{
+			Date:     day.Date.Format(dateLayout),
+			Nav:      day.Nav,
+			Units:    day.Units,
+			Invested: invested,
+			Value:    day.Value,
+			Drawdown: drawdown,
+		})
+	}
+	return result, nil
+}
+
+// monthlyNavIndexes mengembalikan indeks NAV untuk angsuran bulanan mulai start: setiap bulan tanggal
+// yang sama dengan start (dibatasi akhir bulan), dieksekusi pada NAV pertama pada atau setelahnya
+func monthlyNavIndexes(series []models.NavPrice, start, end time.Time) []int {
+	var indexes []int
+	for k := 0; ; k++ {
+		scheduled := planDateInMonth(start.Year(), start.Month()+time.Month(k), start.Day())
+		if scheduled.After(end) {
+			break
+		}
+		i := sort.Search(len(series), func(i int) bool { return !series[i].Date.Before(scheduled) })
+		if i == len(series) {
+			break
+		}
+		if len(indexes) > 0 && indexes[len(indexes)-1] == i {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"golang/models"
+	"sort"
+	"testing"
+)
+
+func navSeries(points map[string]float64) []models.NavPrice {
+	var navs []models.NavPrice
+	for date, nav := range points {
+		navs = append(navs, models.NavPrice{MutualFundID: 1, Date: mustDate(date), Nav: nav})
+	}
+	sort.Slice(navs, func(i, j int) bool { return navs[i].Date.Before(navs[j].Date) })
+	return navs
+}
+
+var backtestFund = models.MutualFund{ID: 1, Currency: models.DefaultCurrency}
+
+func TestBacktestLumpSumAppliesPurchaseFee(t *testing.T) {
+	navs := navSeries(map[string]float64{"2024-01-02": 1000, "2024-01-03": 1100, "2024-01-04": 880, "2024-01-05": 990})
+	r, err := RunBacktest(backtestFund, navs, nil, 1000, 0.01, mustDate("2024-01-01"), mustDate("2024-01-05"), BacktestLumpSum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Installments != 1 {
+		t.Errorf("installments = %d, want 1", r.Installments)
+	}
+	// Biaya 1% dipotong dari setoran: 990 / 1000 = 0.99 unit
+	assertClose(t, "invested", r.Invested, 1000)
+	assertClose(t, "fees", r.FeesPaid, 10)
+	assertClose(t, "units", r.EquityCurve[len(r.EquityCurve)-1].Units, 0.99)
+	assertClose(t, "final value", r.FinalValue, 980.1)
+	// Biaya ikut menurunkan TWR: 0.99 x 990/1000
+	assertClose(t, "twr", r.Returns.TWR, 0.99*0.99-1)
+}
+
+func TestBacktestMonthlyDCA(t *testing.T) {
+	// 15 Februari tidak ada NAV, angsuran kedua memakai NAV 16 Februari
+	navs := navSeries(map[string]float64{"2024-01-15": 100, "2024-02-16": 200, "2024-03-15": 100})
+	r, err := RunBacktest(backtestFund, navs, nil, 3000, 0, mustDate("2024-01-15"), mustDate("2024-03-15"), BacktestMonthlyDCA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Installments != 3 {
+		t.Fatalf("installments = %d, want 3", r.Installments)
+	}
+	// 10 + 5 + 10 unit
+	assertClose(t, "invested", r.Invested, 3000)
+	assertClose(t, "final value", r.FinalValue, 2500)
+	assertClose(t, "cash remaining", r.CashRemaining, 0)
+	if got := r.EquityCurve[1].Date; got != "2024-02-16" {
+		t.Errorf("second installment on %s, want 2024-02-16", got)
+	}
+}
+
+func TestBacktestValueAveraging(t *testing.T) {
+	// Bulan kedua nilai 10 unit x 250 sudah di atas target 2000, jadi tidak membeli; bulan ketiga
+	// membeli kekurangan 3000 - 10 x 100 = 2000
+	navs := navSeries(map[string]float64{"2024-01-15": 100, "2024-02-15": 250, "2024-03-15": 100})
+	r, err := RunBacktest(backtestFund, navs, nil, 3000, 0, mustDate("2024-01-15"), mustDate("2024-03-15"), BacktestValueAveraging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "invested", r.Invested, 3000)
+	assertClose(t, "final value", r.FinalValue, 3000)
+	assertClose(t, "units", r.EquityCurve[2].Units, 30)
+	assertClose(t, "cash remaining", r.CashRemaining, 0)
+}
+
+func TestBacktestValueAveragingGrossesUpFee(t *testing.T) {
+	// Setoran dinaikkan agar nilai setelah biaya 1% tepat mencapai target 5000; angsuran terakhir
+	// dibatasi sisa dana sehingga seluruh dana terpakai
+	navs := navSeries(map[string]float64{"2024-01-15": 100, "2024-02-15": 100})
+	r, err := RunBacktest(backtestFund, navs, nil, 10000, 0.01, mustDate("2024-01-15"), mustDate("2024-02-15"), BacktestValueAveraging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "value after first installment", r.EquityCurve[0].Value, 5000)
+	assertClose(t, "invested", r.Invested, 10000)
+	assertClose(t, "cash remaining", r.CashRemaining, 0)
+	assertClose(t, "fees", r.FeesPaid, 100)
+	assertClose(t, "final value", r.FinalValue, 9900)
+}
+
+func TestBacktestDrawdownIgnoresContributions(t *testing.T) {
+	// NAV turun 50% lalu naik ke 75: nilai portfolio tidak pernah di bawah puncaknya karena ada setoran
+	// baru, tetapi indeks time-weighted turun 50%
+	navs := navSeries(map[string]float64{"2024-01-15": 100, "2024-02-15": 50, "2024-03-15": 75})
+	r, err := RunBacktest(backtestFund, navs, nil, 3000, 0, mustDate("2024-01-15"), mustDate("2024-03-15"), BacktestMonthlyDCA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "max drawdown", r.MaxDrawdown, -0.5)
+	if r.MaxDrawdownPeak != "2024-01-15" || r.MaxDrawdownTrough != "2024-02-15" {
+		t.Errorf("drawdown %s -> %s, want 2024-01-15 -> 2024-02-15", r.MaxDrawdownPeak, r.MaxDrawdownTrough)
+	}
+	assertClose(t, "last drawdown", r.EquityCurve[2].Drawdown, -0.25)
+}
+
+func TestBacktestWithoutNav(t *testing.T) {
+	_, err := RunBacktest(backtestFund, nil, nil, 1000, 0, mustDate("2024-01-01"), mustDate("2024-02-01"), BacktestLumpSum)
+	if err != ErrNotEnoughHistory {
+		t.Errorf("err = %v, want ErrNotEnoughHistory", err)
+	}
+}
@@ -1,64 +1,106 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"golang/models"
-	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
-
-	"gorm.io/gorm"
 )
 
-// Fungsi ini mengambil data NAV dari Bareksa dan mengembalikan response body sebagai []byte
-func GetMutualFundNav(db *gorm.DB, id uint, cperiod, startdate, enddate string) ([]byte, error) {
+const bareksaNavEndpoint = "https://www.bareksa.com/ajax/mutualfund/nav/product1/"
 
-	// cek data murual fund
-	if id == 0 || cperiod == "" || startdate == "" || enddate == "" {
-		return nil, fmt.Errorf("invalid parameters: id=%d, cperiod=%s, startdate=%s, enddate=%s", id, cperiod, startdate, enddate)
-	}
+// BareksaProvider mengambil NAV dari API AJAX Bareksa memakai cperiod=custom
+type BareksaProvider struct {
+	Client *UpstreamClient
+}
 
-	// cek pid dari bareksa
-	var mutualFund models.MutualFund
+func NewBareksaProvider() *BareksaProvider {
+	return &BareksaProvider{Client: BareksaClient}
+}
 
-	if err := db.First(&mutualFund, id).Error; err != nil {
-		return nil, fmt.Errorf("mutual fund not found: %w", err)
-	}
+func (bp *BareksaProvider) Name() string {
+	return models.NavProviderBareksa
+}
 
-	log.Printf("Found mutual fund: %+v", mutualFund.PID)
+// FundID memakai external_id, atau PID untuk data lama yang belum punya external_id
+func (bp *BareksaProvider) FundID(fund models.MutualFund) string {
+	if fund.ExternalID != "" {
+		return fund.ExternalID
+	}
+	if fund.PID != 0 {
+		return fmt.Sprint(fund.PID)
+	}
+	return ""
+}
 
-	pid := fmt.Sprint(mutualFund.PID)
+func (bp *BareksaProvider) FetchNav(fund models.MutualFund, start, end time.Time) ([]NavPoint, error) {
+	pid := bp.FundID(fund)
 	if pid == "" {
-		return nil, fmt.Errorf("mutual fund with id %d has no PID", id)
+		return nil, fmt.Errorf("mutual fund with id %d has no Bareksa id", fund.ID)
 	}
 
-	url := fmt.Sprintf("https://www.bareksa.com/ajax/mutualfund/nav/product1/?id=%s&cperiod=%s&startdate=%s&enddate=%s",
-		pid, cperiod, startdate, enddate)
+	navURL := BareksaNavURL(pid, "custom", start.Format(dateLayout), end.Format(dateLayout))
 
-	log.Printf("Fetching NAV from URL: %s", url)
+	log.Printf("Fetching NAV from URL: %s", navURL)
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	req, err := http.NewRequest("GET", url, nil)
+	body, err := bp.Client.GetJSON(navURL, BareksaHeaders())
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Add("X-Requested-With", "XMLHttpRequest")
+	var response struct {
+		Status bool `json:"status"`
+		Data   struct {
+			Datas []struct {
+				PName string `json:"pname"`
+				Nav   []struct {
+					Date  string `json:"date"`
+					Value string `json:"value"`
+				} `json:"nav"`
+			} `json:"datas"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, &UpstreamError{Kind: ErrUpstreamMalformed, Err: err}
+	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+	if len(response.Data.Datas) == 0 {
+		return nil, nil
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+	var points []NavPoint
+	for _, nav := range response.Data.Datas[0].Nav {
+		date, err := time.Parse(dateLayout, nav.Date)
+		if err != nil {
+			continue
+		}
+		val, err := strconv.ParseFloat(nav.Value, 64)
+		if err != nil {
+			continue
+		}
+		points = append(points, NavPoint{Date: date, Nav: val})
 	}
 
-	return body, nil
+	return points, nil
+}
+
+// BareksaHeaders adalah header yang dibutuhkan endpoint AJAX Bareksa
+func BareksaHeaders() http.Header {
+	header := http.Header{}
+	header.Set("X-Requested-With", "XMLHttpRequest")
+	return header
+}
+
+// BareksaNavURL menyusun URL API NAV Bareksa dengan parameter yang sudah di-escape
+func BareksaNavURL(id, cperiod, startdate, enddate string) string {
+	q := url.Values{}
+	q.Set("id", id)
+	q.Set("cperiod", cperiod)
+	q.Set("startdate", startdate)
+	q.Set("enddate", enddate)
+	return bareksaNavEndpoint + "?" + q.Encode()
 }
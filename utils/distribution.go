@@ -0,0 +1,351 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"golang/models"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DistributionPoint adalah satu distribusi dividen dari provider, dalam bentuk yang sudah dinormalisasi
+type DistributionPoint struct {
+	ExDate        time.Time
+	PayDate       *time.Time
+	AmountPerUnit float64
+	// CASH atau REINVEST; kosong berarti CASH
+	PayoutType string
+}
+
+// DistributionProvider diimplementasikan oleh NavProvider yang juga punya data dividen
+type DistributionProvider interface {
+	// FetchDistributions mengambil distribusi dengan ex-date di rentang [start, end], terurut naik
+	FetchDistributions(fund models.MutualFund, start, end time.Time) ([]DistributionPoint, error)
+}
+
+// ErrNoDistributionProvider dikembalikan jika provider reksa dana tidak menyediakan data dividen
+var ErrNoDistributionProvider = errors.New("NAV provider has no distribution data")
+
+// ErrDistributionRecorded dikembalikan jika DIVIDEND manual dicatat pada ex-date atau tanggal bayar
+// distribusi reksa dana yang sudah dibuatkan DIVIDEND otomatis, sehingga dividennya terhitung dua kali
+var ErrDistributionRecorded = errors.New("dividend is already recorded from the fund distribution")
+
+// CheckManualDividend menolak DIVIDEND manual yang jatuh pada ex-date atau tanggal bayar distribusi
+// reksa dana yang sama dengan ErrDistributionRecorded
+func CheckManualDividend(db *gorm.DB, tx models.Transaction) error {
+	if tx.Type != models.TransactionDividend || tx.DistributionID != nil {
+		return nil
+	}
+	var dist models.FundDistribution
+	err := db.Where("mutual_fund_id = ? AND (ex_date = ? OR COALESCE(pay_date, ex_date) = ?)", tx.MutualFundID, tx.Date, tx.Date).
+		Order("ex_date ASC").Limit(1).Find(&dist).Error
+	if err != nil {
+		return err
+	}
+	if dist.ID != 0 {
+		return fmt.Errorf("%w: distribution %d on %s", ErrDistributionRecorded, dist.ID, dist.ExDate.Format(dateLayout))
+	}
+	return nil
+}
+
+// BuildDistributionTransactions membuat transaksi DIVIDEND dari distribusi reksa dana (terurut berdasarkan
+// ex-date) untuk ledger satu portfolio (terurut, tanpa DIVIDEND hasil distribusi). Unit yang berhak adalah
+// unit yang dimiliki sebelum ex-date, termasuk unit hasil distribusi sebelumnya. Nilai dividen dalam mata
+// uang reksa dana. Dividen REINVEST dibelikan unit baru dengan NAV dari navFor(tanggal bayar); distribusi
+// yang NAV-nya belum ada dilewati.
+func BuildDistributionTransactions(txs []models.Transaction, dists []models.FundDistribution, navFor func(time.Time) float64) []models.Transaction {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	var (
+		results []models.Transaction
+		units   float64
+		next    int
+	)
+	for _, dist := range dists {
+		exDate := DateOnly(dist.ExDate)
+		for next < len(txs) && DateOnly(txs[next].Date).Before(exDate) {
+			if !txs[next].Pending() {
+				units += txs[next].SignedUnits()
+			}
+			next++
+		}
+		if units <= unitEpsilon || dist.AmountPerUnit <= 0 {
+			continue
+		}
+
+		distID := dist.ID
+		tx := models.Transaction{
+			UserID:         txs[0].UserID,
+			PortfolioID:    txs[0].PortfolioID,
+			MutualFundID:   dist.MutualFundID,
+			Type:           models.TransactionDividend,
+			Date:           exDate,
+			Currency:       txs[0].Currency,
+			Amount:         units * dist.AmountPerUnit,
+			DistributionID: &distID,
+		}
+		if dist.PayDate != nil {
+			payDate := DateOnly(*dist.PayDate)
+			tx.SettlementDate = &payDate
+		}
+		if dist.PayoutType == models.DistributionPayoutReinvest {
+			nav := navFor(distributionNavDate(dist))
+			if nav <= 0 {
+				continue
+			}
+			tx.Nav = nav
+			tx.Units = tx.Amount / nav
+			units += tx.Units
+		}
+		results = append(results, tx)
+	}
+	return results
+}
+
+// distributionNavDate: dividen diinvestasikan ulang dengan NAV tanggal bayar, atau ex-date jika tidak ada
+func distributionNavDate(dist models.FundDistribution) time.Time {
+	if dist.PayDate != nil {
+		return DateOnly(*dist.PayDate)
+	}
+	return DateOnly(dist.ExDate)
+}
+
+// WithDistributions menambahkan DIVIDEND dari distribusi reksa dana ke ledger yang belum tersimpan
+// (misalnya satu pembelian), memakai deret NAV navs (terurut), lalu mengurutkan ulang berdasarkan tanggal
+func WithDistributions(txs []models.Transaction, dists []models.FundDistribution, navs []models.NavPrice) []models.Transaction {
+	navFor := func(date time.Time) float64 {
+		i := sort.Search(len(navs), func(i int) bool { return !navs[i].Date.Before(date) })
+		if i == len(navs) || navs[i].Date.After(date.AddDate(0, 0, navLookaheadDays)) {
+			return 0
+		}
+		return navs[i].Nav
+	}
+	generated := BuildDistributionTransactions(txs, dists, navFor)
+	if len(generated) == 0 {
+		return txs
+	}
+	all := append(append([]models.Transaction{}, txs...), generated...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Date.Before(all[j].Date) })
+	return all
+}
+
+// FundDistributions mengambil distribusi sebuah reksa dana, terurut berdasarkan ex-date
+func FundDistributions(db *gorm.DB, fundID uint) ([]models.FundDistribution, error) {
+	var dists []models.FundDistribution
+	err := db.Where("mutual_fund_id = ?", fundID).Order("ex_date ASC").Find(&dists).Error
+	return dists, err
+}
+
+// SyncDistributionTransactions menyamakan DIVIDEND hasil distribusi di ledger satu portfolio user dengan
+// distribusi reksa dana: membuat yang baru, memperbarui nilai/unit yang berubah, dan menghapus yang tidak
+// lagi berhak (misalnya pembelian sebelum ex-date dihapus). Dipanggil dari LedgerChanged.
+func SyncDistributionTransactions(db *gorm.DB, userID, portfolioID, fundID uint) error {
+	dists, err := FundDistributions(db, fundID)
+	if err != nil {
+		return err
+	}
+
+	txs, err := queryLedger(db, userID, portfolioID, fundID)
+	if err != nil {
+		return err
+	}
+	var manual []models.Transaction
+	existing := make(map[uint]models.Transaction)
+	for _, tx := range txs {
+		if tx.DistributionID != nil {
+			existing[*tx.DistributionID] = tx
+			continue
+		}
+		manual = append(manual, tx)
+	}
+	if len(dists) == 0 && len(existing) == 0 {
+		return nil
+	}
+
+	navFor := func(date time.Time) float64 {
+		nav, err := StoredNavForTradeDate(db, fundID, date)
+		if err != nil {
+			return 0
+		}
+		return nav.Nav
+	}
+	expected := BuildDistributionTransactions(manual, dists, navFor)
+
+	return db.Transaction(func(dbtx *gorm.DB) error {
+		for _, tx := range expected {
+			current, ok := existing[*tx.DistributionID]
+			delete(existing, *tx.DistributionID)
+			if !ok {
+				if err := dbtx.Create(&tx).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if current.Date.Equal(tx.Date) && current.Amount == tx.Amount && current.Units == tx.Units &&
+				current.Nav == tx.Nav && sameDate(current.SettlementDate, tx.SettlementDate) {
+				continue
+			}
+			if err := dbtx.Model(&models.Transaction{}).Where("id = ?", current.ID).Updates(map[string]interface{}{
+				"date": tx.Date, "settlement_date": tx.SettlementDate, "amount": tx.Amount, "units": tx.Units, "nav": tx.Nav,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		for _, stale := range existing {
+			if err := dbtx.Model(&models.Transaction{}).Where("id = ?", stale.ID).Update("deleted_at", time.Now()).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return DateOnly(*a).Equal(DateOnly(*b))
+}
+
+// ApplyFundDistributions menyinkronkan DIVIDEND hasil distribusi dan nilai harian semua portfolio yang
+// memegang reksa dana fundID mulai tanggal from. Kegagalan per portfolio dicatat, portfolio lain tetap
+// diproses, lalu error pertama dikembalikan supaya antrean mengulanginya.
+func ApplyFundDistributions(db *gorm.DB, fundID uint, from time.Time) error {
+	holders, err := ledgerHolders(db, 0, fundID)
+	if err != nil {
+		return err
+	}
+	var first error
+	for _, h := range holders {
+		if err := LedgerChanged(db, h.UserID, h.PortfolioID, fundID, from); err != nil {
+			log.Printf("Failed to apply distributions for user %d portfolio %d fund %d: %v", h.UserID, h.PortfolioID, fundID, err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// SaveFundDistribution menyimpan (atau mengganti, berdasarkan reksa dana dan ex-date) satu distribusi
+// lalu memperbarui ledger semua pemegang reksa dana tersebut
+func SaveFundDistribution(db *gorm.DB, dist *models.FundDistribution) error {
+	dist.ExDate = DateOnly(dist.ExDate)
+	if dist.PayoutType == "" {
+		dist.PayoutType = models.DistributionPayoutCash
+	}
+	if dist.Source == "" {
+		dist.Source = models.DistributionSourceAdmin
+	}
+
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "mutual_fund_id"}, {Name: "ex_date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"pay_date", "amount_per_unit", "payout_type", "source", "updated_at"}),
+	}).Create(dist).Error; err != nil {
+		return err
+	}
+	if err := db.Where("mutual_fund_id = ? AND ex_date = ?", dist.MutualFundID, dist.ExDate).First(dist).Error; err != nil {
+		return err
+	}
+
+	QueueFundValuationRefresh(db, dist.MutualFundID, dist.ExDate, true)
+	return nil
+}
+
+// DeleteFundDistribution menghapus distribusi beserta DIVIDEND yang dibuat darinya
+func DeleteFundDistribution(db *gorm.DB, dist models.FundDistribution) error {
+	if err := db.Delete(&dist).Error; err != nil {
+		return err
+	}
+	QueueFundValuationRefresh(db, dist.MutualFundID, dist.ExDate, true)
+	return nil
+}
+
+// SyncFundDistributions mengambil distribusi dengan ex-date di rentang [start, end] dari provider
+// reksa dana lalu menyimpannya. Distribusi yang diinput admin tidak ditimpa oleh data provider.
+// Mengembalikan distribusi yang diterima dari provider.
+func SyncFundDistributions(db *gorm.DB, fund models.MutualFund, start, end time.Time) ([]models.FundDistribution, error) {
+	provider, err := NavProviderFor(fund)
+	if err != nil {
+		return nil, err
+	}
+	source, ok := provider.(DistributionProvider)
+	if !ok {
+		return nil, ErrNoDistributionProvider
+	}
+
+	points, err := source.FetchDistributions(fund, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	dists := make([]models.FundDistribution, 0, len(points))
+	for _, p := range points {
+		payoutType := p.PayoutType
+		if payoutType == "" {
+			payoutType = models.DistributionPayoutCash
+		}
+		if !models.ValidPayoutType(payoutType) {
+			return nil, fmt.Errorf("invalid payout type %q on %s", p.PayoutType, p.ExDate.Format(dateLayout))
+		}
+		dists = append(dists, models.FundDistribution{
+			MutualFundID:  fund.ID,
+			ExDate:        DateOnly(p.ExDate),
+			PayDate:       p.PayDate,
+			AmountPerUnit: p.AmountPerUnit,
+			PayoutType:    payoutType,
+			Source:        provider.Name(),
+		})
+	}
+	if len(dists) == 0 {
+		return dists, nil
+	}
+
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "mutual_fund_id"}, {Name: "ex_date"}},
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Neq{Column: "fund_distributions.source", Value: models.DistributionSourceAdmin}}},
+		DoUpdates: clause.AssignmentColumns([]string{"pay_date", "amount_per_unit", "payout_type", "source", "updated_at"}),
+	}).Create(&dists).Error; err != nil {
+		return nil, fmt.Errorf("failed to store distributions: %w", err)
+	}
+
+	from := dists[0].ExDate
+	for _, dist := range dists {
+		if dist.ExDate.Before(from) {
+			from = dist.ExDate
+		}
+	}
+	QueueFundValuationRefresh(db, fund.ID, from, true)
+	return dists, nil
+}
+
+// reinvestmentAwaitingNav mencari distribusi REINVEST yang tanggal bayarnya baru mendapat NAV setelah
+// NAV rentang [from, to] tersimpan. Mengembalikan ex-date paling awal jika ada.
+func reinvestmentAwaitingNav(db *gorm.DB, fundID uint, from, to time.Time) (time.Time, bool, error) {
+	// NAV sebelum from sudah dipakai oleh distribusi yang tanggal bayarnya sampai tanggal tersebut
+	after := time.Time{}
+	var anchor models.NavPrice
+	err := db.Where("mutual_fund_id = ? AND date < ?", fundID, from).Order("date DESC").First(&anchor).Error
+	if err == nil {
+		after = anchor.Date
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, false, err
+	}
+
+	var dist models.FundDistribution
+	err = db.Where("mutual_fund_id = ? AND payout_type = ? AND COALESCE(pay_date, ex_date) > ? AND COALESCE(pay_date, ex_date) <= ?",
+		fundID, models.DistributionPayoutReinvest, after, to).Order("ex_date ASC").First(&dist).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return dist.ExDate, true, nil
+}
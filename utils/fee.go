@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"golang/models"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidFee dikembalikan jika teks biaya atau minimum investasi reksa dana tidak bisa dibaca
+var ErrInvalidFee = errors.New("invalid fee")
+
+// FeeParseError menunjukkan kolom mana yang gagal di-parse
+type FeeParseError struct {
+	Field string
+	Raw   string
+}
+
+func (e *FeeParseError) Error() string {
+	return fmt.Sprintf("invalid %s %q", e.Field, e.Raw)
+}
+
+func (e *FeeParseError) Unwrap() error {
+	return ErrInvalidFee
+}
+
+// feeMaxPrefixes adalah awalan yang menandakan biaya berupa batas atas
+var feeMaxPrefixes = []string{"maksimal", "maksimum", "maks.", "maks", "max.", "max", "up to", "hingga", "s.d.", "s/d"}
+
+// feeSuffixes adalah keterangan periode yang dibuang dari teks biaya
+var feeSuffixes = []string{"per tahun", "p.a.", "p.a", "/tahun", "/thn"}
+
+// rupiahUnits adalah singkatan satuan nominal yang umum dipakai
+var rupiahUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"juta", 1e6}, {"jt", 1e6}, {"ribu", 1e3}, {"rb", 1e3}, {"k", 1e3},
+}
+
+// isEmptyFeeText: teks kosong atau strip berarti biaya/minimum tidak dicantumkan
+func isEmptyFeeText(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || strings.Trim(s, "-–") == ""
+}
+
+// ParseFeeRate mengubah teks biaya dari Bareksa seperti "1%", "0,5 % p.a." atau "Max 2%" menjadi
+// desimal (1% = 0.01). Teks kosong atau "-" menghasilkan Rate nil, "gratis"/"free" berarti 0.
+func ParseFeeRate(raw string) (models.FeeRate, error) {
+	var fee models.FeeRate
+	s := strings.ToLower(strings.TrimSpace(raw))
+	if isEmptyFeeText(s) {
+		return fee, nil
+	}
+	if s == "gratis" || s == "free" {
+		zero := 0.0
+		fee.Rate = &zero
+		return fee, nil
+	}
+
+	for _, prefix := range feeMaxPrefixes {
+		if strings.HasPrefix(s, prefix) {
+			fee.IsMax = true
+			s = strings.TrimSpace(strings.TrimPrefix(s, prefix))
+			break
+		}
+	}
+	for _, suffix := range feeSuffixes {
+		s = strings.TrimSpace(strings.TrimSuffix(s, suffix))
+	}
+	s = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+
+	rate, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+	if err != nil || rate < 0 || rate > 100 {
+		return models.FeeRate{}, &FeeParseError{Field: "fee", Raw: raw}
+	}
+	rate /= 100
+	fee.Rate = &rate
+	return fee, nil
+}
+
+// ParseRupiah mengubah teks nominal seperti "Rp 100.000", "Rp10rb" atau "Rp 1,5 juta" menjadi
+// rupiah bulat. Teks kosong atau "-" menghasilkan nil.
+func ParseRupiah(raw string) (*int64, error) {
+	s := strings.ToLower(strings.TrimSpace(raw))
+	if isEmptyFeeText(s) {
+		return nil, nil
+	}
+	s = strings.TrimSpace(strings.TrimPrefix(s, "rp."))
+	s = strings.TrimSpace(strings.TrimPrefix(s, "rp"))
+
+	multiplier := 1.0
+	for _, unit := range rupiahUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.multiplier
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
+		}
+	}
+	s = strings.ReplaceAll(s, " ", "")
+	if strings.HasSuffix(s, ",-") {
+		s = strings.TrimSuffix(s, ",-")
+	}
+
+	s = normalizeDecimal(s, multiplier != 1)
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return nil, &FeeParseError{Field: "amount", Raw: raw}
+	}
+	amount := int64(math.Round(value * multiplier))
+	return &amount, nil
+}
+
+// normalizeDecimal mengubah angka berformat Indonesia atau Inggris menjadi format strconv. Pemisah
+// desimal ditentukan dulu sebelum pemisah ribuan dibuang: jika titik dan koma sama-sama ada, yang
+// terakhir adalah desimal. Satu jenis pemisah yang diikuti tepat tiga digit dianggap pemisah ribuan
+// ("100.000", "100,000"), kecuali ada pengali ("10.5 juta", "1,5 juta") yang berarti desimal.
+func normalizeDecimal(s string, hasMultiplier bool) string {
+	dot, comma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+	decimal := byte(0)
+	switch {
+	case dot >= 0 && comma >= 0:
+		if dot > comma {
+			decimal = '.'
+		} else {
+			decimal = ','
+		}
+	case dot >= 0 || comma >= 0:
+		sep, i := byte('.'), dot
+		if comma >= 0 {
+			sep, i = ',', comma
+		}
+		if hasMultiplier || strings.Count(s, string(sep)) == 1 && len(s)-i-1 != 3 {
+			decimal = sep
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == decimal && i == strings.LastIndexByte(s, decimal):
+			b.WriteByte('.')
+		case s[i] == '.' || s[i] == ',':
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// ApplyFundFees mengisi kolom biaya bertipe pada fund dari teks aslinya. Error dikembalikan
+// sebagai *FeeParseError dengan Field berisi nama kolom JSON yang gagal.
+func ApplyFundFees(fund *models.MutualFund) error {
+	minimum, err := ParseRupiah(fund.MinimumInvestment)
+	if err != nil {
+		return &FeeParseError{Field: "minimum_investment", Raw: fund.MinimumInvestment}
+	}
+
+	fees := []struct {
+		field string
+		raw   string
+		dst   *models.FeeRate
+	}{
+		{"management_fee", fund.ManagementFee, &fund.ManagementFeeRate},
+		{"custodian_fee", fund.ConsodiantFee, &fund.CustodianFeeRate},
+		{"switching_fee", fund.SwitchingFee, &fund.SwitchingFeeRate},
+	}
+	for _, f := range fees {
+		rate, err := ParseFeeRate(f.raw)
+		if err != nil {
+			return &FeeParseError{Field: f.field, Raw: f.raw}
+		}
+		*f.dst = rate
+	}
+
+	fund.MinimumInvestmentIDR = minimum
+	now := time.Now()
+	fund.FeesParsedAt = &now
+	return nil
+}
+
+// MigrateFundFees mengisi kolom biaya bertipe untuk reksa dana lama yang belum pernah di-parse
+// (fees_parsed_at kosong). Reksa dana yang teksnya tidak bisa dibaca dicatat di log sekali dan ikut
+// ditandai, sehingga startup berikutnya tidak memindai ulang; teksnya di-parse lagi saat diubah.
+func MigrateFundFees(db *gorm.DB) error {
+	var funds []models.MutualFund
+	if err := db.Where("fees_parsed_at IS NULL").Find(&funds).Error; err != nil {
+		return err
+	}
+
+	for i := range funds {
+		fund := &funds[i]
+		if err := ApplyFundFees(fund); err != nil {
+			log.Printf("Mutual fund %d has unparseable fees: %v", fund.ID, err)
+			if err := db.Model(fund).Update("fees_parsed_at", time.Now()).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err := db.Model(fund).Select(
+			"minimum_investment_idr",
+			"management_fee_rate", "management_fee_is_max",
+			"custodian_fee_rate", "custodian_fee_is_max",
+			"switching_fee_rate", "switching_fee_is_max",
+			"fees_parsed_at",
+		).Updates(fund).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"golang/models"
+)
+
+// FeeBreakdown merinci biaya yang sudah dibayar sebuah kepemilikan
+type FeeBreakdown struct {
+	SubscriptionFees float64 `json:"subscription_fees"`
+	RedemptionFees   float64 `json:"redemption_fees"`
+	SwitchingFees    float64 `json:"switching_fees"`
+	TransactionFees  float64 `json:"transaction_fees"`
+	// Perkiraan biaya manajemen + kustodian yang sudah terpotong dari NAV selama dimiliki.
+	// Hanya informasi: biaya ini sudah tercermin di NAV sehingga tidak mengurangi keuntungan lagi.
+	EstimatedFundExpenses float64 `json:"estimated_fund_expenses"`
+}
+
+// AnnualFeeEstimate adalah perkiraan biaya tahunan reksa dana atas nilai kepemilikan saat ini
+type AnnualFeeEstimate struct {
+	ManagementFeeRate float64 `json:"management_fee_rate"`
+	CustodianFeeRate  float64 `json:"custodian_fee_rate"`
+	ManagementFee     float64 `json:"management_fee"`
+	CustodianFee      float64 `json:"custodian_fee"`
+	Total             float64 `json:"total"`
+	// true jika salah satu tarif hanya batas atas ("Max 2%"), sehingga biaya sebenarnya bisa lebih kecil
+	IsUpperBound bool `json:"is_upper_bound"`
+}
+
+// FeeReport menampilkan keuntungan bruto (sebelum biaya transaksi) dan neto berdampingan
+type FeeReport struct {
+	Invested           float64           `json:"invested"`
+	GrossGain          float64           `json:"gross_gain"`
+	GrossReturnPercent float64           `json:"gross_return_percent"`
+	NetGain            float64           `json:"net_gain"`
+	NetReturnPercent   float64           `json:"net_return_percent"`
+	Fees               FeeBreakdown      `json:"fees"`
+	AnnualFeeCost      AnnualFeeEstimate `json:"annual_fee_cost"`
+}
+
+// BuildFeeReport menghitung rincian biaya dan keuntungan bruto/neto dari ledger satu reksa dana
+// dan hasil BuildDailyValuation-nya. Persentase dihitung terhadap total uang yang disetor.
+func BuildFeeReport(fund models.MutualFund, txs []models.Transaction, days []DailyValuation) FeeReport {
+	var report FeeReport
+	for _, tx := range txs {
+		if tx.Pending() {
+			continue
+		}
+		switch tx.Type {
+		case models.TransactionBuy:
+			report.Fees.SubscriptionFees += tx.Fee
+			report.Invested += tx.Amount
+		case models.TransactionSwitchIn:
+			report.Fees.SwitchingFees += tx.Fee
+			report.Invested += tx.Amount
+		case models.TransactionSell:
+			report.Fees.RedemptionFees += tx.Fee
+		case models.TransactionSwitchOut:
+			report.Fees.SwitchingFees += tx.Fee
+		}
+	}
+	report.Fees.TransactionFees = report.Fees.SubscriptionFees + report.Fees.RedemptionFees + report.Fees.SwitchingFees
+
+	annualRate := fund.ManagementFeeRate.Decimal() + fund.CustodianFeeRate.Decimal()
+	for i := 1; i < len(days); i++ {
+		held := days[i].Date.Sub(days[i-1].Date).Hours() / 24
+		report.Fees.EstimatedFundExpenses += days[i-1].Value * annualRate * held / 365
+	}
+
+	if len(days) > 0 {
+		last := days[len(days)-1]
+		report.NetGain = last.TotalGain
+		report.GrossGain = last.GrossTotalGain
+
+		estimate := &report.AnnualFeeCost
+		estimate.ManagementFeeRate = fund.ManagementFeeRate.Decimal()
+		estimate.CustodianFeeRate = fund.CustodianFeeRate.Decimal()
+		estimate.ManagementFee = last.Value * estimate.ManagementFeeRate
+		estimate.CustodianFee = last.Value * estimate.CustodianFeeRate
+		estimate.Total = estimate.ManagementFee + estimate.CustodianFee
+		estimate.IsUpperBound = fund.ManagementFeeRate.IsMax || fund.CustodianFeeRate.IsMax
+	}
+	if report.Invested > 0 {
+		report.NetReturnPercent = report.NetGain / report.Invested * 100
+		report.GrossReturnPercent = report.GrossGain / report.Invested * 100
+	}
+	return report
+}
@@ -0,0 +1,326 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"golang/models"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrFxRateNotAvailable dikembalikan jika kurs sebuah mata uang belum tersedia untuk tanggal yang diminta
+var ErrFxRateNotAvailable = errors.New("FX rate is not available")
+
+// fxLookbackDays: tanggal tanpa kurs (akhir pekan, libur) memakai kurs terakhir dalam rentang ini
+const fxLookbackDays = 10
+
+// FxPoint adalah kurs 1 unit mata uang dalam rupiah pada satu tanggal
+type FxPoint struct {
+	Date time.Time
+	Rate float64
+}
+
+// FxProvider adalah sumber kurs mata uang terhadap rupiah
+type FxProvider interface {
+	// Name disimpan di fx_rates.source
+	Name() string
+	// FetchRates mengambil kurs currency untuk rentang [start, end], terurut naik
+	FetchRates(currency string, start, end time.Time) ([]FxPoint, error)
+}
+
+var (
+	fxProviderMu sync.RWMutex
+	fxProvider   FxProvider
+)
+
+// RegisterFxProviderFromEnv mengaktifkan FileFxProvider jika FX_FILE_DIR diisi
+func RegisterFxProviderFromEnv() {
+	if dir := os.Getenv("FX_FILE_DIR"); dir != "" {
+		SetFxProvider(NewFileFxProvider(dir))
+	}
+}
+
+// SetFxProvider mengganti sumber kurs yang dipakai untuk melengkapi fx_rates
+func SetFxProvider(p FxProvider) {
+	fxProviderMu.Lock()
+	defer fxProviderMu.Unlock()
+	fxProvider = p
+}
+
+// CurrentFxProvider mengembalikan sumber kurs yang aktif, nil jika belum dikonfigurasi
+func CurrentFxProvider() FxProvider {
+	fxProviderMu.RLock()
+	defer fxProviderMu.RUnlock()
+	return fxProvider
+}
+
+// FileFxProvider membaca kurs dari direktori lokal sebagai pengganti sumber kurs sungguhan.
+// Setiap mata uang disimpan sebagai <dir>/<CURRENCY>.csv (kolom: date,rate), nilainya rupiah per 1 unit.
+type FileFxProvider struct {
+	Dir string
+}
+
+func NewFileFxProvider(dir string) *FileFxProvider {
+	return &FileFxProvider{Dir: dir}
+}
+
+func (fp *FileFxProvider) Name() string {
+	return "file"
+}
+
+func (fp *FileFxProvider) FetchRates(currency string, start, end time.Time) ([]FxPoint, error) {
+	code, err := NormalizeCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	// Formatnya sama dengan file NAV (date,nav), kolom kedua berisi kurs
+	points, err := readNavCSV(filepath.Join(fp.Dir, code+".csv"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].Date.Before(points[j].Date) })
+	var rates []FxPoint
+	for _, p := range filterNavRange(points, start, end) {
+		rates = append(rates, FxPoint{Date: p.Date, Rate: p.Nav})
+	}
+	return rates, nil
+}
+
+// NormalizeCurrency memvalidasi kode mata uang 3 huruf dan mengubahnya ke huruf besar.
+// Kode kosong berarti rupiah.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return models.DefaultCurrency, nil
+	}
+	if len(code) != 3 {
+		return "", fmt.Errorf("invalid currency code %q", code)
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", fmt.Errorf("invalid currency code %q", code)
+		}
+	}
+	return code, nil
+}
+
+// FundCurrency mengembalikan mata uang sebuah reksa dana
+func FundCurrency(db *gorm.DB, fundID uint) (string, error) {
+	var fund models.MutualFund
+	if err := db.Select("id", "currency").First(&fund, fundID).Error; err != nil {
+		return "", err
+	}
+	if fund.Currency == "" {
+		return models.DefaultCurrency, nil
+	}
+	return fund.Currency, nil
+}
+
+// CurrencyChange adalah hasil SetFundCurrency: jumlah baris yang ditandai ulang
+type CurrencyChange struct {
+	Fund         models.MutualFund `json:"fund"`
+	Transactions int64             `json:"transactions"`
+	Portfolios   int64             `json:"portfolios"`
+}
+
+// SetFundCurrency mengganti mata uang reksa dana beserta mata uang semua transaksi dan my_portfolios-nya
+// dalam satu transaksi database, supaya ledger tidak bercampur mata uang. Nilainya tidak dikonversi:
+// ini koreksi untuk reksa dana yang sejak awal tercatat dengan mata uang yang salah. Nilai harian semua
+// pemegangnya dihitung ulang di background.
+func SetFundCurrency(db *gorm.DB, fundID uint, currency string) (*CurrencyChange, error) {
+	change := &CurrencyChange{}
+	err := db.Transaction(func(dbtx *gorm.DB) error {
+		if err := dbtx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&change.Fund, fundID).Error; err != nil {
+			return err
+		}
+		if err := dbtx.Model(&change.Fund).Update("currency", currency).Error; err != nil {
+			return err
+		}
+		result := dbtx.Model(&models.Transaction{}).Where("mutual_fund_id = ? AND currency <> ?", fundID, currency).
+			Update("currency", currency)
+		if result.Error != nil {
+			return result.Error
+		}
+		change.Transactions = result.RowsAffected
+		result = dbtx.Model(&models.MyPortfolio{}).Where("mutual_fund_id = ? AND currency <> ?", fundID, currency).
+			Update("currency", currency)
+		if result.Error != nil {
+			return result.Error
+		}
+		change.Portfolios = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var first struct {
+		Date *time.Time
+	}
+	if err := db.Model(&models.Transaction{}).Select("MIN(date) AS date").
+		Where("mutual_fund_id = ? AND deleted_at IS NULL", fundID).Scan(&first).Error; err != nil {
+		return nil, err
+	}
+	if first.Date != nil {
+		QueueFundValuationRefresh(db, fundID, *first.Date, false)
+	}
+	return change, nil
+}
+
+// SaveFxRates menyimpan kurs (menimpa kurs tanggal yang sama)
+func SaveFxRates(db *gorm.DB, rates []models.FxRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "fetched_at"}),
+	}).Create(&rates).Error
+}
+
+// SyncFxRates mengambil kurs currency untuk rentang [start, end] dari provider lalu menyimpannya
+func SyncFxRates(db *gorm.DB, currency string, start, end time.Time) ([]models.FxRate, error) {
+	provider := CurrentFxProvider()
+	if provider == nil {
+		return nil, fmt.Errorf("%w: no FX provider configured", ErrFxRateNotAvailable)
+	}
+
+	points, err := provider.FetchRates(currency, start, end)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	rates := make([]models.FxRate, 0, len(points))
+	for _, p := range points {
+		if p.Rate <= 0 {
+			continue
+		}
+		rates = append(rates, models.FxRate{Currency: currency, Date: DateOnly(p.Date), Rate: p.Rate, Source: provider.Name(), FetchedAt: now})
+	}
+	if err := SaveFxRates(db, rates); err != nil {
+		return nil, fmt.Errorf("failed to store FX rates: %w", err)
+	}
+	if err := db.Create(&models.FxFetch{Currency: currency, StartDate: DateOnly(start), EndDate: DateOnly(end), FetchedAt: now}).Error; err != nil {
+		log.Printf("Failed to record FX fetch of %s: %v", currency, err)
+	}
+	return rates, nil
+}
+
+// FxConverter mengonversi nilai antar mata uang memakai kurs harian yang sudah dimuat
+type FxConverter struct {
+	rates map[string][]models.FxRate
+}
+
+// LoadFxConverter memuat kurs setiap mata uang untuk rentang [start, end] dari fx_rates. Hari kerja yang
+// belum tersimpan dan belum pernah diminta diambil dulu dari provider kurs (lihat missingDateRanges);
+// jika provider gagal, kurs tersimpan tetap dipakai. Tanggal tanpa kurs memakai kurs terakhir sebelumnya.
+func LoadFxConverter(db *gorm.DB, currencies []string, start, end time.Time) (*FxConverter, error) {
+	fx := &FxConverter{rates: map[string][]models.FxRate{}}
+	from, to := DateOnly(start).AddDate(0, 0, -fxLookbackDays), DateOnly(end)
+	for _, currency := range currencies {
+		if currency == models.DefaultCurrency {
+			continue
+		}
+		if _, ok := fx.rates[currency]; ok {
+			continue
+		}
+
+		rates, err := storedFxRates(db, currency, from, to)
+		if err != nil {
+			return nil, err
+		}
+		if CurrentFxProvider() != nil {
+			var fetches []models.FxFetch
+			if err := db.Where("currency = ? AND start_date <= ? AND end_date >= ?", currency, to, from).
+				Find(&fetches).Error; err != nil {
+				return nil, err
+			}
+			have := make(map[time.Time]bool, len(rates))
+			for _, r := range rates {
+				have[DateOnly(r.Date)] = true
+			}
+			ranges := make([]fetchedRange, len(fetches))
+			for i, f := range fetches {
+				ranges[i] = fetchedRange{Start: f.StartDate, End: f.EndDate, FetchedAt: f.FetchedAt}
+			}
+
+			gaps := missingDateRanges(from, to, time.Now(), have, ranges)
+			for _, gap := range gaps {
+				if _, err := SyncFxRates(db, currency, gap[0], gap[1]); err != nil {
+					log.Printf("Serving stored FX rates for %s, provider failed: %v", currency, err)
+					break
+				}
+			}
+			if len(gaps) > 0 {
+				if rates, err = storedFxRates(db, currency, from, to); err != nil {
+					return nil, err
+				}
+			}
+		}
+		fx.rates[currency] = rates
+	}
+	return fx, nil
+}
+
+func storedFxRates(db *gorm.DB, currency string, from, to time.Time) ([]models.FxRate, error) {
+	var rates []models.FxRate
+	err := db.Where("currency = ? AND date BETWEEN ? AND ?", currency, from, to).Order("date ASC").Find(&rates).Error
+	return rates, err
+}
+
+// idrRate mengembalikan kurs rupiah untuk 1 currency pada tanggal date (kurs terakhir sampai date)
+func (fx *FxConverter) idrRate(currency string, date time.Time) (float64, bool) {
+	if currency == models.DefaultCurrency || currency == "" {
+		return 1, true
+	}
+	rates := fx.rates[currency]
+	day := DateOnly(date)
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Date.After(day) })
+	if i == 0 || rates[i-1].Date.Before(day.AddDate(0, 0, -fxLookbackDays)) {
+		return 0, false
+	}
+	return rates[i-1].Rate, true
+}
+
+// Rate mengembalikan kurs untuk mengubah 1 unit from menjadi to pada tanggal date
+func (fx *FxConverter) Rate(from, to string, date time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	fromRate, ok := fx.idrRate(from, date)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s on %s", ErrFxRateNotAvailable, from, DateOnly(date).Format(dateLayout))
+	}
+	toRate, ok := fx.idrRate(to, date)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s on %s", ErrFxRateNotAvailable, to, DateOnly(date).Format(dateLayout))
+	}
+	return fromRate / toRate, nil
+}
+
+// ConvertAmount mengubah amount dalam mata uang from menjadi mata uang to dengan kurs tanggal date,
+// memuat kurs yang diperlukan dari fx_rates (atau provider)
+func ConvertAmount(db *gorm.DB, amount float64, from, to string, date time.Time) (float64, error) {
+	if from == to {
+		return amount, nil
+	}
+	fx, err := LoadFxConverter(db, []string{from, to}, date, date)
+	if err != nil {
+		return 0, err
+	}
+	rate, err := fx.Rate(from, to, date)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"golang/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ConvertValuations mengubah ledger dan nilai harian setiap reksa dana ke mata uang laporan currency.
+// Transaksi dikonversi dengan kurs tanggal transaksi dan NAV dengan kurs tanggal NAV, sehingga biaya
+// perolehan dan keuntungan terealisasi memakai kurs historis. Selisih antara keuntungan hasil konversi
+// dan keuntungan dalam mata uang reksa dana yang dinilai dengan kurs hari itu dicatat sebagai FxGain.
+// Reksa dana yang mata uangnya sama dengan currency tidak diubah.
+func ConvertValuations(db *gorm.DB, valuations []FundValuation, currency string) ([]FundValuation, error) {
+	if len(valuations) == 0 {
+		return valuations, nil
+	}
+
+	fundIDs := make([]uint, 0, len(valuations))
+	for _, fv := range valuations {
+		fundIDs = append(fundIDs, fv.MutualFundID)
+	}
+	var funds []models.MutualFund
+	if err := db.Select("id", "currency").Where("id IN ?", fundIDs).Find(&funds).Error; err != nil {
+		return nil, err
+	}
+	fundCurrency := make(map[uint]string, len(funds))
+	for _, fund := range funds {
+		fundCurrency[fund.ID] = fund.Currency
+	}
+
+	// Kurs hanya dimuat untuk rentang yang dipakai reksa dana bermata uang lain
+	currencies := []string{currency}
+	var start, end time.Time
+	for _, fv := range valuations {
+		from := fundCurrency[fv.MutualFundID]
+		if from == "" || from == currency {
+			continue
+		}
+		currencies = append(currencies, from)
+		for _, tx := range fv.Transactions {
+			if start.IsZero() || tx.Date.Before(start) {
+				start = tx.Date
+			}
+		}
+		for _, nav := range fv.Navs {
+			if start.IsZero() || nav.Date.Before(start) {
+				start = nav.Date
+			}
+			if nav.Date.After(end) {
+				end = nav.Date
+			}
+		}
+	}
+
+	converted := make([]FundValuation, len(valuations))
+	if len(currencies) == 1 {
+		for i, fv := range valuations {
+			fv.Currency = currency
+			converted[i] = fv
+		}
+		return converted, nil
+	}
+
+	fx, err := LoadFxConverter(db, currencies, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for i, fv := range valuations {
+		from := fundCurrency[fv.MutualFundID]
+		if from == "" || from == currency {
+			fv.Currency = currency
+			converted[i] = fv
+			continue
+		}
+		result, err := convertFundValuation(fv, from, currency, fx)
+		if err != nil {
+			return nil, err
+		}
+		converted[i] = result
+	}
+	return converted, nil
+}
+
+func convertFundValuation(fv FundValuation, from, to string, fx *FxConverter) (FundValuation, error) {
+	txs := make([]models.Transaction, len(fv.Transactions))
+	for i, tx := range fv.Transactions {
+		rate, err := fx.Rate(from, to, tx.Date)
+		if err != nil {
+			return fv, err
+		}
+		tx.Currency = to
+		tx.Amount *= rate
+		tx.Fee *= rate
+		tx.Nav *= rate
+		txs[i] = tx
+	}
+
+	navs := make([]models.NavPrice, len(fv.Navs))
+	rates := make(map[time.Time]float64, len(fv.Navs))
+	for i, nav := range fv.Navs {
+		rate, err := fx.Rate(from, to, nav.Date)
+		if err != nil {
+			return fv, err
+		}
+		rates[nav.Date] = rate
+		nav.Nav *= rate
+		navs[i] = nav
+	}
+
+	// Lot dicocokkan per portfolio seperti SyncRealizedGains, lalu nilai harian dijumlahkan per tanggal
+	byPortfolio := make(map[uint][]int)
+	var portfolioIDs []uint
+	for i, tx := range txs {
+		if _, ok := byPortfolio[tx.PortfolioID]; !ok {
+			portfolioIDs = append(portfolioIDs, tx.PortfolioID)
+		}
+		byPortfolio[tx.PortfolioID] = append(byPortfolio[tx.PortfolioID], i)
+	}
+	byDate := make(map[time.Time]*DailyValuation)
+	for _, portfolioID := range portfolioIDs {
+		var (
+			pos    Position
+			ledger []models.Transaction
+		)
+		for _, i := range byPortfolio[portfolioID] {
+			matches := pos.Apply(txs[i])
+			if (txs[i].Type == models.TransactionSell || txs[i].Type == models.TransactionSwitchOut) && !txs[i].Pending() {
+				txs[i].CostBasis = 0
+				for _, m := range matches {
+					txs[i].CostBasis += m.CostBasis
+				}
+				txs[i].RealizedGain = txs[i].Amount - txs[i].Fee - txs[i].CostBasis
+			}
+			ledger = append(ledger, txs[i])
+		}
+		for _, day := range BuildDailyValuation(ledger, navs) {
+			point, ok := byDate[day.Date]
+			if !ok {
+				point = &DailyValuation{Date: day.Date, Nav: day.Nav}
+				byDate[day.Date] = point
+			}
+			point.Units += day.Units
+			point.CostBasis += day.CostBasis
+			point.Value += day.Value
+			point.CashIn += day.CashIn
+			point.CashOut += day.CashOut
+			point.DailyGain += day.DailyGain
+			point.TotalGain += day.TotalGain
+			point.Fees += day.Fees
+			point.GrossTotalGain += day.GrossTotalGain
+			point.Dividends += day.Dividends
+		}
+	}
+
+	local := make(map[time.Time]DailyValuation, len(fv.Days))
+	for _, day := range fv.Days {
+		local[day.Date] = day
+	}
+	days := make([]DailyValuation, 0, len(byDate))
+	for _, point := range byDate {
+		if day, ok := local[point.Date]; ok {
+			point.FxGain = point.TotalGain - day.TotalGain*rates[point.Date]
+		}
+		days = append(days, *point)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date.Before(days[j].Date) })
+	var prevNav, prevValue float64
+	for i := range days {
+		if prevNav != 0 {
+			days[i].NavChange = days[i].Nav - prevNav
+			days[i].NavChangePercent = days[i].NavChange / prevNav * 100
+		}
+		if base := prevValue + days[i].CashIn; base != 0 {
+			days[i].DailyGainPercent = days[i].DailyGain / base * 100
+		}
+		prevNav, prevValue = days[i].Nav, days[i].Value
+	}
+
+	fv.Currency = to
+	fv.Transactions = txs
+	fv.Navs = navs
+	fv.Days = days
+	return fv, nil
+}
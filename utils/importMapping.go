@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang/models"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ImportMapping memetakan kolom file CSV ekspor satu sumber (marketplace atau aplikasi bank) ke
+// field transaksi. Nama kolom dicocokkan dengan header tanpa membedakan huruf besar/kecil.
+type ImportMapping struct {
+	Source string `json:"source"`
+	// Pemisah kolom, default koma
+	Delimiter string `json:"delimiter"`
+	// Angka memakai koma desimal dan titik ribuan (1.234,56)
+	DecimalComma bool     `json:"decimal_comma"`
+	DateLayouts  []string `json:"date_layouts"`
+
+	DateColumn     string `json:"date_column"`
+	FundNameColumn string `json:"fund_name_column"`
+	// Kolom kode reksa dana (PID Bareksa atau external_id), opsional
+	FundCodeColumn string `json:"fund_code_column"`
+	TypeColumn     string `json:"type_column"`
+	AmountColumn   string `json:"amount_column"`
+	UnitsColumn    string `json:"units_column"`
+	NavColumn      string `json:"nav_column"`
+	FeeColumn      string `json:"fee_column"`
+
+	// Teks jenis transaksi di file (huruf kecil) ke tipe ledger; tipe ledger sendiri (BUY, SELL, ...)
+	// selalu dikenali
+	TypeValues map[string]models.TransactionType `json:"type_values"`
+	// Tipe yang dipakai jika file tidak punya kolom jenis transaksi
+	DefaultType models.TransactionType `json:"default_type"`
+}
+
+// Kolom bawaan mengikuti header file ekspor masing-masing sumber; bisa diganti atau ditambah
+// lewat file JSON di IMPORT_MAPPINGS_FILE (lihat RegisterImportMappingsFromEnv)
+var (
+	importMappingsMu sync.RWMutex
+	importMappings   = map[string]ImportMapping{}
+)
+
+func init() {
+	RegisterImportMapping(ImportMapping{
+		Source:         "generic",
+		DateLayouts:    []string{"2006-01-02"},
+		DateColumn:     "date",
+		FundNameColumn: "fund_name",
+		FundCodeColumn: "fund_code",
+		TypeColumn:     "type",
+		AmountColumn:   "amount",
+		UnitsColumn:    "units",
+		NavColumn:      "nav",
+		FeeColumn:      "fee",
+	})
+	RegisterImportMapping(ImportMapping{
+		Source:         "bareksa",
+		DecimalComma:   true,
+		DateLayouts:    []string{"02/01/2006", "2006-01-02", "2 Jan 2006"},
+		DateColumn:     "tanggal transaksi",
+		FundNameColumn: "nama produk",
+		TypeColumn:     "jenis transaksi",
+		AmountColumn:   "nominal",
+		UnitsColumn:    "unit",
+		NavColumn:      "nab/unit",
+		FeeColumn:      "biaya",
+		TypeValues: map[string]models.TransactionType{
+			"pembelian":         models.TransactionBuy,
+			"penjualan":         models.TransactionSell,
+			"pengalihan masuk":  models.TransactionSwitchIn,
+			"pengalihan keluar": models.TransactionSwitchOut,
+			"dividen":           models.TransactionDividend,
+		},
+	})
+	RegisterImportMapping(ImportMapping{
+		Source:         "bibit",
+		DateLayouts:    []string{"2006-01-02", "02 Jan 2006", "2 Jan 2006"},
+		DateColumn:     "date",
+		FundNameColumn: "product",
+		TypeColumn:     "transaction type",
+		AmountColumn:   "amount",
+		UnitsColumn:    "units",
+		NavColumn:      "nav",
+		FeeColumn:      "fee",
+		TypeValues: map[string]models.TransactionType{
+			"buy":        models.TransactionBuy,
+			"sell":       models.TransactionSell,
+			"switch in":  models.TransactionSwitchIn,
+			"switch out": models.TransactionSwitchOut,
+			"dividend":   models.TransactionDividend,
+		},
+	})
+	RegisterImportMapping(ImportMapping{
+		Source:         "bank",
+		Delimiter:      ";",
+		DecimalComma:   true,
+		DateLayouts:    []string{"02/01/2006", "02-01-2006", "2006-01-02"},
+		DateColumn:     "tanggal",
+		FundNameColumn: "nama reksa dana",
+		TypeColumn:     "jenis",
+		AmountColumn:   "nilai transaksi",
+		UnitsColumn:    "jumlah unit",
+		NavColumn:      "nab",
+		FeeColumn:      "biaya",
+		TypeValues: map[string]models.TransactionType{
+			"subscription":  models.TransactionBuy,
+			"redemption":    models.TransactionSell,
+			"switching in":  models.TransactionSwitchIn,
+			"switching out": models.TransactionSwitchOut,
+			"pembelian":     models.TransactionBuy,
+			"penjualan":     models.TransactionSell,
+		},
+	})
+}
+
+// RegisterImportMappingsFromEnv membaca mapping tambahan dari file JSON (array ImportMapping) di
+// IMPORT_MAPPINGS_FILE. Mapping dengan source yang sama menggantikan mapping bawaan.
+func RegisterImportMappingsFromEnv() {
+	path := os.Getenv("IMPORT_MAPPINGS_FILE")
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read import mappings %s: %v", path, err)
+		return
+	}
+	var mappings []ImportMapping
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		log.Printf("Invalid import mappings %s: %v", path, err)
+		return
+	}
+	for _, m := range mappings {
+		if err := m.validate(); err != nil {
+			log.Printf("Skipping import mapping %q: %v", m.Source, err)
+			continue
+		}
+		RegisterImportMapping(m)
+	}
+}
+
+func (m ImportMapping) validate() error {
+	switch {
+	case m.Source == "":
+		return fmt.Errorf("source is required")
+	case m.DateColumn == "":
+		return fmt.Errorf("date_column is required")
+	case m.FundNameColumn == "" && m.FundCodeColumn == "":
+		return fmt.Errorf("fund_name_column or fund_code_column is required")
+	case m.AmountColumn == "" && m.UnitsColumn == "":
+		return fmt.Errorf("amount_column or units_column is required")
+	case len([]rune(m.Delimiter)) > 1:
+		return fmt.Errorf("delimiter must be a single character")
+	case m.DefaultType != "" && !models.ValidTransactionType(m.DefaultType):
+		return fmt.Errorf("invalid default_type %q", m.DefaultType)
+	}
+	for text, t := range m.TypeValues {
+		if !models.ValidTransactionType(t) {
+			return fmt.Errorf("invalid transaction type %q for type value %q", t, text)
+		}
+	}
+	return nil
+}
+
+// RegisterImportMapping mendaftarkan (atau mengganti) mapping berdasarkan source
+func RegisterImportMapping(m ImportMapping) {
+	importMappingsMu.Lock()
+	defer importMappingsMu.Unlock()
+	m.Source = strings.ToLower(m.Source)
+	if len(m.DateLayouts) == 0 {
+		m.DateLayouts = []string{dateLayout}
+	}
+	if m.DefaultType == "" {
+		m.DefaultType = models.TransactionBuy
+	}
+	typeValues := make(map[string]models.TransactionType, len(m.TypeValues))
+	for text, t := range m.TypeValues {
+		typeValues[normalizeImportText(text)] = t
+	}
+	m.TypeValues = typeValues
+	importMappings[m.Source] = m
+}
+
+// GetImportMapping mencari mapping yang terdaftar berdasarkan source
+func GetImportMapping(source string) (ImportMapping, error) {
+	importMappingsMu.RLock()
+	defer importMappingsMu.RUnlock()
+	m, ok := importMappings[strings.ToLower(source)]
+	if !ok {
+		return ImportMapping{}, fmt.Errorf("unknown import source %q", source)
+	}
+	return m, nil
+}
+
+// ImportSources mengembalikan semua mapping yang terdaftar, terurut berdasarkan source
+func ImportSources() []ImportMapping {
+	importMappingsMu.RLock()
+	defer importMappingsMu.RUnlock()
+	mappings := make([]ImportMapping, 0, len(importMappings))
+	for _, m := range importMappings {
+		mappings = append(mappings, m)
+	}
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].Source < mappings[j].Source })
+	return mappings
+}
@@ -0,0 +1,700 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"golang/models"
+	"log"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// unitEpsilon adalah sisa unit yang dianggap nol (pembulatan floating point)
+const unitEpsilon = 1e-9
+
+// unitCheckTolerance adalah selisih pembulatan unit yang masih diterima saat memeriksa penjualan
+const unitCheckTolerance = 1e-6
+
+// ApplyTradeNav melengkapi NAV, unit dan nilai transaksi dari NAV tanggal transaksi.
+// Untuk BUY/SWITCH_IN unit dihitung dari Amount - Fee; untuk SELL/SWITCH_OUT salah satu dari
+// Units atau Amount boleh diisi dan yang lain dihitung. Jika NAV belum terbit, transaksi
+// dibiarkan pending dan ErrNavNotAvailable dikembalikan.
+func ApplyTradeNav(db *gorm.DB, tx *models.Transaction) error {
+	if tx.Type == models.TransactionDividend {
+		return nil
+	}
+
+	if tx.Nav <= 0 {
+		nav, err := NavForTradeDate(db, tx.MutualFundID, tradeNavDate(*tx))
+		if err != nil {
+			return err
+		}
+		if nav.Nav <= 0 {
+			return fmt.Errorf("invalid NAV %f on %s", nav.Nav, nav.Date.Format(dateLayout))
+		}
+		tx.Nav = nav.Nav
+	}
+
+	switch tx.Type {
+	case models.TransactionBuy, models.TransactionSwitchIn:
+		if tx.Amount > 0 {
+			tx.Units = (tx.Amount - tx.Fee) / tx.Nav
+		} else {
+			tx.Amount = tx.Units*tx.Nav + tx.Fee
+		}
+	case models.TransactionSell, models.TransactionSwitchOut:
+		if tx.Units > 0 {
+			tx.Amount = tx.Units * tx.Nav
+		} else {
+			tx.Units = tx.Amount / tx.Nav
+		}
+	}
+	return nil
+}
+
+// tradeNavDate: SWITCH_IN memakai NAV tanggal settlement, transaksi lain memakai tanggal transaksi
+func tradeNavDate(tx models.Transaction) time.Time {
+	if tx.Type == models.TransactionSwitchIn && tx.SettlementDate != nil {
+		return *tx.SettlementDate
+	}
+	return tx.Date
+}
+
+// FillPendingTransactions menghitung transaksi yang sebelumnya belum punya NAV lalu menyimpannya.
+// Mengembalikan jumlah transaksi yang berhasil dilengkapi.
+func FillPendingTransactions(db *gorm.DB, txs []models.Transaction) int {
+	filled := 0
+	for i := range txs {
+		tx := &txs[i]
+		if !tx.Pending() {
+			continue
+		}
+		if err := ApplyTradeNav(db, tx); err != nil {
+			if !errors.Is(err, ErrNavNotAvailable) {
+				log.Printf("Failed to apply NAV for transaction %d: %v", tx.ID, err)
+			}
+			continue
+		}
+		if err := db.Model(tx).Select("nav", "units", "amount").Updates(tx).Error; err != nil {
+			log.Printf("Failed to save transaction %d: %v", tx.ID, err)
+			continue
+		}
+		filled++
+	}
+	return filled
+}
+
+// MigrateLegacyPortfolios memindahkan baris my_portfolios menjadi transaksi BUY. Aman dijalankan
+// berulang karena setiap baris hanya dimigrasi sekali (legacy_portfolio_id unik). Unit dihitung
+// dari NAV yang sudah tersimpan; yang belum ada NAV-nya akan dihitung saat ledger dibuka.
+func MigrateLegacyPortfolios(db *gorm.DB) error {
+	var portfolios []models.MyPortfolio
+	if err := db.Where("deleted_at IS NULL AND id NOT IN (?)",
+		db.Model(&models.Transaction{}).Select("legacy_portfolio_id").Where("legacy_portfolio_id IS NOT NULL"),
+	).Find(&portfolios).Error; err != nil {
+		return err
+	}
+	if len(portfolios) == 0 {
+		return nil
+	}
+
+	txs := make([]models.Transaction, 0, len(portfolios))
+	for _, p := range portfolios {
+		legacyID := p.ID
+		tx := models.Transaction{
+			UserID:            p.UserID,
+			MutualFundID:      p.MutualFundID,
+			Type:              models.TransactionBuy,
+			Date:              DateOnly(p.Date),
+			Currency:          p.Currency,
+			Amount:            p.Value,
+			Nav:               p.Nav,
+			Units:             p.Units,
+			LegacyPortfolioID: &legacyID,
+		}
+		if tx.Nav <= 0 {
+			if nav, err := StoredNavForTradeDate(db, p.MutualFundID, p.Date); err == nil && nav.Nav > 0 {
+				tx.Nav = nav.Nav
+				tx.Units = tx.Amount / nav.Nav
+			}
+		}
+		txs = append(txs, tx)
+	}
+
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&txs).Error; err != nil {
+		return err
+	}
+	log.Printf("Migrated %d my_portfolios rows into BUY transactions", len(txs))
+	return nil
+}
+
+// Lot adalah sisa satu pembelian (BUY, SWITCH_IN, atau dividen yang diinvestasikan ulang)
+type Lot struct {
+	TransactionID uint      `json:"transaction_id"`
+	Date          time.Time `json:"date"`
+	Nav           float64   `json:"nav"`
+	OriginalUnits float64   `json:"original_units"`
+	Units         float64   `json:"units"`
+	CostBasis     float64   `json:"cost_basis"`
+}
+
+// Position adalah posisi satu reksa dana hasil memproses ledger secara berurutan. Setiap
+// pembelian menjadi lot; penjualan dicocokkan ke lot memakai metode FIFO (lot tertua dulu)
+// atau AVERAGE (semua lot dikurangi proporsional dengan biaya rata-rata).
+type Position struct {
+	Lots  []Lot
+	Units float64
+	// Biaya perolehan unit yang masih dipegang (termasuk fee pembelian)
+	CostBasis float64
+	// Total uang masuk (BUY/SWITCH_IN) dan uang keluar bersih (SELL/SWITCH_OUT setelah fee)
+	CashIn  float64
+	CashOut float64
+	// Keuntungan terealisasi dari penjualan
+	RealizedGain float64
+	// Dividen total, dan bagian yang dibayar tunai (sisanya diinvestasikan ulang)
+	Dividends     float64
+	DividendsPaid float64
+	Fees          float64
+}
+
+// Apply memproses satu transaksi dan mengembalikan pencocokan lot jika transaksi adalah penjualan.
+// Transaksi pending diabaikan.
+func (p *Position) Apply(tx models.Transaction) []models.LotMatch {
+	if tx.Pending() {
+		return nil
+	}
+
+	p.Fees += tx.Fee
+	switch tx.Type {
+	case models.TransactionBuy, models.TransactionSwitchIn:
+		p.addLot(tx, tx.Amount)
+		p.CashIn += tx.Amount
+	case models.TransactionSell, models.TransactionSwitchOut:
+		return p.sell(tx)
+	case models.TransactionDividend:
+		p.Dividends += tx.Amount
+		if tx.Units > 0 {
+			// Dividen yang diinvestasikan ulang menjadi lot baru dengan biaya = nilai dividen
+			p.addLot(tx, tx.Amount)
+		} else {
+			p.DividendsPaid += tx.Amount
+		}
+	}
+	return nil
+}
+
+// ApplyStrict sama seperti Apply, tapi menolak penjualan yang melebihi unit yang dimiliki alih-alih
+// memotongnya, sehingga pencocokan lot tidak pernah dihitung dari unit yang tidak ada
+func (p *Position) ApplyStrict(tx models.Transaction) ([]models.LotMatch, error) {
+	if (tx.Type == models.TransactionSell || tx.Type == models.TransactionSwitchOut) && !tx.Pending() &&
+		tx.Units > p.Units+unitCheckTolerance {
+		return nil, fmt.Errorf("%w: selling %f units on %s, holding %f", ErrInsufficientUnits, tx.Units, tx.Date.Format(dateLayout), p.Units)
+	}
+	return p.Apply(tx), nil
+}
+
+// validateLedgerUnits memproses ulang seluruh ledger (terurut) dan memastikan tidak ada penjualan yang
+// melebihi unit pada saat itu, termasuk penjualan setelahnya yang unitnya terpakai oleh penjualan yang
+// disisipkan di tengah. Penjualan pending yang unitnya sudah diketahui ikut dihitung.
+func validateLedgerUnits(txs []models.Transaction) error {
+	var pos Position
+	for _, tx := range txs {
+		if tx.Pending() && tx.Units > 0 && (tx.Type == models.TransactionSell || tx.Type == models.TransactionSwitchOut) {
+			// NAV belum terbit, tapi unitnya sudah pasti; NAV sementara hanya agar unitnya diproses
+			tx.Nav = tx.Amount / tx.Units
+			if tx.Nav <= 0 {
+				tx.Nav = 1
+			}
+		}
+		if _, err := pos.ApplyStrict(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Position) addLot(tx models.Transaction, cost float64) {
+	p.Lots = append(p.Lots, Lot{
+		TransactionID: tx.ID,
+		Date:          tx.Date,
+		Nav:           tx.Nav,
+		OriginalUnits: tx.Units,
+		Units:         tx.Units,
+		CostBasis:     cost,
+	})
+	p.Units += tx.Units
+	p.CostBasis += cost
+}
+
+func (p *Position) sell(tx models.Transaction) []models.LotMatch {
+	units := math.Min(tx.Units, p.Units)
+	proceeds := tx.Amount - tx.Fee
+	p.CashOut += proceeds
+	if units <= 0 {
+		p.RealizedGain += proceeds
+		return nil
+	}
+
+	var matches []models.LotMatch
+	match := func(lot *Lot, take float64) {
+		cost := lot.CostBasis * take / lot.Units
+		share := proceeds * take / units
+		lot.Units -= take
+		lot.CostBasis -= cost
+		p.Units -= take
+		p.CostBasis -= cost
+		p.RealizedGain += share - cost
+		matches = append(matches, models.LotMatch{
+			UserID:            tx.UserID,
+			PortfolioID:       tx.PortfolioID,
+			MutualFundID:      tx.MutualFundID,
+			SellTransactionID: tx.ID,
+			BuyTransactionID:  lot.TransactionID,
+			Units:             take,
+			CostBasis:         cost,
+			Proceeds:          share,
+			RealizedGain:      share - cost,
+		})
+	}
+
+	if tx.CostMethod == models.CostMethodAverage {
+		// Semua lot dikurangi dengan proporsi yang sama, sehingga biaya per unit = biaya rata-rata
+		ratio := units / p.Units
+		for i := range p.Lots {
+			if p.Lots[i].Units > 0 {
+				match(&p.Lots[i], p.Lots[i].Units*ratio)
+			}
+		}
+	} else {
+		remaining := units
+		for i := range p.Lots {
+			if remaining <= unitEpsilon {
+				break
+			}
+			if p.Lots[i].Units <= 0 {
+				continue
+			}
+			take := math.Min(remaining, p.Lots[i].Units)
+			match(&p.Lots[i], take)
+			remaining -= take
+		}
+	}
+
+	// Buang lot yang sudah habis
+	lots := p.Lots[:0]
+	for _, lot := range p.Lots {
+		if lot.Units > unitEpsilon {
+			lots = append(lots, lot)
+		}
+	}
+	p.Lots = lots
+	if p.Units < unitEpsilon {
+		p.Units = 0
+		p.CostBasis = 0
+	}
+	return matches
+}
+
+// BuildPosition memproses semua transaksi (harus sudah terurut berdasarkan tanggal)
+func BuildPosition(txs []models.Transaction) Position {
+	var p Position
+	for _, tx := range txs {
+		p.Apply(tx)
+	}
+	return p
+}
+
+// Holding adalah ringkasan kepemilikan satu reksa dana yang diturunkan dari ledger
+type Holding struct {
+	// Portfolio pemilik; 0 jika holding adalah gabungan beberapa portfolio
+	PortfolioID    uint    `json:"portfolio_id,omitempty"`
+	MutualFundID   uint    `json:"mutual_fund_id"`
+	Units          float64 `json:"units"`
+	TotalInvested  float64 `json:"total_invested"`
+	AverageCost    float64 `json:"average_cost"`
+	LatestNav      float64 `json:"latest_nav"`
+	LatestNavDate  string  `json:"latest_nav_date"`
+	CurrentValue   float64 `json:"current_value"`
+	UnrealizedGain float64 `json:"unrealized_gain"`
+	// Persentase keuntungan terhadap modal, dalam persen
+	UnrealizedGainPercent float64 `json:"unrealized_gain_percent"`
+	RealizedGain          float64 `json:"realized_gain"`
+	Dividends             float64 `json:"dividends"`
+	// Lot pembelian yang masih tersisa
+	Lots []Lot `json:"lots"`
+	// Jumlah transaksi yang belum bisa dihitung karena NAV belum terbit
+	PendingTransactions int     `json:"pending_transactions"`
+	PendingAmount       float64 `json:"pending_amount"`
+}
+
+// BuildHolding menghitung unit, harga rata-rata dan keuntungan dari ledger satu reksa dana
+// dan NAV terakhir. Nilai saat ini = units × NAV terakhir. Jika ledger mencakup beberapa portfolio,
+// lot dicocokkan per portfolio lalu hasilnya dijumlahkan.
+func BuildHolding(fundID uint, txs []models.Transaction, latest *models.NavPrice) Holding {
+	h := Holding{MutualFundID: fundID}
+	for _, tx := range txs {
+		if tx.Pending() {
+			h.PendingTransactions++
+			h.PendingAmount += tx.Amount
+		}
+	}
+
+	h.Lots = []Lot{}
+	portfolioIDs, byPortfolio := splitByPortfolio(txs)
+	for _, portfolioID := range portfolioIDs {
+		p := BuildPosition(byPortfolio[portfolioID])
+		h.Units += p.Units
+		h.TotalInvested += p.CostBasis
+		h.RealizedGain += p.RealizedGain
+		h.Dividends += p.Dividends
+		h.Lots = append(h.Lots, p.Lots...)
+	}
+	if len(portfolioIDs) == 1 {
+		h.PortfolioID = portfolioIDs[0]
+	}
+	if h.Units > 0 {
+		h.AverageCost = h.TotalInvested / h.Units
+	}
+	if latest != nil {
+		h.LatestNav = latest.Nav
+		h.LatestNavDate = latest.Date.Format(dateLayout)
+		h.CurrentValue = h.Units * latest.Nav
+		h.UnrealizedGain = h.CurrentValue - h.TotalInvested
+		if h.TotalInvested > 0 {
+			h.UnrealizedGainPercent = h.UnrealizedGain / h.TotalInvested * 100
+		}
+	}
+	return h
+}
+
+// splitByPortfolio mengelompokkan ledger per portfolio dengan urutan kemunculan yang tetap
+func splitByPortfolio(txs []models.Transaction) ([]uint, map[uint][]models.Transaction) {
+	var ids []uint
+	groups := make(map[uint][]models.Transaction)
+	for _, tx := range txs {
+		if _, ok := groups[tx.PortfolioID]; !ok {
+			ids = append(ids, tx.PortfolioID)
+		}
+		groups[tx.PortfolioID] = append(groups[tx.PortfolioID], tx)
+	}
+	return ids, groups
+}
+
+// ErrInsufficientUnits dikembalikan jika penjualan melebihi unit yang dimiliki
+var ErrInsufficientUnits = errors.New("insufficient units")
+
+// ErrCurrencyMismatch dikembalikan jika mata uang transaksi berbeda dengan mata uang reksa dana
+var ErrCurrencyMismatch = errors.New("transaction currency must match the mutual fund currency")
+
+// LoadLedger mengambil transaksi aktif milik user di satu portfolio (portfolioID 0 = semua portfolio)
+// untuk satu reksa dana (fundID 0 = semua), terurut berdasarkan tanggal lalu ID, dan melengkapi
+// transaksi yang masih pending
+func LoadLedger(db *gorm.DB, userID, portfolioID, fundID uint) ([]models.Transaction, error) {
+	txs, err := queryLedger(db, userID, portfolioID, fundID)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]bool, len(txs))
+	for i, tx := range txs {
+		pending[i] = tx.Pending()
+	}
+	if FillPendingTransactions(db, txs) == 0 {
+		return txs, nil
+	}
+
+	// Transaksi yang baru final mengubah pencocokan lot dan nilai harian sejak tanggalnya
+	type ledgerKey struct{ portfolioID, fundID uint }
+	var keys []ledgerKey
+	changedFrom := make(map[ledgerKey]time.Time)
+	for i, tx := range txs {
+		if !pending[i] || tx.Pending() {
+			continue
+		}
+		key := ledgerKey{tx.PortfolioID, tx.MutualFundID}
+		if from, ok := changedFrom[key]; !ok {
+			keys = append(keys, key)
+			changedFrom[key] = tx.Date
+		} else if tx.Date.Before(from) {
+			changedFrom[key] = tx.Date
+		}
+	}
+	for _, key := range keys {
+		if err := LedgerChanged(db, userID, key.portfolioID, key.fundID, changedFrom[key]); err != nil {
+			log.Printf("Failed to refresh ledger for user %d portfolio %d fund %d: %v", userID, key.portfolioID, key.fundID, err)
+		}
+	}
+	return txs, nil
+}
+
+func queryLedger(db *gorm.DB, userID, portfolioID, fundID uint) ([]models.Transaction, error) {
+	query := db.Where("user_id = ? AND deleted_at IS NULL", userID)
+	if portfolioID != 0 {
+		query = query.Where("portfolio_id = ?", portfolioID)
+	}
+	if fundID != 0 {
+		query = query.Where("mutual_fund_id = ?", fundID)
+	}
+
+	var txs []models.Transaction
+	if err := query.Order("date ASC, id ASC").Find(&txs).Error; err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// RecordTransaction melengkapi NAV transaksi baru, memastikan penjualan tidak membuat unit ledger
+// negatif di tanggal mana pun (termasuk penjualan setelahnya), menyimpannya, lalu menghitung ulang pencocokan lot dan
+// nilai harian (lihat LedgerChanged). Transaksi tanpa portfolio masuk ke portfolio default user dan
+// transaksi tanpa mata uang memakai mata uang reksa dana. DIVIDEND manual untuk distribusi yang sudah
+// tercatat ditolak dengan ErrDistributionRecorded.
+// db boleh berupa transaksi database yang sedang berjalan.
+func RecordTransaction(db *gorm.DB, tx *models.Transaction) error {
+	currency, err := FundCurrency(db, tx.MutualFundID)
+	if err != nil {
+		return err
+	}
+	if tx.Currency == "" {
+		tx.Currency = currency
+	} else if tx.Currency != currency {
+		return fmt.Errorf("%w: %s, mutual fund uses %s", ErrCurrencyMismatch, tx.Currency, currency)
+	}
+
+	if tx.PortfolioID == 0 {
+		portfolio, err := DefaultPortfolio(db, tx.UserID)
+		if err != nil {
+			return err
+		}
+		tx.PortfolioID = portfolio.ID
+	}
+	if err := CheckManualDividend(db, *tx); err != nil {
+		return err
+	}
+
+	if err := ApplyTradeNav(db, tx); err != nil && !errors.Is(err, ErrNavNotAvailable) {
+		log.Printf("NAV for new transaction left pending: %v", err)
+	}
+
+	if tx.Type == models.TransactionSell || tx.Type == models.TransactionSwitchOut {
+		if tx.CostMethod == "" {
+			tx.CostMethod = models.CostMethodFIFO
+		}
+		if err := validateSale(db, *tx); err != nil {
+			return err
+		}
+	}
+
+	// Penyimpanan dan pencocokan lot dalam satu transaksi database: jika ledger ternyata tidak valid,
+	// baris baru ikut dibatalkan
+	return db.Transaction(func(dbtx *gorm.DB) error {
+		if err := dbtx.Create(tx).Error; err != nil {
+			return err
+		}
+		return LedgerChanged(dbtx, tx.UserID, tx.PortfolioID, tx.MutualFundID, tx.Date)
+	})
+}
+
+// validateSale memproses ulang ledger portfolio dengan penjualan sale disisipkan pada tanggalnya. Penjualan
+// berdasarkan nominal yang NAV-nya belum terbit diperkirakan unitnya dengan NAV tersimpan terakhir.
+func validateSale(db *gorm.DB, sale models.Transaction) error {
+	ledger, err := queryLedger(db, sale.UserID, sale.PortfolioID, sale.MutualFundID)
+	if err != nil {
+		return err
+	}
+	if sale.Pending() && sale.Units <= 0 && sale.Amount > 0 {
+		var nav models.NavPrice
+		err := db.Where("mutual_fund_id = ? AND date <= ? AND nav > 0", sale.MutualFundID, DateOnly(sale.Date)).
+			Order("date DESC").First(&nav).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			sale.Units = sale.Amount / nav.Nav
+		}
+	}
+
+	txs := make([]models.Transaction, 0, len(ledger)+1)
+	inserted := false
+	for _, tx := range ledger {
+		if !inserted && tx.Date.After(sale.Date) {
+			txs = append(txs, sale)
+			inserted = true
+		}
+		txs = append(txs, tx)
+	}
+	if !inserted {
+		txs = append(txs, sale)
+	}
+	return validateLedgerUnits(txs)
+}
+
+// CheckLedgerUnits memastikan tidak ada penjualan di ledger satu portfolio user yang melebihi unit setelah
+// transaksi diurutkan; dipakai setelah ledger diubah di dalam transaksi database sebelum di-commit
+func CheckLedgerUnits(db *gorm.DB, userID, portfolioID, fundID uint) error {
+	txs, err := queryLedger(db, userID, portfolioID, fundID)
+	if err != nil {
+		return err
+	}
+	return validateLedgerUnits(txs)
+}
+
+// UnitsHeld menghitung unit yang dimiliki sebuah portfolio user pada sebuah reksa dana sampai tanggal date
+func UnitsHeld(db *gorm.DB, userID, portfolioID, fundID uint, date time.Time) (float64, error) {
+	txs, err := LoadLedger(db, userID, portfolioID, fundID)
+	if err != nil {
+		return 0, err
+	}
+
+	var pos Position
+	for _, tx := range txs {
+		if tx.Date.After(date) {
+			break
+		}
+		pos.Apply(tx)
+	}
+	return pos.Units, nil
+}
+
+// SyncRealizedGains memproses ulang ledger satu reksa dana di satu portfolio user lalu menyimpan
+// hasil pencocokan lot (lot_matches) serta cost_basis dan realized_gain setiap penjualan.
+// Dipanggil setiap kali ledger reksa dana tersebut berubah.
+func SyncRealizedGains(db *gorm.DB, userID, portfolioID, fundID uint) error {
+	txs, err := queryLedger(db, userID, portfolioID, fundID)
+	if err != nil {
+		return err
+	}
+
+	var (
+		pos     Position
+		matches []models.LotMatch
+		sales   []models.Transaction
+	)
+	for _, tx := range txs {
+		saleMatches, err := pos.ApplyStrict(tx)
+		if err != nil {
+			return err
+		}
+		if tx.Type != models.TransactionSell && tx.Type != models.TransactionSwitchOut {
+			continue
+		}
+
+		tx.CostBasis, tx.RealizedGain = 0, 0
+		if !tx.Pending() {
+			tx.RealizedGain = tx.Amount - tx.Fee
+			for _, m := range saleMatches {
+				tx.CostBasis += m.CostBasis
+			}
+			tx.RealizedGain -= tx.CostBasis
+		}
+		matches = append(matches, saleMatches...)
+		sales = append(sales, tx)
+	}
+
+	return db.Transaction(func(dbtx *gorm.DB) error {
+		if err := dbtx.Where("user_id = ? AND portfolio_id = ? AND mutual_fund_id = ?", userID, portfolioID, fundID).
+			Delete(&models.LotMatch{}).Error; err != nil {
+			return err
+		}
+		if len(matches) > 0 {
+			if err := dbtx.Create(&matches).Error; err != nil {
+				return err
+			}
+		}
+		for _, sale := range sales {
+			if err := dbtx.Model(&models.Transaction{}).Where("id = ?", sale.ID).
+				Updates(map[string]interface{}{"cost_basis": sale.CostBasis, "realized_gain": sale.RealizedGain}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DailyValuation adalah nilai kepemilikan satu reksa dana pada satu hari NAV
+type DailyValuation struct {
+	Date             time.Time
+	Nav              float64
+	NavChange        float64
+	NavChangePercent float64
+	Units            float64
+	CostBasis        float64
+	Value            float64
+	// Uang masuk (pembelian) dan keluar (penjualan bersih + dividen tunai) pada hari ini
+	CashIn  float64
+	CashOut float64
+	// Perubahan nilai karena pergerakan NAV hari ini, tanpa arus kas
+	DailyGain        float64
+	DailyGainPercent float64
+	// Keuntungan total sejak awal: belum terealisasi + terealisasi + dividen tunai
+	TotalGain float64
+	// Biaya transaksi (pembelian, penjualan, switching) kumulatif sampai hari ini dan keuntungan
+	// total sebelum biaya tersebut
+	Fees           float64
+	GrossTotalGain float64
+	// Dividen kumulatif (tunai dan diinvestasikan ulang); sudah termasuk di TotalGain
+	Dividends float64
+	// Bagian TotalGain yang berasal dari perubahan kurs, hanya diisi setelah konversi ke mata uang
+	// laporan (lihat ConvertValuations)
+	FxGain float64
+}
+
+// BuildDailyValuation menghitung nilai harian dari ledger (terurut) dan deret NAV (terurut).
+// Transaksi pada hari libur ikut dihitung di hari NAV berikutnya. Hari sebelum transaksi
+// pertama tidak dikembalikan.
+func BuildDailyValuation(txs []models.Transaction, navs []models.NavPrice) []DailyValuation {
+	var (
+		results   []DailyValuation
+		pos       Position
+		next      int
+		prevNav   float64
+		prevValue float64
+	)
+
+	for _, nav := range navs {
+		before := pos
+		for next < len(txs) && !DateOnly(txs[next].Date).After(nav.Date) {
+			pos.Apply(txs[next])
+			next++
+		}
+
+		if next == 0 {
+			prevNav = nav.Nav
+			continue
+		}
+
+		cashIn := pos.CashIn - before.CashIn
+		cashOut := (pos.CashOut + pos.DividendsPaid) - (before.CashOut + before.DividendsPaid)
+		value := pos.Units * nav.Nav
+
+		point := DailyValuation{
+			Date:      nav.Date,
+			Nav:       nav.Nav,
+			Units:     pos.Units,
+			CostBasis: pos.CostBasis,
+			Value:     value,
+			CashIn:    cashIn,
+			CashOut:   cashOut,
+			DailyGain: value + cashOut - cashIn - prevValue,
+			TotalGain: value - pos.CostBasis + pos.RealizedGain + pos.Dividends,
+			Fees:      pos.Fees,
+			Dividends: pos.Dividends,
+		}
+		point.GrossTotalGain = point.TotalGain + point.Fees
+		if prevNav != 0 {
+			point.NavChange = nav.Nav - prevNav
+			point.NavChangePercent = point.NavChange / prevNav * 100
+		}
+		if base := prevValue + cashIn; base != 0 {
+			point.DailyGainPercent = point.DailyGain / base * 100
+		}
+
+		results = append(results, point)
+		prevNav = nav.Nav
+		prevValue = value
+	}
+
+	return results
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"errors"
+	"golang/models"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// daysPerMonth dipakai untuk mengubah statistik return per hari kalender menjadi bulanan
+const daysPerMonth = 365.25 / 12
+
+// MaxProjectionYears membatasi horizon simulasi goal; memori dan waktu simulasi sebanding dengan
+// jumlah bulan x jumlah simulasi
+const MaxProjectionYears = 50
+
+// ErrNotEnoughHistory dikembalikan jika riwayat NAV terlalu pendek untuk menghitung volatilitas
+var ErrNotEnoughHistory = errors.New("not enough NAV history")
+
+// ReturnStats adalah statistik log-return bulanan sebuah reksa dana dari riwayat NAV tersimpan
+type ReturnStats struct {
+	MonthlyMean       float64 `json:"monthly_mean"`
+	MonthlyVolatility float64 `json:"monthly_volatility"`
+	// Versi tahunan untuk ditampilkan (desimal, 0.1 = 10%)
+	AnnualReturn     float64 `json:"annual_return"`
+	AnnualVolatility float64 `json:"annual_volatility"`
+	Observations     int     `json:"observations"`
+}
+
+// FundReturnStats menghitung statistik return bulanan dari NAV tersimpan sejak tanggal since,
+// termasuk distribusi dividen reksa dana
+func FundReturnStats(db *gorm.DB, fundID uint, since time.Time) (ReturnStats, error) {
+	var navs []models.NavPrice
+	if err := db.Where("mutual_fund_id = ? AND date >= ? AND nav > 0", fundID, DateOnly(since)).
+		Order("date ASC").Find(&navs).Error; err != nil {
+		return ReturnStats{}, err
+	}
+	var dists []models.FundDistribution
+	if err := db.Where("mutual_fund_id = ? AND ex_date > ?", fundID, DateOnly(since)).
+		Order("ex_date ASC").Find(&dists).Error; err != nil {
+		return ReturnStats{}, err
+	}
+	return returnStats(navs, dists)
+}
+
+// returnStats menghitung drift dan volatilitas log-return total per hari kalender dari NAV terurut
+// (dividen per unit dari dists ditambahkan kembali pada ex-date-nya, karena NAV turun sebesar dividen), lalu
+// mengubahnya ke skala bulanan. Jarak antar NAV tidak selalu satu hari bursa (akhir pekan, libur,
+// NAV yang hilang), jadi setiap return ditimbang dengan jumlah hari di antaranya: drift adalah total
+// log-return dibagi total hari, dan variansnya dari selisih return terhadap drift x jarak hari.
+func returnStats(navs []models.NavPrice, dists []models.FundDistribution) (ReturnStats, error) {
+	if len(navs) < 3 {
+		return ReturnStats{}, ErrNotEnoughHistory
+	}
+
+	returns := make([]float64, 0, len(navs)-1)
+	gaps := make([]float64, 0, len(navs)-1)
+	var total, days float64
+	next := 0
+	for i := 1; i < len(navs); i++ {
+		gap := navs[i].Date.Sub(navs[i-1].Date).Hours() / 24
+		if gap <= 0 {
+			continue
+		}
+		var dividend float64
+		for ; next < len(dists) && !dists[next].ExDate.After(navs[i].Date); next++ {
+			if dists[next].ExDate.After(navs[i-1].Date) {
+				dividend += dists[next].AmountPerUnit
+			}
+		}
+		r := math.Log((navs[i].Nav + dividend) / navs[i-1].Nav)
+		returns, gaps = append(returns, r), append(gaps, gap)
+		total += r
+		days += gap
+	}
+	if len(returns) < 2 {
+		return ReturnStats{}, ErrNotEnoughHistory
+	}
+	drift := total / days
+	var variance float64
+	for i, r := range returns {
+		deviation := r - drift*gaps[i]
+		variance += deviation * deviation / gaps[i]
+	}
+	variance /= float64(len(returns) - 1)
+
+	stats := ReturnStats{
+		MonthlyMean:       drift * daysPerMonth,
+		MonthlyVolatility: math.Sqrt(variance * daysPerMonth),
+		Observations:      len(returns),
+	}
+	stats.AnnualReturn = math.Exp(stats.MonthlyMean*12) - 1
+	stats.AnnualVolatility = stats.MonthlyVolatility * math.Sqrt(12)
+	return stats, nil
+}
+
+// SimulationAsset adalah satu kepemilikan yang disimulasikan
+type SimulationAsset struct {
+	MutualFundID        uint        `json:"mutual_fund_id"`
+	CurrentValue        float64     `json:"current_value"`
+	MonthlyContribution float64     `json:"monthly_contribution"`
+	Stats               ReturnStats `json:"stats"`
+}
+
+// ProjectionBand adalah persentil nilai portfolio pada akhir satu bulan simulasi
+type ProjectionBand struct {
+	Date string  `json:"date"`
+	P10  float64 `json:"p10"`
+	P25  float64 `json:"p25"`
+	P50  float64 `json:"p50"`
+	P75  float64 `json:"p75"`
+	P90  float64 `json:"p90"`
+}
+
+// Projection adalah hasil simulasi Monte Carlo sebuah goal
+type Projection struct {
+	Simulations        int              `json:"simulations"`
+	Seed               int64            `json:"seed"`
+	Months             int              `json:"months"`
+	TargetAmount       float64          `json:"target_amount"`
+	CurrentValue       float64          `json:"current_value"`
+	TotalContributions float64          `json:"total_contributions"`
+	Probability        float64          `json:"probability"`
+	Bands              []ProjectionBand `json:"bands"`
+}
+
+// SimulateGoal menjalankan simulasi Monte Carlo bulanan dari start sampai targetDate. Setiap bulan
+// nilai setiap reksa dana dikalikan exp(N(mean, volatility)) dari statistik historisnya (antar
+// reksa dana diasumsikan independen), lalu setoran bulanan ditambahkan. RNG memakai seed yang
+// sama untuk hasil yang bisa diulang. Horizon dibatasi MaxProjectionYears.
+func SimulateGoal(assets []SimulationAsset, target float64, start, targetDate time.Time, simulations int, seed int64) Projection {
+	start, targetDate = DateOnly(start), DateOnly(targetDate)
+	if limit := start.AddDate(MaxProjectionYears, 0, 0); targetDate.After(limit) {
+		targetDate = limit
+	}
+	months := 0
+	for d := start.AddDate(0, 1, 0); !d.After(targetDate); d = d.AddDate(0, 1, 0) {
+		months++
+	}
+
+	projection := Projection{Simulations: simulations, Seed: seed, Months: months, TargetAmount: target}
+	for _, a := range assets {
+		projection.CurrentValue += a.CurrentValue
+		projection.TotalContributions += a.MonthlyContribution * float64(months)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	// totals[m][s] = nilai total simulasi s di akhir bulan m
+	totals := make([][]float64, months)
+	for m := range totals {
+		totals[m] = make([]float64, simulations)
+	}
+	values := make([]float64, len(assets))
+	reached := 0
+	for s := 0; s < simulations; s++ {
+		for i, a := range assets {
+			values[i] = a.CurrentValue
+		}
+		total := projection.CurrentValue
+		for m := 0; m < months; m++ {
+			total = 0
+			for i, a := range assets {
+				shock := a.Stats.MonthlyMean + a.Stats.MonthlyVolatility*rng.NormFloat64()
+				values[i] = values[i]*math.Exp(shock) + a.MonthlyContribution
+				total += values[i]
+			}
+			totals[m][s] = total
+		}
+		if total >= target {
+			reached++
+		}
+	}
+	if simulations > 0 {
+		projection.Probability = float64(reached) / float64(simulations)
+	}
+
+	projection.Bands = make([]ProjectionBand, 0, months)
+	for m := 0; m < months; m++ {
+		sort.Float64s(totals[m])
+		projection.Bands = append(projection.Bands, ProjectionBand{
+			Date: start.AddDate(0, m+1, 0).Format(dateLayout),
+			P10:  percentile(totals[m], 0.10),
+			P25:  percentile(totals[m], 0.25),
+			P50:  percentile(totals[m], 0.50),
+			P75:  percentile(totals[m], 0.75),
+			P90:  percentile(totals[m], 0.90),
+		})
+	}
+	return projection
+}
+
+// percentile mengambil persentil p (0-1) dari data terurut dengan interpolasi linear
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := p * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"golang/models"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func simulationAssets() []SimulationAsset {
+	return []SimulationAsset{
+		{MutualFundID: 1, CurrentValue: 10000000, MonthlyContribution: 1000000, Stats: ReturnStats{MonthlyMean: 0.006, MonthlyVolatility: 0.04}},
+		{MutualFundID: 2, CurrentValue: 5000000, MonthlyContribution: 500000, Stats: ReturnStats{MonthlyMean: 0.003, MonthlyVolatility: 0.01}},
+	}
+}
+
+func TestSimulateGoalSameSeedIsReproducible(t *testing.T) {
+	start, target := mustDate("2024-01-15"), mustDate("2029-01-15")
+	a := SimulateGoal(simulationAssets(), 100000000, start, target, 500, 42)
+	b := SimulateGoal(simulationAssets(), 100000000, start, target, 500, 42)
+
+	if a.Months != 60 || len(a.Bands) != 60 {
+		t.Fatalf("months = %d, bands = %d, want 60", a.Months, len(a.Bands))
+	}
+	if !reflect.DeepEqual(a.Bands, b.Bands) {
+		t.Error("percentile bands differ for the same seed")
+	}
+	if a.Probability != b.Probability {
+		t.Errorf("probability = %f and %f for the same seed", a.Probability, b.Probability)
+	}
+
+	c := SimulateGoal(simulationAssets(), 100000000, start, target, 500, 43)
+	if reflect.DeepEqual(a.Bands, c.Bands) {
+		t.Error("percentile bands are identical for different seeds")
+	}
+}
+
+func TestSimulateGoalPercentilesAreOrdered(t *testing.T) {
+	p := SimulateGoal(simulationAssets(), 100000000, mustDate("2024-01-15"), mustDate("2026-01-15"), 200, 7)
+	for _, band := range p.Bands {
+		if !(band.P10 <= band.P25 && band.P25 <= band.P50 && band.P50 <= band.P75 && band.P75 <= band.P90) {
+			t.Fatalf("percentiles out of order on %s: %+v", band.Date, band)
+		}
+	}
+}
+
+func TestSimulateGoalCapsHorizon(t *testing.T) {
+	p := SimulateGoal(simulationAssets(), 100000000, mustDate("2024-01-15"), mustDate("2224-01-15"), 1, 1)
+	if want := MaxProjectionYears * 12; p.Months != want || len(p.Bands) != want {
+		t.Errorf("months = %d, bands = %d, want %d", p.Months, len(p.Bands), want)
+	}
+}
+
+func TestReturnStatsScalesByElapsedDays(t *testing.T) {
+	// NAV tumbuh 0,05% log per hari kalender, hanya tercatat pada hari kerja
+	const growth = 0.0005
+	start := mustDate("2024-01-01")
+	var navs []models.NavPrice
+	for d := start; d.Before(start.AddDate(0, 3, 0)); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			continue
+		}
+		days := d.Sub(start).Hours() / 24
+		navs = append(navs, models.NavPrice{Date: d, Nav: 1000 * math.Exp(growth*days)})
+	}
+
+	stats, err := returnStats(navs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := growth * daysPerMonth; math.Abs(stats.MonthlyMean-want) > 1e-9 {
+		t.Errorf("monthly mean = %f, want %f", stats.MonthlyMean, want)
+	}
+	if stats.MonthlyVolatility > 1e-9 {
+		t.Errorf("monthly volatility = %f, want 0 for steady growth across weekends", stats.MonthlyVolatility)
+	}
+}
+
+func TestReturnStatsAddsBackDistributions(t *testing.T) {
+	// NAV datar yang turun sebesar dividen pada ex-date tidak boleh terbaca sebagai kerugian
+	navs := []models.NavPrice{
+		{Date: mustDate("2024-03-01"), Nav: 1000},
+		{Date: mustDate("2024-03-04"), Nav: 1000},
+		{Date: mustDate("2024-03-05"), Nav: 980},
+		{Date: mustDate("2024-03-06"), Nav: 980},
+	}
+	dists := []models.FundDistribution{{ExDate: mustDate("2024-03-05"), AmountPerUnit: 20}}
+
+	stats, err := returnStats(navs, dists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(stats.MonthlyMean) > 1e-12 || math.Abs(stats.MonthlyVolatility) > 1e-12 {
+		t.Errorf("stats = %+v, want zero mean and volatility", stats)
+	}
+}
@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const dateLayout = "2006-01-02"
+
+// NavSeries adalah hasil GetMutualFundNav: nama produk dan deret NAV yang sudah terurut berdasarkan tanggal
+type NavSeries struct {
+	ProductName string            `json:"product_name"`
+	Navs        []models.NavPrice `json:"navs"`
+	// Stale bernilai true jika provider sedang gagal dan data hanya berasal dari nav_prices
+	Stale bool `json:"stale"`
+	// CacheStatus berisi CacheHit atau CacheMiss, untuk header X-Cache
+	CacheStatus string `json:"-"`
+}
+
+// Fungsi ini mengambil data NAV sebuah reksa dana untuk periode cperiod (lihat ResolveNavPeriod).
+// Hasilnya di-cache di Redis per (reksa dana, periode, rentang tanggal). Saat cache kosong, bagian
+// rentang yang sudah tersimpan di tabel nav_prices dibaca dari database, dan hanya tanggal yang
+// belum ada yang diambil dari provider reksa dana tersebut (lalu disimpan).
+func GetMutualFundNav(db *gorm.DB, id uint, cperiod, startdate, enddate string) (*NavSeries, error) {
+
+	// cek data murual fund
+	if id == 0 || cperiod == "" {
+		return nil, fmt.Errorf("invalid parameters: id=%d, cperiod=%s, startdate=%s, enddate=%s", id, cperiod, startdate, enddate)
+	}
+
+	start, end, err := ResolveNavPeriod(cperiod, startdate, enddate, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("nav:series:%d:%s:%s:%s", id, cperiod, start.Format(dateLayout), end.Format(dateLayout))
+	data, status, err := CachedNavFetch(key, end, func() ([]byte, bool, error) {
+		series, err := loadMutualFundNav(db, id, start, end)
+		if err != nil {
+			return nil, false, err
+		}
+		data, err := json.Marshal(series)
+		// Data basi (provider gagal) tidak di-cache supaya request berikutnya mencoba lagi
+		return data, !series.Stale, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var series NavSeries
+	if err := json.Unmarshal(data, &series); err != nil {
+		return nil, fmt.Errorf("failed to decode NAV series: %w", err)
+	}
+	series.CacheStatus = status
+	return &series, nil
+}
+
+// loadMutualFundNav membaca NAV dari nav_prices dan melengkapi tanggal yang belum tersimpan dari provider
+func loadMutualFundNav(db *gorm.DB, id uint, start, end time.Time) (*NavSeries, error) {
+	var mutualFund models.MutualFund
+	if err := db.First(&mutualFund, id).Error; err != nil {
+		return nil, fmt.Errorf("mutual fund not found: %w", err)
+	}
+
+	var navs []models.NavPrice
+	if err := db.Where("mutual_fund_id = ? AND date BETWEEN ? AND ?", id, start, end).
+		Order("date ASC").Find(&navs).Error; err != nil {
+		return nil, fmt.Errorf("failed to read stored NAV: %w", err)
+	}
+	var fetches []models.NavFetch
+	if err := db.Where("mutual_fund_id = ? AND start_date <= ? AND end_date >= ?", id, end, start).
+		Find(&fetches).Error; err != nil {
+		return nil, fmt.Errorf("failed to read NAV fetch log: %w", err)
+	}
+	gaps := missingNavRanges(start, end, time.Now(), navs, fetches)
+	if len(gaps) == 0 {
+		return &NavSeries{ProductName: mutualFund.Name, Navs: navs}, nil
+	}
+
+	// Jika provider sedang gagal (atau circuit breaker terbuka), tetap sajikan data yang sudah tersimpan
+	stale := false
+	for _, gap := range gaps {
+		if _, err := SyncNav(db, mutualFund, gap[0], gap[1]); err != nil {
+			if !IsUpstreamFailure(err) || len(navs) == 0 {
+				return nil, err
+			}
+			log.Printf("Serving stored NAV for fund %d, provider failed: %v", id, err)
+			stale = true
+			break
+		}
+	}
+
+	navs = nil
+	if err := db.Where("mutual_fund_id = ? AND date BETWEEN ? AND ?", id, start, end).
+		Order("date ASC").Find(&navs).Error; err != nil {
+		return nil, fmt.Errorf("failed to read stored NAV: %w", err)
+	}
+
+	return &NavSeries{ProductName: mutualFund.Name, Navs: navs, Stale: stale}, nil
+}
+
+// SyncNav mengambil NAV dari provider reksa dana untuk rentang [start, end] lalu menyimpannya ke nav_prices
+func SyncNav(db *gorm.DB, mutualFund models.MutualFund, start, end time.Time) ([]models.NavPrice, error) {
+	provider, err := NavProviderFor(mutualFund)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := provider.FetchNav(mutualFund, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	navs := make([]models.NavPrice, 0, len(points))
+	for _, p := range points {
+		navs = append(navs, models.NavPrice{
+			MutualFundID: mutualFund.ID,
+			Date:         p.Date,
+			Nav:          p.Nav,
+			Source:       provider.Name(),
+			FetchedAt:    now,
+		})
+	}
+
+	if err := db.Create(&models.NavFetch{MutualFundID: mutualFund.ID, StartDate: DateOnly(start), EndDate: DateOnly(end), FetchedAt: now}).Error; err != nil {
+		log.Printf("Failed to record NAV fetch of fund %d: %v", mutualFund.ID, err)
+	}
+	if len(navs) == 0 {
+		return navs, nil
+	}
+
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "mutual_fund_id"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"nav", "source", "fetched_at"}),
+	}).Create(&navs).Error; err != nil {
+		return nil, fmt.Errorf("failed to store NAV: %w", err)
+	}
+	InvalidateNavCache(mutualFund.ID)
+
+	// NAV baru (atau koreksi NAV) mengubah nilai harian semua pemegang reksa dana ini. Dividen
+	// REINVEST yang menunggu NAV tanggal bayarnya ikut dihitung unitnya. Perhitungannya diantrekan
+	// ke background (lihat QueueFundValuationRefresh).
+	from, to := navs[0].Date, navs[0].Date
+	for _, nav := range navs {
+		if nav.Date.Before(from) {
+			from = nav.Date
+		}
+		if nav.Date.After(to) {
+			to = nav.Date
+		}
+	}
+	exDate, reinvest, err := reinvestmentAwaitingNav(db, mutualFund.ID, from, to)
+	if err != nil {
+		log.Printf("Failed to check reinvested distributions of fund %d: %v", mutualFund.ID, err)
+	}
+	if reinvest {
+		if exDate.Before(from) {
+			from = exDate
+		}
+	}
+	QueueFundValuationRefresh(db, mutualFund.ID, from, reinvest)
+
+	// Provider yang punya data dividen ikut menyinkronkan distribusi pada rentang yang sama
+	if _, ok := provider.(DistributionProvider); ok {
+		if _, err := SyncFundDistributions(db, mutualFund, start, end); err != nil {
+			log.Printf("Failed to sync distributions of fund %d: %v", mutualFund.ID, err)
+		}
+	}
+
+	return navs, nil
+}
+
+// settleBusinessDays adalah jumlah hari kerja setelah sebuah tanggal sebelum data yang belum terbit
+// untuk tanggal itu dianggap tidak ada
+const settleBusinessDays = 2
+
+// addWeekdays mengembalikan tanggal n hari kerja (Senin-Jumat) setelah date
+func addWeekdays(date time.Time, n int) time.Time {
+	d := DateOnly(date)
+	for n > 0 {
+		d = d.AddDate(0, 0, 1)
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+			n--
+		}
+	}
+	return d
+}
+
+// fetchedRange adalah rentang tanggal yang sudah pernah diminta ke provider (nav_fetches atau fx_fetches)
+type fetchedRange struct {
+	Start, End, FetchedAt time.Time
+}
+
+// missingNavRanges mengelompokkan hari kerja di [start, end] yang belum punya NAV menjadi rentang untuk
+// diminta ke provider (lihat missingDateRanges)
+func missingNavRanges(start, end, now time.Time, stored []models.NavPrice, fetches []models.NavFetch) [][2]time.Time {
+	have := make(map[time.Time]bool, len(stored))
+	for _, nav := range stored {
+		have[DateOnly(nav.Date)] = true
+	}
+	ranges := make([]fetchedRange, len(fetches))
+	for i, f := range fetches {
+		ranges[i] = fetchedRange{Start: f.StartDate, End: f.EndDate, FetchedAt: f.FetchedAt}
+	}
+	return missingDateRanges(start, end, now, have, ranges)
+}
+
+// missingDateRanges mengelompokkan hari kerja di [start, end] yang tidak ada di have menjadi rentang untuk
+// diminta ke provider, termasuk celah di tengah data tersimpan. NAV dan kurs sering baru terbit satu-dua
+// hari kerja kemudian, jadi hari tanpa data baru dianggap libur jika sudah diminta setelah settleBusinessDays
+// hari kerja berikutnya lewat. Sebelum itu hari tersebut diminta paling banyak sekali sehari, supaya tanggal
+// tanpa data tidak memanggil provider di setiap request. Tanggal setelah hari ini diabaikan.
+func missingDateRanges(start, end, now time.Time, have map[time.Time]bool, fetches []fetchedRange) [][2]time.Time {
+	today := DateOnly(now)
+	if end.After(today) {
+		end = today
+	}
+	settled := func(day time.Time) bool {
+		final := addWeekdays(day, settleBusinessDays)
+		for _, f := range fetches {
+			if day.Before(DateOnly(f.Start)) || day.After(DateOnly(f.End)) {
+				continue
+			}
+			if !f.FetchedAt.Before(final) || DateOnly(f.FetchedAt).Equal(today) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var gaps [][2]time.Time
+	open := false
+	for d := DateOnly(start); !d.After(end); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			continue
+		}
+		if have[d] || settled(d) {
+			open = false
+			continue
+		}
+		if open {
+			gaps[len(gaps)-1][1] = d
+		} else {
+			gaps = append(gaps, [2]time.Time{d, d})
+			open = true
+		}
+	}
+	return gaps
+}
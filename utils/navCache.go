@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	CacheHit  = "HIT"
+	CacheMiss = "MISS"
+
+	// historicalNavTTL dipakai untuk rentang yang sudah lewat, datanya tidak berubah lagi
+	historicalNavTTL = 24 * time.Hour
+	minNavTTL        = time.Minute
+	redisTimeout     = 2 * time.Second
+)
+
+// NavCache menyimpan response NAV di Redis dan menggabungkan request identik yang berjalan
+// bersamaan sehingga hanya ada satu pemanggilan ke upstream.
+type NavCache struct {
+	redis    *redis.Client
+	schedule NavSchedule
+	group    singleflight.Group
+}
+
+var navCache *NavCache
+
+// InitNavCache mengaktifkan cache NAV. Tanpa ini semua request langsung ke sumber data.
+func InitNavCache(rdb *redis.Client, schedule NavSchedule) {
+	navCache = &NavCache{redis: rdb, schedule: schedule}
+}
+
+// CachedNavFetch mengambil data dari cache Redis, atau memanggil load sekali untuk semua
+// request identik yang sedang menunggu lalu menyimpan hasilnya (jika load menandainya cacheable).
+// Status yang dikembalikan adalah CacheHit atau CacheMiss.
+func CachedNavFetch(key string, end time.Time, load func() ([]byte, bool, error)) ([]byte, string, error) {
+	if navCache == nil {
+		data, _, err := load()
+		return data, CacheMiss, err
+	}
+	return navCache.fetch(key, end, load)
+}
+
+func (nc *NavCache) fetch(key string, end time.Time, load func() ([]byte, bool, error)) ([]byte, string, error) {
+	if data, ok := nc.get(key); ok {
+		return data, CacheHit, nil
+	}
+
+	v, err, _ := nc.group.Do(key, func() (interface{}, error) {
+		// Cek lagi, mungkin request lain baru saja mengisi cache
+		if data, ok := nc.get(key); ok {
+			return data, nil
+		}
+
+		data, cacheable, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if !cacheable {
+			return data, nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		defer cancel()
+		if err := nc.redis.Set(ctx, key, data, nc.TTL(end, time.Now())).Err(); err != nil {
+			log.Printf("NAV cache: failed to store %s: %v", key, err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, CacheMiss, err
+	}
+
+	return v.([]byte), CacheMiss, nil
+}
+
+// InvalidateNavCache menghapus semua deret NAV ter-cache milik reksa dana fundID, dipanggil setiap
+// kali nav_prices reksa dana itu ditulis (ingestion, backfill, sinkronisasi)
+func InvalidateNavCache(fundID uint) {
+	if navCache == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	pattern := fmt.Sprintf("nav:series:%d:*", fundID)
+	iter := navCache.redis.Scan(ctx, 0, pattern, 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("NAV cache: failed to scan %s: %v", pattern, err)
+		return
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if err := navCache.redis.Del(ctx, keys...).Err(); err != nil {
+		log.Printf("NAV cache: failed to invalidate fund %d: %v", fundID, err)
+	}
+}
+
+func (nc *NavCache) get(key string) ([]byte, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := nc.redis.Get(ctx, key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("NAV cache: failed to read %s: %v", key, err)
+		}
+		return nil, false
+	}
+	return data, true
+}
+
+// TTL menghitung umur cache untuk rentang yang berakhir di end. Rentang yang sudah lewat
+// disimpan 24 jam, sedangkan rentang yang mencakup hari ini hanya berlaku sampai jadwal
+// terbit NAV berikutnya.
+func (nc *NavCache) TTL(end, now time.Time) time.Duration {
+	local := now.In(nc.schedule.Location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	if end.Before(today) {
+		return historicalNavTTL
+	}
+
+	ttl := nc.schedule.Next(now).Sub(now)
+	if ttl < minNavTTL {
+		ttl = minNavTTL
+	}
+	return ttl
+}
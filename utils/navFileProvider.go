@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang/models"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileProvider membaca NAV dari direktori lokal, untuk reksa dana yang tidak tersedia di Bareksa.
+// Setiap reksa dana disimpan sebagai <dir>/<external_id>.csv (kolom: date,nav)
+// atau <dir>/<external_id>.json (array of {"date": "2006-01-02", "nav": 1234.56}).
+// Dividen (opsional) disimpan di <dir>/<external_id>.distributions.json, array of
+// {"ex_date": "2006-01-02", "pay_date": "2006-01-05", "amount_per_unit": 12.5, "payout_type": "CASH"}.
+type FileProvider struct {
+	Dir string
+}
+
+func NewFileProvider(dir string) *FileProvider {
+	return &FileProvider{Dir: dir}
+}
+
+func (fp *FileProvider) Name() string {
+	return models.NavProviderFile
+}
+
+func (fp *FileProvider) FundID(fund models.MutualFund) string {
+	return fund.ExternalID
+}
+
+func (fp *FileProvider) FetchNav(fund models.MutualFund, start, end time.Time) ([]NavPoint, error) {
+	base, err := fp.basePath(fund)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := readNavCSV(base + ".csv")
+	if errors.Is(err, os.ErrNotExist) {
+		points, err = readNavJSON(base + ".json")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].Date.Before(points[j].Date) })
+	return filterNavRange(points, start, end), nil
+}
+
+// FetchDistributions membaca dividen reksa dana; reksa dana tanpa file dividen dianggap tidak pernah membagi dividen
+func (fp *FileProvider) FetchDistributions(fund models.MutualFund, start, end time.Time) ([]DistributionPoint, error) {
+	base, err := fp.basePath(fund)
+	if err != nil {
+		return nil, err
+	}
+
+	path := base + ".distributions.json"
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		ExDate        string  `json:"ex_date"`
+		PayDate       string  `json:"pay_date"`
+		AmountPerUnit float64 `json:"amount_per_unit"`
+		PayoutType    string  `json:"payout_type"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var points []DistributionPoint
+	for _, row := range rows {
+		exDate, err := time.Parse(dateLayout, row.ExDate)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid ex_date %q", path, row.ExDate)
+		}
+		if exDate.Before(start) || exDate.After(end) {
+			continue
+		}
+		point := DistributionPoint{ExDate: exDate, AmountPerUnit: row.AmountPerUnit, PayoutType: strings.ToUpper(row.PayoutType)}
+		if row.PayDate != "" {
+			payDate, err := time.Parse(dateLayout, row.PayDate)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid pay_date %q", path, row.PayDate)
+			}
+			point.PayDate = &payDate
+		}
+		points = append(points, point)
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].ExDate.Before(points[j].ExDate) })
+	return points, nil
+}
+
+// basePath mengembalikan path file reksa dana tanpa ekstensi
+func (fp *FileProvider) basePath(fund models.MutualFund) (string, error) {
+	fundID := fp.FundID(fund)
+	if fundID == "" || strings.ContainsAny(fundID, `/\`) || strings.Contains(fundID, "..") {
+		return "", fmt.Errorf("invalid external id %q", fundID)
+	}
+	return filepath.Join(fp.Dir, fundID), nil
+}
+
+func readNavCSV(path string) ([]NavPoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.TrimLeadingSpace = true
+
+	var points []NavPoint
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%s line %d: expected date,nav", path, line)
+		}
+
+		date, err := time.Parse(dateLayout, record[0])
+		if err != nil {
+			// Baris header dilewati
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("%s line %d: invalid date %q", path, line, record[0])
+		}
+		nav, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: invalid nav %q", path, line, record[1])
+		}
+		points = append(points, NavPoint{Date: date, Nav: nav})
+	}
+
+	return points, nil
+}
+
+func readNavJSON(path string) ([]NavPoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Date string  `json:"date"`
+		Nav  float64 `json:"nav"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	points := make([]NavPoint, 0, len(rows))
+	for _, row := range rows {
+		date, err := time.Parse(dateLayout, row.Date)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid date %q", path, row.Date)
+		}
+		points = append(points, NavPoint{Date: date, Nav: row.Nav})
+	}
+
+	return points, nil
+}
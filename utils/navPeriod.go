@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// earliestNavDate dipakai sebagai awal periode "all"
+var earliestNavDate = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ResolveNavPeriod mengubah cperiod (custom, 1w, 1m, 3m, 6m, ytd, 1y, 3y, 5y, all) menjadi rentang tanggal.
+// startdate dan enddate hanya dipakai (dan wajib) untuk cperiod "custom".
+func ResolveNavPeriod(cperiod, startdate, enddate string, now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var start time.Time
+	switch cperiod {
+	case "custom":
+		s, err := time.Parse(dateLayout, startdate)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid startdate %q", startdate)
+		}
+		e, err := time.Parse(dateLayout, enddate)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid enddate %q", enddate)
+		}
+		if e.Before(s) {
+			return time.Time{}, time.Time{}, fmt.Errorf("enddate %s is before startdate %s", enddate, startdate)
+		}
+		return s, e, nil
+	case "1w":
+		start = today.AddDate(0, 0, -7)
+	case "1m":
+		start = today.AddDate(0, -1, 0)
+	case "3m":
+		start = today.AddDate(0, -3, 0)
+	case "6m":
+		start = today.AddDate(0, -6, 0)
+	case "ytd":
+		start = time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	case "1y":
+		start = today.AddDate(-1, 0, 0)
+	case "3y":
+		start = today.AddDate(-3, 0, 0)
+	case "5y":
+		start = today.AddDate(-5, 0, 0)
+	case "all":
+		start = earliestNavDate
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid cperiod %q", cperiod)
+	}
+
+	return start, today, nil
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"fmt"
+	"golang/models"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NavPoint adalah satu nilai NAV pada satu tanggal, dalam bentuk yang sudah dinormalisasi
+type NavPoint struct {
+	Date time.Time
+	Nav  float64
+}
+
+// NavProvider adalah sumber data NAV. Setiap reksa dana mencatat provider mana yang dipakai
+// (kolom nav_provider) dan ID reksa dana di provider tersebut (kolom external_id).
+type NavProvider interface {
+	// Name adalah nama provider yang disimpan di mutual_funds.nav_provider dan nav_prices.source
+	Name() string
+	// FundID mengembalikan identifier reksa dana di sisi provider
+	FundID(fund models.MutualFund) string
+	// FetchNav mengambil deret NAV untuk rentang tanggal [start, end], terurut naik
+	FetchNav(fund models.MutualFund, start, end time.Time) ([]NavPoint, error)
+}
+
+var (
+	navProvidersMu sync.RWMutex
+	navProviders   = map[string]NavProvider{}
+)
+
+func init() {
+	RegisterNavProvider(NewBareksaProvider())
+}
+
+// RegisterNavProvidersFromEnv mendaftarkan provider tambahan yang dikonfigurasi lewat environment.
+// NAV_FILE_DIR mengaktifkan FileProvider untuk reksa dana yang tidak ada di Bareksa.
+func RegisterNavProvidersFromEnv() {
+	if navDir := os.Getenv("NAV_FILE_DIR"); navDir != "" {
+		RegisterNavProvider(NewFileProvider(navDir))
+	}
+}
+
+// RegisterNavProvider mendaftarkan (atau mengganti) provider berdasarkan namanya
+func RegisterNavProvider(p NavProvider) {
+	navProvidersMu.Lock()
+	defer navProvidersMu.Unlock()
+	navProviders[p.Name()] = p
+}
+
+// GetNavProvider mencari provider yang terdaftar berdasarkan nama
+func GetNavProvider(name string) (NavProvider, error) {
+	navProvidersMu.RLock()
+	defer navProvidersMu.RUnlock()
+	p, ok := navProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown NAV provider %q", name)
+	}
+	return p, nil
+}
+
+// NavProviderFor mengembalikan provider yang dipakai oleh sebuah reksa dana
+func NavProviderFor(fund models.MutualFund) (NavProvider, error) {
+	name := fund.NavProvider
+	if name == "" {
+		name = models.NavProviderBareksa
+	}
+	return GetNavProvider(name)
+}
+
+// MemoryProvider menyimpan NAV di memori. Dipakai untuk pengujian dan data sementara.
+type MemoryProvider struct {
+	name string
+	mu   sync.RWMutex
+	navs map[string][]NavPoint
+}
+
+func NewMemoryProvider(name string) *MemoryProvider {
+	return &MemoryProvider{name: name, navs: map[string][]NavPoint{}}
+}
+
+func (mp *MemoryProvider) Name() string {
+	return mp.name
+}
+
+func (mp *MemoryProvider) FundID(fund models.MutualFund) string {
+	return fund.ExternalID
+}
+
+// Set mengganti seluruh deret NAV untuk satu fund ID
+func (mp *MemoryProvider) Set(fundID string, points []NavPoint) {
+	sorted := append([]NavPoint(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.navs[fundID] = sorted
+}
+
+func (mp *MemoryProvider) FetchNav(fund models.MutualFund, start, end time.Time) ([]NavPoint, error) {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	all, ok := mp.navs[mp.FundID(fund)]
+	if !ok {
+		return nil, fmt.Errorf("no NAV data for fund %q", mp.FundID(fund))
+	}
+	return filterNavRange(all, start, end), nil
+}
+
+// filterNavRange mengambil titik NAV yang berada di rentang [start, end]
+func filterNavRange(points []NavPoint, start, end time.Time) []NavPoint {
+	var result []NavPoint
+	for _, p := range points {
+		if p.Date.Before(start) || p.Date.After(end) {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// NavSchedule menentukan jam NAV dianggap sudah terbit. Dipakai scheduler ingestion harian
+// dan untuk menghitung TTL cache NAV.
+type NavSchedule struct {
+	// Jam:menit setelah NAV diterbitkan, di zona waktu Location
+	Hour     int
+	Minute   int
+	Location *time.Location
+}
+
+// DefaultNavSchedule membaca NAV_INGEST_TIME (format 15:04, default 20:00 WIB)
+func DefaultNavSchedule() (NavSchedule, error) {
+	loc, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		return NavSchedule{}, err
+	}
+
+	at := os.Getenv("NAV_INGEST_TIME")
+	if at == "" {
+		at = "20:00"
+	}
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return NavSchedule{}, fmt.Errorf("invalid NAV_INGEST_TIME %q: %w", at, err)
+	}
+
+	return NavSchedule{Hour: t.Hour(), Minute: t.Minute(), Location: loc}, nil
+}
+
+// Next mengembalikan jadwal terbit berikutnya setelah now. NAV hanya terbit di hari kerja,
+// jadi Sabtu dan Minggu dilewati.
+func (s NavSchedule) Next(now time.Time) time.Time {
+	local := now.In(s.Location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), s.Hour, s.Minute, 0, 0, s.Location)
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+	for next.Weekday() == time.Saturday || next.Weekday() == time.Sunday {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"errors"
+	"golang/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNavNotAvailable dikembalikan jika NAV untuk tanggal transaksi belum terbit
+var ErrNavNotAvailable = errors.New("NAV for the trade date is not available yet")
+
+// navLookaheadDays: pembelian di hari libur memakai NAV hari kerja berikutnya
+const navLookaheadDays = 10
+
+// DateOnly membuang jam dari t, tanggal kalender t dipertahankan
+func DateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// StoredNavForTradeDate mencari NAV yang berlaku untuk transaksi pada tanggal date, yaitu NAV
+// pertama pada atau setelah date, hanya dari nav_prices (tanpa memanggil provider).
+func StoredNavForTradeDate(db *gorm.DB, fundID uint, date time.Time) (*models.NavPrice, error) {
+	day := DateOnly(date)
+	var nav models.NavPrice
+	err := db.Where("mutual_fund_id = ? AND date BETWEEN ? AND ?", fundID, day, day.AddDate(0, 0, navLookaheadDays)).
+		Order("date ASC").First(&nav).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNavNotAvailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &nav, nil
+}
+
+// NavForTradeDate sama seperti StoredNavForTradeDate, tapi melengkapi nav_prices dari provider dulu
+func NavForTradeDate(db *gorm.DB, fundID uint, date time.Time) (*models.NavPrice, error) {
+	day := DateOnly(date)
+	end := day.AddDate(0, 0, navLookaheadDays)
+	if today := DateOnly(time.Now()); end.After(today) {
+		end = today
+	}
+	if day.After(end) {
+		return nil, ErrNavNotAvailable
+	}
+
+	series, err := GetMutualFundNav(db, fundID, "custom", day.Format(dateLayout), end.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+	if len(series.Navs) == 0 {
+		return nil, ErrNavNotAvailable
+	}
+	return &series.Navs[0], nil
+}
+
+// LatestStoredNav mengambil NAV terakhir yang tersimpan untuk sebuah reksa dana
+func LatestStoredNav(db *gorm.DB, fundID uint) (*models.NavPrice, error) {
+	var nav models.NavPrice
+	err := db.Where("mutual_fund_id = ?", fundID).Order("date DESC").First(&nav).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNavNotAvailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &nav, nil
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"errors"
+	"golang/models"
+	"log"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultPortfolio mengembalikan portfolio default user, dibuat otomatis jika belum ada
+func DefaultPortfolio(db *gorm.DB, userID uint) (*models.Portfolio, error) {
+	var portfolio models.Portfolio
+	err := db.Where("user_id = ? AND is_default = ?", userID, true).Order("id ASC").First(&portfolio).Error
+	if err == nil {
+		return &portfolio, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	portfolio = models.Portfolio{
+		UserID:       userID,
+		Name:         models.DefaultPortfolioName,
+		BaseCurrency: models.DefaultCurrency,
+		IsDefault:    true,
+	}
+	// Nama default bisa sudah dipakai jika dibuat bersamaan; ambil ulang barisnya
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&portfolio).Error; err != nil {
+		return nil, err
+	}
+	if portfolio.ID == 0 {
+		if err := db.Where("user_id = ? AND name = ?", userID, models.DefaultPortfolioName).First(&portfolio).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &portfolio, nil
+}
+
+// MigratePortfolios memindahkan transaksi dan rencana investasi berkala yang belum punya portfolio
+// ke portfolio default masing-masing user, lalu menghitung ulang lot dan valuasinya per portfolio
+func MigratePortfolios(db *gorm.DB) error {
+	// Unique index portfolio_valuations lama belum memuat portfolio_id, sehingga satu reksa dana
+	// yang sama di dua portfolio bertabrakan. Penggantinya sudah dibuat AutoMigrate.
+	if err := db.Exec("DROP INDEX IF EXISTS idx_portfolio_valuations_user_fund_date").Error; err != nil {
+		return err
+	}
+
+	var userIDs []uint
+	if err := db.Raw(`SELECT user_id FROM transactions WHERE portfolio_id = 0
+		UNION SELECT user_id FROM recurring_plans WHERE portfolio_id = 0 ORDER BY user_id`).
+		Scan(&userIDs).Error; err != nil {
+		return err
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	for _, userID := range userIDs {
+		portfolio, err := DefaultPortfolio(db, userID)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&models.Transaction{}).Where("user_id = ? AND portfolio_id = 0", userID).
+			Update("portfolio_id", portfolio.ID).Error; err != nil {
+			return err
+		}
+		if err := db.Model(&models.RecurringPlan{}).Where("user_id = ? AND portfolio_id = 0", userID).
+			Update("portfolio_id", portfolio.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	// Lot dan valuasi lama dihitung tanpa portfolio; hitung ulang dari ledger
+	if err := db.Where("portfolio_id = 0").Delete(&models.LotMatch{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("portfolio_id = 0").Delete(&models.PortfolioValuation{}).Error; err != nil {
+		return err
+	}
+	var keys []models.Transaction
+	if err := db.Model(&models.Transaction{}).Where("deleted_at IS NULL AND user_id IN ?", userIDs).
+		Distinct("user_id", "portfolio_id", "mutual_fund_id").Find(&keys).Error; err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := SyncRealizedGains(db, k.UserID, k.PortfolioID, k.MutualFundID); err != nil {
+			return err
+		}
+	}
+	log.Printf("Assigned ledgers of %d users to their default portfolio", len(userIDs))
+	return nil
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"golang/models"
+	"sort"
+)
+
+// Allocation adalah porsi nilai portfolio untuk satu kelompok (reksa dana, manajer investasi, atau kategori)
+type Allocation struct {
+	Key     string  `json:"key"`
+	Value   float64 `json:"value"`
+	Percent float64 `json:"percent"`
+}
+
+// FundSummary adalah ringkasan satu reksa dana di dashboard portfolio
+type FundSummary struct {
+	MutualFundID         uint    `json:"mutual_fund_id"`
+	Name                 string  `json:"name"`
+	InvestmentManagement string  `json:"investment_management"`
+	Category             string  `json:"category"`
+	Units                float64 `json:"units"`
+	Invested             float64 `json:"invested"`
+	CurrentValue         float64 `json:"current_value"`
+	TodayChange          float64 `json:"today_change"`
+	TotalGain            float64 `json:"total_gain"`
+	FxGain               float64 `json:"fx_gain"`
+	LatestNavDate        string  `json:"latest_nav_date"`
+}
+
+// PortfolioSummary adalah ringkasan seluruh kepemilikan user dalam mata uang Currency. FxGain adalah
+// bagian AllTimeGain yang berasal dari perubahan kurs, terpisah dari kinerja reksa dana.
+type PortfolioSummary struct {
+	Currency           string        `json:"currency"`
+	TotalInvested      float64       `json:"total_invested"`
+	CurrentValue       float64       `json:"current_value"`
+	TodayChange        float64       `json:"today_change"`
+	TodayChangePercent float64       `json:"today_change_percent"`
+	AllTimeGain        float64       `json:"all_time_gain"`
+	FxGain             float64       `json:"fx_gain"`
+	RealizedGain       float64       `json:"realized_gain"`
+	Dividends          float64       `json:"dividends"`
+	Funds              []FundSummary `json:"funds"`
+	ByFund             []Allocation  `json:"allocation_by_fund"`
+	ByManager          []Allocation  `json:"allocation_by_investment_manager"`
+	ByCategory         []Allocation  `json:"allocation_by_category"`
+	// Deret nilai harian gabungan semua reksa dana
+	Daily []DailyValuation `json:"-"`
+}
+
+// uncategorized dipakai untuk reksa dana yang belum punya manajer investasi atau kategori
+const uncategorized = "Lainnya"
+
+// BuildPortfolioSummary menyusun dashboard dari nilai harian setiap reksa dana. funds berisi data
+// reksa dana berdasarkan ID; perubahan hari ini diambil dari tanggal terakhir deret gabungan.
+func BuildPortfolioSummary(valuations []FundValuation, funds map[uint]models.MutualFund) PortfolioSummary {
+	var summary PortfolioSummary
+	series := make([][]DailyValuation, 0, len(valuations))
+	byFund := make(map[string]float64)
+	byManager := make(map[string]float64)
+	byCategory := make(map[string]float64)
+
+	for _, fv := range valuations {
+		series = append(series, fv.Days)
+		fund := funds[fv.MutualFundID]
+
+		item := FundSummary{
+			MutualFundID:         fv.MutualFundID,
+			Name:                 fund.Name,
+			InvestmentManagement: fund.InvestmentManagement,
+			Category:             fund.Category,
+		}
+		if len(fv.Days) > 0 {
+			last := fv.Days[len(fv.Days)-1]
+			item.FxGain = last.FxGain
+			item.Units = last.Units
+			item.Invested = last.CostBasis
+			item.CurrentValue = last.Value
+			item.TotalGain = last.TotalGain
+			item.LatestNavDate = last.Date.Format(dateLayout)
+		}
+		// Laba terealisasi sudah disimpan per penjualan oleh SyncRealizedGains (per portfolio)
+		for _, tx := range fv.Transactions {
+			switch {
+			case tx.Pending():
+			case tx.Type == models.TransactionSell || tx.Type == models.TransactionSwitchOut:
+				summary.RealizedGain += tx.RealizedGain
+			case tx.Type == models.TransactionDividend:
+				summary.Dividends += tx.Amount
+			}
+		}
+		summary.Funds = append(summary.Funds, item)
+		summary.TotalInvested += item.Invested
+
+		byFund[groupKey(fund.Name)] += item.CurrentValue
+		byManager[groupKey(fund.InvestmentManagement)] += item.CurrentValue
+		byCategory[groupKey(fund.Category)] += item.CurrentValue
+	}
+
+	summary.Daily = CombineDailyValuations(series)
+	if n := len(summary.Daily); n > 0 {
+		last := summary.Daily[n-1]
+		summary.CurrentValue = last.Value
+		summary.AllTimeGain = last.TotalGain
+		summary.FxGain = last.FxGain
+		summary.TodayChange = last.DailyGain
+		summary.TodayChangePercent = last.DailyGainPercent
+
+		// Perubahan hari ini per reksa dana hanya untuk reksa dana yang punya NAV di tanggal terakhir
+		for i, fv := range valuations {
+			if days := fv.Days; len(days) > 0 && days[len(days)-1].Date.Equal(last.Date) {
+				summary.Funds[i].TodayChange = days[len(days)-1].DailyGain
+			}
+		}
+	}
+
+	summary.ByFund = buildAllocations(byFund, summary.CurrentValue)
+	summary.ByManager = buildAllocations(byManager, summary.CurrentValue)
+	summary.ByCategory = buildAllocations(byCategory, summary.CurrentValue)
+	return summary
+}
+
+func groupKey(name string) string {
+	if name == "" {
+		return uncategorized
+	}
+	return name
+}
+
+// buildAllocations mengubah total per kelompok menjadi daftar terurut dari nilai terbesar
+func buildAllocations(values map[string]float64, total float64) []Allocation {
+	allocations := make([]Allocation, 0, len(values))
+	for key, value := range values {
+		a := Allocation{Key: key, Value: value}
+		if total > 0 {
+			a.Percent = value / total * 100
+		}
+		allocations = append(allocations, a)
+	}
+	sort.Slice(allocations, func(i, j int) bool {
+		if allocations[i].Value != allocations[j].Value {
+			return allocations[i].Value > allocations[j].Value
+		}
+		return allocations[i].Key < allocations[j].Key
+	})
+	return allocations
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"golang/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FundValuation adalah ledger dan nilai harian satu reksa dana milik user
+type FundValuation struct {
+	MutualFundID uint
+	ProductName  string
+	Transactions []models.Transaction
+	Navs         []models.NavPrice
+	// Nilai harian dari portfolio_valuations
+	Days []DailyValuation
+	// true jika NAV disajikan dari data tersimpan karena provider gagal
+	Stale bool
+	// Mata uang nilai di Transactions, Navs dan Days; kosong berarti mata uang reksa dana
+	Currency string
+}
+
+// LoadFundValuation menghitung nilai harian ledger user untuk satu reksa dana sampai tanggal end,
+// di satu portfolio atau gabungan semua portfolio (portfolioID 0).
+// Mengembalikan nil tanpa error jika user tidak punya transaksi di reksa dana tersebut.
+func LoadFundValuation(db *gorm.DB, userID, portfolioID, fundID uint, end time.Time) (*FundValuation, error) {
+	txs, err := LoadLedger(db, userID, portfolioID, fundID)
+	if err != nil {
+		return nil, err
+	}
+	return buildFundValuation(db, userID, portfolioID, fundID, txs, end)
+}
+
+// LoadPortfolioValuations menghitung nilai harian semua reksa dana di satu portfolio user (atau semua
+// portfolio jika portfolioID 0), terurut berdasarkan ID reksa dana
+func LoadPortfolioValuations(db *gorm.DB, userID, portfolioID uint, end time.Time) ([]FundValuation, error) {
+	txs, err := LoadLedger(db, userID, portfolioID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	byFund := make(map[uint][]models.Transaction)
+	var fundIDs []uint
+	for _, tx := range txs {
+		if _, ok := byFund[tx.MutualFundID]; !ok {
+			fundIDs = append(fundIDs, tx.MutualFundID)
+		}
+		byFund[tx.MutualFundID] = append(byFund[tx.MutualFundID], tx)
+	}
+	sort.Slice(fundIDs, func(i, j int) bool { return fundIDs[i] < fundIDs[j] })
+
+	valuations := make([]FundValuation, 0, len(fundIDs))
+	for _, fundID := range fundIDs {
+		fv, err := buildFundValuation(db, userID, portfolioID, fundID, byFund[fundID], end)
+		if err != nil {
+			return nil, err
+		}
+		if fv != nil {
+			valuations = append(valuations, *fv)
+		}
+	}
+	return valuations, nil
+}
+
+// buildFundValuation memastikan NAV sudah lengkap sampai end (NAV baru ikut memperbarui
+// portfolio_valuations), lalu membaca nilai harian dari portfolio_valuations
+func buildFundValuation(db *gorm.DB, userID, portfolioID, fundID uint, txs []models.Transaction, end time.Time) (*FundValuation, error) {
+	if len(txs) == 0 {
+		return nil, nil
+	}
+
+	// Mulai dari hari sebelumnya (-1 hari) untuk mendapatkan nilai awal
+	start := txs[0].Date.AddDate(0, 0, -1)
+	series, err := GetMutualFundNav(db, fundID, "custom", start.Format(dateLayout), DateOnly(end).Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := StoredDailyValuations(db, userID, portfolioID, fundID, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FundValuation{
+		MutualFundID: fundID,
+		ProductName:  series.ProductName,
+		Transactions: txs,
+		Navs:         series.Navs,
+		Days:         days,
+		Stale:        series.Stale,
+	}, nil
+}
+
+// CombineDailyValuations menggabungkan nilai harian beberapa reksa dana menjadi satu deret.
+// Tanggal yang dipakai adalah gabungan tanggal NAV semua reksa dana; reksa dana yang tidak punya
+// NAV pada suatu tanggal dinilai dengan nilai terakhirnya. Nav dan Units tidak diisi.
+func CombineDailyValuations(series [][]DailyValuation) []DailyValuation {
+	dateSet := make(map[time.Time]bool)
+	for _, days := range series {
+		for _, day := range days {
+			dateSet[day.Date] = true
+		}
+	}
+	dates := make([]time.Time, 0, len(dateSet))
+	for date := range dateSet {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	next := make([]int, len(series))
+	last := make([]*DailyValuation, len(series))
+	results := make([]DailyValuation, 0, len(dates))
+	prevValue := 0.0
+	for _, date := range dates {
+		point := DailyValuation{Date: date}
+		for i, days := range series {
+			if next[i] < len(days) && days[next[i]].Date.Equal(date) {
+				day := &days[next[i]]
+				point.CashIn += day.CashIn
+				point.CashOut += day.CashOut
+				point.DailyGain += day.DailyGain
+				last[i] = day
+				next[i]++
+			}
+			if last[i] != nil {
+				point.CostBasis += last[i].CostBasis
+				point.Value += last[i].Value
+				point.TotalGain += last[i].TotalGain
+				point.Fees += last[i].Fees
+				point.GrossTotalGain += last[i].GrossTotalGain
+				point.Dividends += last[i].Dividends
+				point.FxGain += last[i].FxGain
+			}
+		}
+		if base := prevValue + point.CashIn; base != 0 {
+			point.DailyGainPercent = point.DailyGain / base * 100
+		}
+		results = append(results, point)
+		prevValue = point.Value
+	}
+	return results
+}
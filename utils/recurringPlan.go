@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"fmt"
+	"golang/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PlanExecutionPreview adalah satu jadwal eksekusi rencana berkala yang akan datang
+type PlanExecutionPreview struct {
+	ScheduledDate string `json:"scheduled_date"`
+	ExecutionDate string `json:"execution_date"`
+}
+
+// planTimeZone adalah zona waktu jatuh tempo rencana berkala, sama dengan scheduler-nya
+const planTimeZone = "Asia/Jakarta"
+
+// PlanLocation mengembalikan zona waktu yang menentukan "hari ini" untuk rencana berkala
+func PlanLocation() (*time.Location, error) {
+	return time.LoadLocation(planTimeZone)
+}
+
+// LocalDate mengembalikan tanggal kalender now di zona waktu loc (jam 00:00 UTC, seperti kolom date)
+func LocalDate(now time.Time, loc *time.Location) time.Time {
+	local := now.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// HolidayCalendar adalah kumpulan hari libur bursa (market_holidays), dengan kunci YYYY-MM-DD
+type HolidayCalendar map[string]bool
+
+// LoadHolidayCalendar membaca hari libur bursa mulai tanggal from
+func LoadHolidayCalendar(db *gorm.DB, from time.Time) (HolidayCalendar, error) {
+	var dates []time.Time
+	if err := db.Model(&models.MarketHoliday{}).Where("date >= ?", DateOnly(from)).Pluck("date", &dates).Error; err != nil {
+		return nil, err
+	}
+	holidays := make(HolidayCalendar, len(dates))
+	for _, d := range dates {
+		holidays[d.Format(dateLayout)] = true
+	}
+	return holidays, nil
+}
+
+// NextBusinessDay mengembalikan date jika hari bursa, atau hari bursa berikutnya jika date jatuh
+// pada akhir pekan atau hari libur di holidays
+func NextBusinessDay(date time.Time, holidays HolidayCalendar) time.Time {
+	d := DateOnly(date)
+	for d.Weekday() == time.Saturday || d.Weekday() == time.Sunday || holidays[d.Format(dateLayout)] {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// planDateInMonth mengembalikan tanggal dayOfMonth pada bulan year/month, dibatasi tanggal terakhir bulan itu
+func planDateInMonth(year int, month time.Month, dayOfMonth int) time.Time {
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if dayOfMonth > lastDay {
+		dayOfMonth = lastDay
+	}
+	return time.Date(year, month, dayOfMonth, 0, 0, 0, 0, time.UTC)
+}
+
+// FirstPlanDate mengembalikan jatuh tempo pertama pada atau setelah tanggal mulai rencana
+func FirstPlanDate(plan models.RecurringPlan) time.Time {
+	start := DateOnly(plan.StartDate)
+	first := planDateInMonth(start.Year(), start.Month(), plan.DayOfMonth)
+	if first.Before(start) {
+		first = planDateInMonth(start.Year(), start.Month()+1, plan.DayOfMonth)
+	}
+	return first
+}
+
+// NextPlanDate mengembalikan jatuh tempo setelah current sesuai frekuensi rencana
+func NextPlanDate(plan models.RecurringPlan, current time.Time) time.Time {
+	months := models.PlanFrequencyMonths(plan.Frequency)
+	return planDateInMonth(current.Year(), current.Month()+time.Month(months), plan.DayOfMonth)
+}
+
+// PlanEnded bernilai true jika jatuh tempo date sudah melewati tanggal akhir rencana
+func PlanEnded(plan models.RecurringPlan, date time.Time) bool {
+	return plan.EndDate != nil && DateOnly(date).After(DateOnly(*plan.EndDate))
+}
+
+// UpcomingPlanExecutions menampilkan sampai count jadwal eksekusi berikutnya dari rencana aktif
+func UpcomingPlanExecutions(plan models.RecurringPlan, count int, holidays HolidayCalendar) []PlanExecutionPreview {
+	previews := []PlanExecutionPreview{}
+	if plan.Status != models.PlanStatusActive {
+		return previews
+	}
+	for date := plan.NextRunDate; len(previews) < count && !PlanEnded(plan, date); date = NextPlanDate(plan, date) {
+		previews = append(previews, PlanExecutionPreview{
+			ScheduledDate: date.Format(dateLayout),
+			ExecutionDate: NextBusinessDay(date, holidays).Format(dateLayout),
+		})
+	}
+	return previews
+}
+
+// advancePlan memindahkan NextRunDate ke jatuh tempo berikutnya dan menandai rencana selesai jika
+// sudah melewati tanggal akhir
+func advancePlan(db *gorm.DB, plan *models.RecurringPlan) error {
+	plan.NextRunDate = NextPlanDate(*plan, plan.NextRunDate)
+	if PlanEnded(*plan, plan.NextRunDate) {
+		plan.Status = models.PlanStatusCompleted
+	}
+	return db.Model(plan).Select("next_run_date", "last_run_date", "status").Updates(plan).Error
+}
+
+// SkipPlanExecution melewati jatuh tempo berikutnya tanpa membuat pembelian
+func SkipPlanExecution(db *gorm.DB, plan *models.RecurringPlan) (*models.RecurringPlanExecution, error) {
+	if plan.Status != models.PlanStatusActive && plan.Status != models.PlanStatusPaused {
+		return nil, fmt.Errorf("plan is %s", plan.Status)
+	}
+
+	holidays, err := LoadHolidayCalendar(db, plan.NextRunDate)
+	if err != nil {
+		return nil, err
+	}
+	execution := models.RecurringPlanExecution{
+		PlanID:        plan.ID,
+		ScheduledDate: plan.NextRunDate,
+		ExecutionDate: NextBusinessDay(plan.NextRunDate, holidays),
+		Status:        models.PlanExecutionSkipped,
+	}
+	err = db.Transaction(func(dbtx *gorm.DB) error {
+		if err := dbtx.Create(&execution).Error; err != nil {
+			return err
+		}
+		return advancePlan(dbtx, plan)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &execution, nil
+}
+
+// ExecuteDuePlan membuat pembelian untuk setiap jatuh tempo rencana yang hari bursanya sudah
+// tiba (<= today), termasuk yang tertinggal. Setiap jatuh tempo disimpan dalam satu transaksi
+// database bersama pembeliannya, sehingga tidak ada pembelian ganda jika dijalankan ulang.
+func ExecuteDuePlan(db *gorm.DB, plan *models.RecurringPlan, today time.Time) ([]models.RecurringPlanExecution, error) {
+	var executions []models.RecurringPlanExecution
+	today = DateOnly(today)
+	holidays, err := LoadHolidayCalendar(db, plan.NextRunDate)
+	if err != nil {
+		return nil, err
+	}
+	for plan.Status == models.PlanStatusActive && !NextBusinessDay(plan.NextRunDate, holidays).After(today) {
+		if PlanEnded(*plan, plan.NextRunDate) {
+			plan.Status = models.PlanStatusCompleted
+			if err := db.Model(plan).Update("status", plan.Status).Error; err != nil {
+				return executions, err
+			}
+			break
+		}
+
+		executionDate := NextBusinessDay(plan.NextRunDate, holidays)
+		execution := models.RecurringPlanExecution{
+			PlanID:        plan.ID,
+			ScheduledDate: plan.NextRunDate,
+			ExecutionDate: executionDate,
+			Status:        models.PlanExecutionExecuted,
+		}
+		err := db.Transaction(func(dbtx *gorm.DB) error {
+			tx := models.Transaction{
+				UserID:       plan.UserID,
+				PortfolioID:  plan.PortfolioID,
+				MutualFundID: plan.MutualFundID,
+				Type:         models.TransactionBuy,
+				Date:         executionDate,
+				Amount:       plan.Amount,
+				Fee:          plan.Fee,
+				Note:         fmt.Sprintf("Recurring plan #%d", plan.ID),
+			}
+			if err := RecordTransaction(dbtx, &tx); err != nil {
+				return err
+			}
+			execution.TransactionID = &tx.ID
+			if err := dbtx.Create(&execution).Error; err != nil {
+				return err
+			}
+			plan.LastRunDate = &executionDate
+			return advancePlan(dbtx, plan)
+		})
+		if err != nil {
+			return executions, err
+		}
+		executions = append(executions, execution)
+	}
+	return executions, nil
+}
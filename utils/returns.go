@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrNoSolution dikembalikan jika XIRR tidak bisa dihitung, misalnya semua arus kas searah
+var ErrNoSolution = errors.New("XIRR has no solution for these cash flows")
+
+// CashFlow adalah arus kas dari sisi investor: negatif untuk uang yang disetor, positif untuk
+// uang yang diterima (penjualan, dividen tunai, atau nilai akhir)
+type CashFlow struct {
+	Date   time.Time `json:"date"`
+	Amount float64   `json:"amount"`
+}
+
+// XIRR menghitung tingkat pengembalian tahunan (money-weighted) untuk arus kas bertanggal,
+// memakai basis 365 hari seperti fungsi XIRR di spreadsheet.
+func XIRR(flows []CashFlow) (float64, error) {
+	if len(flows) < 2 {
+		return 0, ErrNoSolution
+	}
+	hasNegative, hasPositive := false, false
+	for _, f := range flows {
+		hasNegative = hasNegative || f.Amount < 0
+		hasPositive = hasPositive || f.Amount > 0
+	}
+	if !hasNegative || !hasPositive {
+		return 0, ErrNoSolution
+	}
+
+	first := flows[0].Date
+	for _, f := range flows {
+		if f.Date.Before(first) {
+			first = f.Date
+		}
+	}
+	years := make([]float64, len(flows))
+	for i, f := range flows {
+		years[i] = f.Date.Sub(first).Hours() / 24 / 365
+	}
+
+	npv := func(rate float64) float64 {
+		var sum float64
+		for i, f := range flows {
+			sum += f.Amount / math.Pow(1+rate, years[i])
+		}
+		return sum
+	}
+	derivative := func(rate float64) float64 {
+		var sum float64
+		for i, f := range flows {
+			sum -= years[i] * f.Amount / math.Pow(1+rate, years[i]+1)
+		}
+		return sum
+	}
+
+	// Newton-Raphson dulu; jika tidak konvergen pakai bisection
+	rate := 0.1
+	for i := 0; i < 50; i++ {
+		value, slope := npv(rate), derivative(rate)
+		if slope == 0 {
+			break
+		}
+		nextRate := rate - value/slope
+		if nextRate <= -1 || math.IsNaN(nextRate) || math.IsInf(nextRate, 0) {
+			break
+		}
+		if math.Abs(nextRate-rate) < 1e-10 {
+			return nextRate, nil
+		}
+		rate = nextRate
+	}
+
+	low, high := -0.999999, 1.0
+	for npv(low)*npv(high) > 0 {
+		high *= 2
+		if high > 1e6 {
+			return 0, ErrNoSolution
+		}
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		if npv(low)*npv(mid) <= 0 {
+			high = mid
+		} else {
+			low = mid
+		}
+		if high-low < 1e-10 {
+			break
+		}
+	}
+	return (low + high) / 2, nil
+}
+
+// ReturnMetrics adalah ringkasan kinerja sebuah kepemilikan pada rentang [From, To]
+type ReturnMetrics struct {
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	StartValue float64 `json:"start_value"`
+	EndValue   float64 `json:"end_value"`
+	Invested   float64 `json:"invested"`
+	Withdrawn  float64 `json:"withdrawn"`
+	Gain       float64 `json:"gain"`
+	// Money-weighted return tahunan, nil jika tidak bisa dihitung
+	XIRR *float64 `json:"xirr"`
+	// Time-weighted return kumulatif dan tahunan (desimal, 0.1 = 10%). AnnualizedTWR nil untuk rentang
+	// di bawah satu tahun, karena return jangka pendek yang disetahunkan membesar-besarkan hasil.
+	TWR           float64  `json:"twr"`
+	AnnualizedTWR *float64 `json:"annualized_twr"`
+}
+
+// ComputeReturns menghitung XIRR dan TWR dari nilai harian (hasil BuildDailyValuation atau
+// CombineDailyValuations) untuk rentang [from, to]. Nilai pada hari NAV terakhir sebelum from
+// dianggap sebagai setoran awal pada from, dan nilai terakhir sampai to sebagai penerimaan akhir pada to.
+// TWR dirangkai dari return harian yang sudah dibersihkan dari arus kas (lihat twrFactor).
+func ComputeReturns(days []DailyValuation, from, to time.Time) ReturnMetrics {
+	from, to = DateOnly(from), DateOnly(to)
+	metrics := ReturnMetrics{From: from.Format(dateLayout), To: to.Format(dateLayout)}
+
+	var (
+		flows     []CashFlow
+		prevValue float64
+		started   bool
+		growth    = 1.0
+	)
+	for _, day := range days {
+		if day.Date.Before(from) {
+			prevValue = day.Value
+			continue
+		}
+		if day.Date.After(to) {
+			break
+		}
+		if !started {
+			started = true
+			metrics.StartValue = prevValue
+			if prevValue > 0 {
+				flows = append(flows, CashFlow{Date: from, Amount: -prevValue})
+			}
+		}
+
+		if day.CashIn != 0 {
+			flows = append(flows, CashFlow{Date: day.Date, Amount: -day.CashIn})
+		}
+		if day.CashOut != 0 {
+			flows = append(flows, CashFlow{Date: day.Date, Amount: day.CashOut})
+		}
+		metrics.Invested += day.CashIn
+		metrics.Withdrawn += day.CashOut
+
+		growth *= twrFactor(prevValue, day)
+		prevValue = day.Value
+		metrics.EndValue = day.Value
+	}
+	if !started {
+		metrics.StartValue, metrics.EndValue = prevValue, prevValue
+		return metrics
+	}
+
+	if metrics.EndValue > 0 {
+		flows = append(flows, CashFlow{Date: to, Amount: metrics.EndValue})
+	}
+	metrics.Gain = metrics.EndValue + metrics.Withdrawn - metrics.StartValue - metrics.Invested
+	if rate, err := XIRR(flows); err == nil {
+		metrics.XIRR = &rate
+	}
+
+	metrics.TWR = growth - 1
+	if span := to.Sub(from).Hours() / 24; span >= 365 && growth > 0 {
+		annualized := math.Pow(growth, 365/span) - 1
+		metrics.AnnualizedTWR = &annualized
+	}
+	return metrics
+}
+
+// twrFactor mengembalikan faktor pertumbuhan satu hari untuk TWR. Transaksi dieksekusi dengan NAV hari
+// itu, jadi return hari itu dihitung dari nilai kemarin setelah arus kas hari itu dikeluarkan. Jika belum
+// ada nilai kemarin, setoran hari itu menjadi basisnya (sehingga fee pembelian tetap terhitung).
+func twrFactor(prevValue float64, day DailyValuation) float64 {
+	if prevValue > 0 {
+		return (day.Value + day.CashOut - day.CashIn) / prevValue
+	}
+	if day.CashIn > 0 {
+		return (day.Value + day.CashOut) / day.CashIn
+	}
+	return 1
+}
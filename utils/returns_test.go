@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func mustDate(s string) time.Time {
+	d, err := time.Parse(dateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func assertClose(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-6 {
+		t.Errorf("%s = %.9f, want %.9f", name, got, want)
+	}
+}
+
+func TestXIRRSingleFlow(t *testing.T) {
+	_, err := XIRR([]CashFlow{{Date: mustDate("2021-01-01"), Amount: -1000}})
+	if !errors.Is(err, ErrNoSolution) {
+		t.Fatalf("err = %v, want ErrNoSolution", err)
+	}
+}
+
+func TestXIRROneYear(t *testing.T) {
+	// 365 hari tepat: 1000 menjadi 1100 berarti 10% per tahun
+	rate, err := XIRR([]CashFlow{
+		{Date: mustDate("2021-01-01"), Amount: -1000},
+		{Date: mustDate("2022-01-01"), Amount: 1100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "xirr", rate, 0.10)
+}
+
+func TestXIRRMultipleFlows(t *testing.T) {
+	// Contoh dari dokumentasi fungsi XIRR spreadsheet, hasilnya 37.336%
+	rate, err := XIRR([]CashFlow{
+		{Date: mustDate("2008-01-01"), Amount: -10000},
+		{Date: mustDate("2008-03-01"), Amount: 2750},
+		{Date: mustDate("2008-10-30"), Amount: 4250},
+		{Date: mustDate("2009-02-15"), Amount: 3250},
+		{Date: mustDate("2009-04-01"), Amount: 2750},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "xirr", rate, 0.373362535)
+}
+
+func TestComputeReturnsTerminalFlowOnRangeEnd(t *testing.T) {
+	// NAV terakhir 31 Desember, tapi rentang sampai 1 Januari: nilai akhir diterima pada to,
+	// sehingga periodenya tepat 365 hari dan XIRR = 10%
+	days := []DailyValuation{
+		{Date: mustDate("2021-01-01"), CashIn: 1000, Value: 1000},
+		{Date: mustDate("2021-12-31"), Value: 1100},
+	}
+	m := ComputeReturns(days, mustDate("2021-01-01"), mustDate("2022-01-01"))
+	if m.XIRR == nil {
+		t.Fatal("xirr is nil")
+	}
+	assertClose(t, "xirr", *m.XIRR, 0.10)
+	assertClose(t, "twr", m.TWR, 0.10)
+	if m.AnnualizedTWR == nil {
+		t.Fatal("annualized twr is nil for a one-year range")
+	}
+	assertClose(t, "annualized twr", *m.AnnualizedTWR, 0.10)
+	assertClose(t, "gain", m.Gain, 100)
+}
+
+func TestComputeReturnsFlowOnSameDay(t *testing.T) {
+	// Setoran 500 pada hari terakhir dibeli dengan NAV hari itu: 1000 awal sudah menjadi 1100,
+	// jadi TWR dan XIRR tetap 10% dan setoran baru tidak ikut dihitung tumbuh
+	days := []DailyValuation{
+		{Date: mustDate("2021-01-01"), CashIn: 1000, Value: 1000},
+		{Date: mustDate("2022-01-01"), CashIn: 500, Value: 1600},
+	}
+	m := ComputeReturns(days, mustDate("2021-01-01"), mustDate("2022-01-01"))
+	assertClose(t, "twr", m.TWR, 0.10)
+	if m.XIRR == nil {
+		t.Fatal("xirr is nil")
+	}
+	assertClose(t, "xirr", *m.XIRR, 0.10)
+	assertClose(t, "invested", m.Invested, 1500)
+	assertClose(t, "gain", m.Gain, 100)
+}
+
+func TestComputeReturnsUnderOneYear(t *testing.T) {
+	// 181 hari dengan kenaikan 5%: TWR kumulatif 5%, tidak disetahunkan;
+	// XIRR tetap tahunan: 1.05^(365/181) - 1
+	days := []DailyValuation{
+		{Date: mustDate("2021-01-01"), CashIn: 1000, Value: 1000},
+		{Date: mustDate("2021-03-15"), Value: 980},
+		{Date: mustDate("2021-07-01"), Value: 1050},
+	}
+	m := ComputeReturns(days, mustDate("2021-01-01"), mustDate("2021-07-01"))
+	assertClose(t, "twr", m.TWR, 0.05)
+	if m.AnnualizedTWR != nil {
+		t.Errorf("annualized twr = %f, want nil under one year", *m.AnnualizedTWR)
+	}
+	if m.XIRR == nil {
+		t.Fatal("xirr is nil")
+	}
+	assertClose(t, "xirr", *m.XIRR, 0.103391927)
+}
+
+func TestComputeReturnsStartsFromPriorValue(t *testing.T) {
+	// Nilai sebelum from menjadi setoran awal: 2000 pada from, 2200 setahun kemudian
+	days := []DailyValuation{
+		{Date: mustDate("2020-06-01"), CashIn: 1500, Value: 1500},
+		{Date: mustDate("2020-12-31"), Value: 2000},
+		{Date: mustDate("2021-12-31"), Value: 2200},
+	}
+	m := ComputeReturns(days, mustDate("2021-01-01"), mustDate("2022-01-01"))
+	assertClose(t, "start value", m.StartValue, 2000)
+	assertClose(t, "invested", m.Invested, 0)
+	assertClose(t, "twr", m.TWR, 0.10)
+	if m.XIRR == nil {
+		t.Fatal("xirr is nil")
+	}
+	assertClose(t, "xirr", *m.XIRR, 0.10)
+}
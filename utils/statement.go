@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"golang/models"
+	"sort"
+	"time"
+)
+
+// StatementFund adalah posisi satu reksa dana di laporan (statement) periode tertentu
+type StatementFund struct {
+	MutualFundID uint    `json:"mutual_fund_id"`
+	Name         string  `json:"name"`
+	OpeningUnits float64 `json:"opening_units"`
+	OpeningNav   float64 `json:"opening_nav"`
+	OpeningValue float64 `json:"opening_value"`
+	ClosingUnits float64 `json:"closing_units"`
+	ClosingNav   float64 `json:"closing_nav"`
+	NavDate      string  `json:"nav_date"`
+	ClosingValue float64 `json:"closing_value"`
+	CostBasis    float64 `json:"cost_basis"`
+	CashIn       float64 `json:"cash_in"`
+	CashOut      float64 `json:"cash_out"`
+	// Keuntungan selama periode: nilai akhir - nilai awal - uang masuk + uang keluar
+	PeriodGain   float64 `json:"period_gain"`
+	RealizedGain float64 `json:"realized_gain"`
+	// Keuntungan total sejak transaksi pertama sampai akhir periode
+	TotalGain float64 `json:"total_gain"`
+	// Bagian keuntungan periode yang berasal dari perubahan kurs
+	FxGain float64 `json:"fx_gain"`
+}
+
+// StatementTransaction adalah satu baris transaksi di dalam periode statement
+type StatementTransaction struct {
+	ID           uint                   `json:"id"`
+	Date         string                 `json:"date"`
+	MutualFundID uint                   `json:"mutual_fund_id"`
+	Name         string                 `json:"name"`
+	Type         models.TransactionType `json:"type"`
+	Units        float64                `json:"units"`
+	Nav          float64                `json:"nav"`
+	Amount       float64                `json:"amount"`
+	Fee          float64                `json:"fee"`
+	RealizedGain float64                `json:"realized_gain"`
+	Pending      bool                   `json:"pending"`
+}
+
+// Statement adalah laporan portfolio untuk rentang tanggal StartDate sampai EndDate
+type Statement struct {
+	PortfolioName  string                 `json:"portfolio_name"`
+	Currency       string                 `json:"currency"`
+	StartDate      string                 `json:"start_date"`
+	EndDate        string                 `json:"end_date"`
+	GeneratedAt    time.Time              `json:"generated_at"`
+	OpeningBalance float64                `json:"opening_balance"`
+	CashIn         float64                `json:"cash_in"`
+	CashOut        float64                `json:"cash_out"`
+	PeriodGain     float64                `json:"period_gain"`
+	RealizedGain   float64                `json:"realized_gain"`
+	FxGain         float64                `json:"fx_gain"`
+	ClosingBalance float64                `json:"closing_balance"`
+	Funds          []StatementFund        `json:"funds"`
+	Transactions   []StatementTransaction `json:"transactions"`
+}
+
+// BuildStatement menyusun statement dari nilai harian setiap reksa dana (lihat LoadPortfolioValuations).
+// Saldo awal adalah nilai pada hari NAV terakhir sebelum start; saldo akhir pada hari NAV terakhir
+// sampai end. names berisi nama reksa dana berdasarkan ID, dengan ProductName sebagai cadangan.
+// Nilai mengikuti mata uang valuations (lihat ConvertValuations).
+func BuildStatement(valuations []FundValuation, names map[uint]string, start, end time.Time) Statement {
+	start, end = DateOnly(start), DateOnly(end)
+	statement := Statement{
+		StartDate:    start.Format(dateLayout),
+		EndDate:      end.Format(dateLayout),
+		GeneratedAt:  time.Now(),
+		Funds:        []StatementFund{},
+		Transactions: []StatementTransaction{},
+	}
+
+	for _, fv := range valuations {
+		name := names[fv.MutualFundID]
+		if name == "" {
+			name = fv.ProductName
+		}
+		item := StatementFund{MutualFundID: fv.MutualFundID, Name: name}
+
+		var openingFxGain float64
+		for _, day := range fv.Days {
+			if day.Date.After(end) {
+				break
+			}
+			if day.Date.Before(start) {
+				item.OpeningUnits = day.Units
+				item.OpeningNav = day.Nav
+				item.OpeningValue = day.Value
+				openingFxGain = day.FxGain
+				continue
+			}
+			item.CashIn += day.CashIn
+			item.CashOut += day.CashOut
+			item.ClosingUnits = day.Units
+			item.ClosingNav = day.Nav
+			item.NavDate = day.Date.Format(dateLayout)
+			item.ClosingValue = day.Value
+			item.CostBasis = day.CostBasis
+			item.TotalGain = day.TotalGain
+			item.FxGain = day.FxGain - openingFxGain
+		}
+		// Tidak ada hari NAV di dalam periode: posisi akhir sama dengan posisi awal
+		if item.NavDate == "" {
+			item.ClosingUnits = item.OpeningUnits
+			item.ClosingNav = item.OpeningNav
+			item.ClosingValue = item.OpeningValue
+		}
+		item.PeriodGain = item.ClosingValue - item.OpeningValue - item.CashIn + item.CashOut
+
+		for _, tx := range fv.Transactions {
+			if tx.Date.Before(start) || tx.Date.After(end) {
+				continue
+			}
+			if tx.Type == models.TransactionSell || tx.Type == models.TransactionSwitchOut {
+				item.RealizedGain += tx.RealizedGain
+			}
+			statement.Transactions = append(statement.Transactions, StatementTransaction{
+				ID:           tx.ID,
+				Date:         tx.Date.Format(dateLayout),
+				MutualFundID: tx.MutualFundID,
+				Name:         name,
+				Type:         tx.Type,
+				Units:        tx.Units,
+				Nav:          tx.Nav,
+				Amount:       tx.Amount,
+				Fee:          tx.Fee,
+				RealizedGain: tx.RealizedGain,
+				Pending:      tx.Pending(),
+			})
+		}
+
+		// Reksa dana yang sudah habis dijual sebelum periode tidak perlu ditampilkan
+		if item.OpeningUnits == 0 && item.ClosingUnits == 0 && item.CashIn == 0 && item.CashOut == 0 {
+			continue
+		}
+		statement.Funds = append(statement.Funds, item)
+		statement.OpeningBalance += item.OpeningValue
+		statement.ClosingBalance += item.ClosingValue
+		statement.CashIn += item.CashIn
+		statement.CashOut += item.CashOut
+		statement.PeriodGain += item.PeriodGain
+		statement.RealizedGain += item.RealizedGain
+		statement.FxGain += item.FxGain
+	}
+
+	sort.SliceStable(statement.Transactions, func(i, j int) bool {
+		a, b := statement.Transactions[i], statement.Transactions[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		return a.ID < b.ID
+	})
+	return statement
+}
@@ -0,0 +1,407 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format ekspor laporan (statement, laporan tahunan) yang didukung
+const (
+	StatementFormatCSV  = "csv"
+	StatementFormatXLSX = "xlsx"
+	StatementFormatPDF  = "pdf"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported statement format")
+
+// StatementContentType mengembalikan MIME type untuk format laporan
+func StatementContentType(format string) string {
+	switch format {
+	case StatementFormatCSV:
+		return "text/csv; charset=utf-8"
+	case StatementFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case StatementFormatPDF:
+		return "application/pdf"
+	}
+	return "application/octet-stream"
+}
+
+// WriteStatement menulis statement ke w dalam format csv, xlsx, atau pdf
+func WriteStatement(w io.Writer, s Statement, format string) error {
+	title := fmt.Sprintf("Laporan Portfolio %s (%s s/d %s)", s.PortfolioName, s.StartDate, s.EndDate)
+	return writeTables(w, format, title, statementTables(s))
+}
+
+func writeTables(w io.Writer, format, title string, tables []statementTable) error {
+	switch format {
+	case StatementFormatCSV:
+		return writeTablesCSV(w, tables)
+	case StatementFormatXLSX:
+		return writeTablesXLSX(w, tables)
+	case StatementFormatPDF:
+		return writeTablesPDF(w, title, tables)
+	}
+	return fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+}
+
+// statementNumber adalah sel angka yang ditampilkan dengan jumlah desimal tertentu
+type statementNumber struct {
+	Value    float64
+	Decimals int
+}
+
+func statementMoney(v float64) statementNumber { return statementNumber{v, 2} }
+func statementUnits(v float64) statementNumber { return statementNumber{v, 4} }
+
+// statementTable adalah satu bagian statement; sel berisi string atau statementNumber
+type statementTable struct {
+	Title  string
+	Header []string
+	Rows   [][]any
+}
+
+func formatCell(v any) string {
+	if n, ok := v.(statementNumber); ok {
+		return strconv.FormatFloat(n.Value, 'f', n.Decimals, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func statementTables(s Statement) []statementTable {
+	summary := statementTable{
+		Title:  "Ringkasan",
+		Header: []string{"Keterangan", "Nilai"},
+		Rows: [][]any{
+			{"Portfolio", s.PortfolioName},
+			{"Mata uang", s.Currency},
+			{"Periode", s.StartDate + " s/d " + s.EndDate},
+			{"Dibuat", s.GeneratedAt.Format("2006-01-02 15:04")},
+			{"Saldo awal", statementMoney(s.OpeningBalance)},
+			{"Uang masuk", statementMoney(s.CashIn)},
+			{"Uang keluar", statementMoney(s.CashOut)},
+			{"Keuntungan periode", statementMoney(s.PeriodGain)},
+			{"Keuntungan terealisasi", statementMoney(s.RealizedGain)},
+			{"Selisih kurs periode", statementMoney(s.FxGain)},
+			{"Saldo akhir", statementMoney(s.ClosingBalance)},
+		},
+	}
+
+	funds := statementTable{
+		Title: "Posisi per Reksa Dana",
+		Header: []string{"Reksa Dana", "Unit Awal", "Nilai Awal", "Unit Akhir", "NAV Akhir", "Tanggal NAV",
+			"Nilai Akhir", "Modal", "Keuntungan Periode", "Keuntungan Terealisasi", "Keuntungan Total"},
+	}
+	for _, f := range s.Funds {
+		funds.Rows = append(funds.Rows, []any{f.Name, statementUnits(f.OpeningUnits), statementMoney(f.OpeningValue),
+			statementUnits(f.ClosingUnits), statementUnits(f.ClosingNav), f.NavDate, statementMoney(f.ClosingValue), statementMoney(f.CostBasis),
+			statementMoney(f.PeriodGain), statementMoney(f.RealizedGain), statementMoney(f.TotalGain)})
+	}
+
+	txs := statementTable{
+		Title:  "Transaksi",
+		Header: []string{"Tanggal", "Reksa Dana", "Tipe", "Unit", "NAV", "Nominal", "Biaya", "Keuntungan Terealisasi"},
+	}
+	for _, tx := range s.Transactions {
+		txType := string(tx.Type)
+		if tx.Pending {
+			txType += " (pending)"
+		}
+		txs.Rows = append(txs.Rows, []any{tx.Date, tx.Name, txType, statementUnits(tx.Units), statementUnits(tx.Nav),
+			statementMoney(tx.Amount), statementMoney(tx.Fee), statementMoney(tx.RealizedGain)})
+	}
+
+	return []statementTable{summary, funds, txs}
+}
+
+// writeTablesCSV menulis setiap tabel berurutan, dipisahkan baris kosong dan diawali judulnya
+func writeTablesCSV(w io.Writer, tables []statementTable) error {
+	cw := csv.NewWriter(w)
+	for i, t := range tables {
+		if i > 0 {
+			cw.Write([]string{})
+		}
+		cw.Write([]string{t.Title})
+		cw.Write(t.Header)
+		for _, row := range t.Rows {
+			record := make([]string, len(row))
+			for j, cell := range row {
+				record[j] = formatCell(cell)
+			}
+			cw.Write(record)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeTablesXLSX menulis workbook SpreadsheetML minimal (satu sheet per tabel) tanpa library
+// eksternal. Teks disimpan sebagai inline string, angka sebagai sel numerik.
+func writeTablesXLSX(w io.Writer, tables []statementTable) error {
+	zw := zip.NewWriter(w)
+
+	var sheets, rels, overrides strings.Builder
+	for i := range tables {
+		n := i + 1
+		fmt.Fprintf(&sheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(tables[i].Title), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+	}
+
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			overrides.String() + `</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` + sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() + `</Relationships>`},
+	}
+	for i, t := range tables {
+		files = append(files, struct{ name, body string }{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), xlsxSheet(t)})
+	}
+
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func xlsxSheet(t statementTable) string {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	header := make([]any, len(t.Header))
+	for i, h := range t.Header {
+		header[i] = h
+	}
+	for r, row := range append([][]any{header}, t.Rows...) {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			ref := xlsxColumn(c) + strconv.Itoa(r+1)
+			if n, ok := cell.(statementNumber); ok {
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(n.Value, 'f', -1, 64))
+				continue
+			}
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, xmlEscape(formatCell(cell)))
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// xlsxColumn mengubah indeks kolom (0 = A) menjadi nama kolom Excel
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Ukuran halaman PDF A4 landscape dalam point dan font Courier agar kolom rata
+const (
+	pdfPageWidth   = 842
+	pdfPageHeight  = 595
+	pdfMargin      = 36
+	pdfFontSize    = 7
+	pdfLineHeight  = 9
+	pdfLinesOnPage = (pdfPageHeight - 2*pdfMargin) / pdfLineHeight
+	// Glyph Courier selebar 0.6 x ukuran font, jadi satu baris muat sekitar 183 karakter
+	pdfLineChars = (pdfPageWidth - 2*pdfMargin) * 10 / (6 * pdfFontSize)
+)
+
+// writeTablesPDF menulis PDF sederhana berisi tabel teks ber-font Courier, dipecah per halaman
+func writeTablesPDF(w io.Writer, title string, tables []statementTable) error {
+	lines := []string{title, ""}
+	for _, table := range tables {
+		for _, t := range splitTableColumns(table, pdfLineChars) {
+			lines = append(lines, t.Title)
+			lines = append(lines, textTable(t)...)
+			lines = append(lines, "")
+		}
+	}
+
+	var pages [][]string
+	for len(lines) > 0 {
+		n := min(len(lines), pdfLinesOnPage)
+		pages = append(pages, lines[:n])
+		lines = lines[n:]
+	}
+
+	// Objek 1 katalog, 2 daftar halaman, 3 font, lalu pasangan halaman + konten
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+	)
+	for i, page := range pages {
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLineHeight, pdfMargin, pdfPageHeight-pdfMargin)
+		for _, line := range page {
+			fmt.Fprintf(&content, "(%s) '\n", pdfEscape(line))
+		}
+		content.WriteString("ET")
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%EOF\n", len(objects)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// splitTableColumns memecah tabel yang barisnya lebih dari maxChars karakter menjadi beberapa tabel
+// berurutan. Kolom pertama (nama baris) diulang di setiap bagian agar tetap bisa dibaca.
+func splitTableColumns(t statementTable, maxChars int) []statementTable {
+	widths, _ := tableCells(t)
+	total := 0
+	for _, width := range widths {
+		total += width + 2
+	}
+	if len(widths) < 2 || total-2 <= maxChars {
+		return []statementTable{t}
+	}
+
+	var groups [][]int
+	var group []int
+	used := widths[0]
+	for c := 1; c < len(widths); c++ {
+		if len(group) > 0 && used+2+widths[c] > maxChars {
+			groups = append(groups, group)
+			group, used = nil, widths[0]
+		}
+		group = append(group, c)
+		used += 2 + widths[c]
+	}
+	groups = append(groups, group)
+
+	parts := make([]statementTable, len(groups))
+	for i, columns := range groups {
+		columns = append([]int{0}, columns...)
+		part := statementTable{Title: fmt.Sprintf("%s (%d/%d)", t.Title, i+1, len(groups))}
+		for _, c := range columns {
+			part.Header = append(part.Header, t.Header[c])
+		}
+		for _, row := range t.Rows {
+			values := make([]any, len(columns))
+			for j, c := range columns {
+				values[j] = row[c]
+			}
+			part.Rows = append(part.Rows, values)
+		}
+		parts[i] = part
+	}
+	return parts
+}
+
+// tableCells memformat semua sel tabel dan menghitung lebar setiap kolom
+func tableCells(t statementTable) ([]int, [][]string) {
+	widths := make([]int, len(t.Header))
+	for i, h := range t.Header {
+		widths[i] = len(h)
+	}
+	cells := make([][]string, len(t.Rows))
+	for r, row := range t.Rows {
+		cells[r] = make([]string, len(row))
+		for c, cell := range row {
+			cells[r][c] = formatCell(cell)
+			widths[c] = max(widths[c], len(cells[r][c]))
+		}
+	}
+	return widths, cells
+}
+
+// textTable merender tabel menjadi baris teks dengan kolom rata; angka rata kanan
+func textTable(t statementTable) []string {
+	widths, cells := tableCells(t)
+
+	render := func(values []string, row []any) string {
+		parts := make([]string, len(values))
+		for c, v := range values {
+			if _, ok := row[c].(statementNumber); ok {
+				parts[c] = fmt.Sprintf("%*s", widths[c], v)
+			} else {
+				parts[c] = fmt.Sprintf("%-*s", widths[c], v)
+			}
+		}
+		return strings.TrimRight(strings.Join(parts, "  "), " ")
+	}
+
+	header := make([]any, len(t.Header))
+	for i, h := range t.Header {
+		header[i] = h
+	}
+	lines := []string{render(t.Header, header)}
+	separator := make([]string, len(widths))
+	for i, width := range widths {
+		separator[i] = strings.Repeat("-", width)
+	}
+	lines = append(lines, strings.Join(separator, "  "))
+	for r, row := range t.Rows {
+		lines = append(lines, render(cells[r], row))
+	}
+	return lines
+}
+
+// pdfEscape meloloskan karakter khusus string PDF; karakter di luar ASCII diganti "?"
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"golang/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrSameFund dikembalikan jika reksa dana asal dan tujuan switching sama
+var ErrSameFund = errors.New("source and target mutual fund must differ")
+
+// SwitchRequest adalah permintaan pengalihan (switching) unit dari satu reksa dana ke reksa dana
+// lain. Isi salah satu dari Units atau Amount (nilai bruto di reksa dana asal), atau All.
+type SwitchRequest struct {
+	UserID         uint
+	PortfolioID    uint
+	FromFundID     uint
+	ToFundID       uint
+	Date           time.Time
+	SettlementDate *time.Time
+	Units          float64
+	Amount         float64
+	All            bool
+	CostMethod     string
+	Note           string
+}
+
+// SwitchResult berisi kedua kaki switching yang saling terhubung lewat LinkedTransactionID
+type SwitchResult struct {
+	Out     models.Transaction `json:"switch_out"`
+	In      models.Transaction `json:"switch_in"`
+	FeeRate float64            `json:"fee_rate"`
+}
+
+// AddBusinessDays menggeser date sebanyak n hari kerja (Senin-Jumat)
+func AddBusinessDays(date time.Time, n int) time.Time {
+	d := DateOnly(date)
+	for n > 0 {
+		d = d.AddDate(0, 0, 1)
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+			n--
+		}
+	}
+	return d
+}
+
+// SwitchFunds mencatat switching di dalam satu portfolio sebagai SWITCH_OUT di reksa dana asal (NAV tanggal transaksi,
+// dipotong biaya switching reksa dana asal) dan SWITCH_IN di reksa dana tujuan senilai hasil
+// bersihnya (NAV tanggal settlement, default T+1 hari kerja), dikonversi jika mata uang kedua reksa
+// dana berbeda. Kedua kaki disimpan dalam satu transaksi database.
+func SwitchFunds(db *gorm.DB, req SwitchRequest) (*SwitchResult, error) {
+	if req.FromFundID == req.ToFundID {
+		return nil, ErrSameFund
+	}
+	if req.PortfolioID == 0 {
+		portfolio, err := DefaultPortfolio(db, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		req.PortfolioID = portfolio.ID
+	}
+
+	var from, to models.MutualFund
+	if err := db.First(&from, req.FromFundID).Error; err != nil {
+		return nil, fmt.Errorf("source mutual fund %d: %w", req.FromFundID, err)
+	}
+	if err := db.First(&to, req.ToFundID).Error; err != nil {
+		return nil, fmt.Errorf("target mutual fund %d: %w", req.ToFundID, err)
+	}
+
+	// Teks biaya switching yang belum bisa di-parse tidak boleh dianggap 0%
+	feeRate := from.SwitchingFeeRate.Decimal()
+	if from.SwitchingFeeRate.Rate == nil && !isEmptyFeeText(from.SwitchingFee) {
+		rate, err := ParseFeeRate(from.SwitchingFee)
+		if err != nil {
+			return nil, fmt.Errorf("switching fee of mutual fund %d: %w", from.ID, err)
+		}
+		feeRate = rate.Decimal()
+	}
+
+	date := DateOnly(req.Date)
+	settlement := AddBusinessDays(date, 1)
+	if req.SettlementDate != nil {
+		settlement = DateOnly(*req.SettlementDate)
+	}
+	if settlement.Before(date) {
+		return nil, fmt.Errorf("settlement date must not be before the switch date")
+	}
+
+	out := models.Transaction{
+		UserID:       req.UserID,
+		PortfolioID:  req.PortfolioID,
+		MutualFundID: from.ID,
+		Type:         models.TransactionSwitchOut,
+		Date:         date,
+		Units:        req.Units,
+		Amount:       req.Amount,
+		CostMethod:   req.CostMethod,
+		Note:         req.Note,
+	}
+	if req.All {
+		held, err := UnitsHeld(db, req.UserID, req.PortfolioID, from.ID, date)
+		if err != nil {
+			return nil, err
+		}
+		out.Units, out.Amount = held, 0
+	}
+
+	// NAV reksa dana asal harus sudah terbit karena nilai yang dipindahkan bergantung padanya.
+	// NAV diambil sebelum transaksi database agar panggilan ke provider tidak menahan lock.
+	if err := ApplyTradeNav(db, &out); err != nil {
+		return nil, err
+	}
+	if out.Units <= 0 {
+		return nil, fmt.Errorf("%w: nothing to switch out of mutual fund %d", ErrInsufficientUnits, from.ID)
+	}
+	out.Fee = out.Amount * feeRate
+
+	// Switching ke reksa dana dengan mata uang lain dikonversi dengan kurs tanggal switching
+	proceeds, err := ConvertAmount(db, out.Amount-out.Fee, from.Currency, to.Currency, date)
+	if err != nil {
+		return nil, err
+	}
+
+	in := models.Transaction{
+		UserID:         req.UserID,
+		PortfolioID:    req.PortfolioID,
+		MutualFundID:   to.ID,
+		Type:           models.TransactionSwitchIn,
+		Date:           settlement,
+		SettlementDate: &settlement,
+		Amount:         proceeds,
+		Note:           req.Note,
+	}
+	if err := ApplyTradeNav(db, &in); err != nil && !errors.Is(err, ErrNavNotAvailable) {
+		return nil, err
+	}
+
+	err = db.Transaction(func(dbtx *gorm.DB) error {
+		if err := RecordTransaction(dbtx, &out); err != nil {
+			return err
+		}
+		in.LinkedTransactionID = &out.ID
+		if err := RecordTransaction(dbtx, &in); err != nil {
+			return err
+		}
+		out.LinkedTransactionID = &in.ID
+		return dbtx.Model(&out).Update("linked_transaction_id", in.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SwitchResult{Out: out, In: in, FeeRate: feeRate}, nil
+}
@@ -0,0 +1,539 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"golang/models"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Status baris dan status pencocokan reksa dana pada impor CSV
+const (
+	ImportRowReady     = "ready"
+	ImportRowDuplicate = "duplicate"
+	ImportRowUnmatched = "unmatched"
+	ImportRowInvalid   = "invalid"
+
+	FundMatchMatched   = "matched"
+	FundMatchConfirmed = "confirmed"
+	FundMatchAmbiguous = "ambiguous"
+	FundMatchUnmatched = "unmatched"
+)
+
+var ErrImportNotReady = errors.New("import has unmatched or invalid rows")
+
+// switchSettlementDays adalah jarak maksimal tanggal SWITCH_IN setelah SWITCH_OUT pasangannya pada impor
+const switchSettlementDays = 7
+
+// ImportRow adalah satu baris file impor yang sudah dibaca memakai ImportMapping
+type ImportRow struct {
+	Line         int                    `json:"line"`
+	Date         string                 `json:"date"`
+	FundKey      string                 `json:"fund_key"`
+	MutualFundID uint                   `json:"mutual_fund_id,omitempty"`
+	Type         models.TransactionType `json:"type"`
+	Amount       float64                `json:"amount"`
+	Units        float64                `json:"units"`
+	Nav          float64                `json:"nav"`
+	Fee          float64                `json:"fee"`
+	Status       string                 `json:"status"`
+	Detail       string                 `json:"detail,omitempty"`
+
+	date     time.Time
+	fundName string
+	fundCode string
+}
+
+// FundCandidate adalah reksa dana yang mungkin cocok dengan nama di file impor
+type FundCandidate struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	PID  uint   `json:"pid"`
+}
+
+// FundMatch adalah hasil pencocokan satu nama/kode reksa dana di file impor. Key dipakai sebagai
+// kunci konfirmasi (fund_map) saat commit.
+type FundMatch struct {
+	Key          string          `json:"key"`
+	Name         string          `json:"name"`
+	Code         string          `json:"code,omitempty"`
+	Status       string          `json:"status"`
+	MutualFundID uint            `json:"mutual_fund_id,omitempty"`
+	Candidates   []FundCandidate `json:"candidates,omitempty"`
+	Rows         int             `json:"rows"`
+}
+
+// ImportPreview adalah hasil dry run impor: setiap baris beserta statusnya dan pencocokan reksa dana
+type ImportPreview struct {
+	Source     string      `json:"source"`
+	TotalRows  int         `json:"total_rows"`
+	Ready      int         `json:"ready"`
+	Duplicates int         `json:"duplicates"`
+	Unmatched  int         `json:"unmatched"`
+	Invalid    int         `json:"invalid"`
+	Funds      []FundMatch `json:"funds"`
+	Rows       []ImportRow `json:"rows"`
+}
+
+// CanCommit bernilai true jika semua baris sudah siap diimpor atau merupakan duplikat
+func (p ImportPreview) CanCommit() bool {
+	return p.Unmatched == 0 && p.Invalid == 0
+}
+
+// normalizeImportText mengubah teks menjadi huruf kecil dengan satu spasi di antara kata,
+// tanpa tanda baca, agar nama reksa dana dan jenis transaksi bisa dibandingkan
+func normalizeImportText(s string) string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// ParseImportCSV membaca file CSV memakai mapping. Baris yang tidak bisa dibaca tetap dikembalikan
+// dengan status invalid agar bisa dilaporkan; error hanya untuk file atau header yang tidak valid.
+func ParseImportCSV(r io.Reader, m ImportMapping) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	if m.Delimiter != "" {
+		reader.Comma = []rune(m.Delimiter)[0]
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	column := func(name string) int {
+		if name == "" {
+			return -1
+		}
+		if i, ok := columns[strings.ToLower(name)]; ok {
+			return i
+		}
+		return -1
+	}
+
+	dateCol, nameCol, codeCol := column(m.DateColumn), column(m.FundNameColumn), column(m.FundCodeColumn)
+	typeCol, amountCol, unitsCol := column(m.TypeColumn), column(m.AmountColumn), column(m.UnitsColumn)
+	navCol, feeCol := column(m.NavColumn), column(m.FeeColumn)
+	if dateCol < 0 {
+		return nil, fmt.Errorf("missing column %q", m.DateColumn)
+	}
+	if nameCol < 0 && codeCol < 0 {
+		return nil, fmt.Errorf("missing column %q", m.FundNameColumn)
+	}
+	if amountCol < 0 && unitsCol < 0 {
+		return nil, fmt.Errorf("missing column %q or %q", m.AmountColumn, m.UnitsColumn)
+	}
+
+	var rows []ImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rows = append(rows, ImportRow{Line: line, Status: ImportRowInvalid, Detail: err.Error()})
+			continue
+		}
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		row := ImportRow{Line: line, Status: ImportRowReady, fundName: field(nameCol), fundCode: field(codeCol)}
+		row.FundKey = row.fundCode
+		if row.FundKey == "" {
+			row.FundKey = normalizeImportText(row.fundName)
+		}
+		if err := parseImportRow(&row, m, field(dateCol), field(typeCol), field(amountCol), field(unitsCol), field(navCol), field(feeCol)); err != nil {
+			row.Status, row.Detail = ImportRowInvalid, err.Error()
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseImportRow(row *ImportRow, m ImportMapping, date, txType, amount, units, nav, fee string) error {
+	if row.FundKey == "" {
+		return fmt.Errorf("missing fund name")
+	}
+
+	for _, layout := range m.DateLayouts {
+		if parsed, err := time.Parse(layout, date); err == nil {
+			row.date = DateOnly(parsed)
+			break
+		}
+	}
+	if row.date.IsZero() {
+		return fmt.Errorf("invalid date %q", date)
+	}
+	row.Date = row.date.Format(dateLayout)
+
+	row.Type = m.DefaultType
+	if txType != "" {
+		if t, ok := m.TypeValues[normalizeImportText(txType)]; ok {
+			row.Type = t
+		} else if t := models.TransactionType(strings.ToUpper(txType)); models.ValidTransactionType(t) {
+			row.Type = t
+		} else {
+			return fmt.Errorf("unknown transaction type %q", txType)
+		}
+	}
+
+	var err error
+	values := []struct {
+		raw string
+		dst *float64
+	}{{amount, &row.Amount}, {units, &row.Units}, {nav, &row.Nav}, {fee, &row.Fee}}
+	for _, v := range values {
+		if *v.dst, err = parseImportNumber(v.raw, m.DecimalComma); err != nil {
+			return err
+		}
+	}
+	if row.Amount == 0 && row.Units == 0 {
+		return fmt.Errorf("amount or units is required")
+	}
+	if row.Type != models.TransactionDividend && row.Amount > 0 && row.Fee >= row.Amount {
+		return fmt.Errorf("fee must be smaller than amount")
+	}
+	return nil
+}
+
+// parseImportNumber membaca angka dengan awalan "Rp" opsional; kosong dianggap 0. Tanda minus
+// diabaikan karena arah transaksi ditentukan oleh jenisnya.
+func parseImportNumber(raw string, decimalComma bool) (float64, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(raw), "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "Rp"), "IDR")
+	s = strings.TrimLeft(strings.TrimSpace(s), ".")
+	s = strings.ReplaceAll(s, " ", "")
+	if s == "" || s == "-" {
+		return 0, nil
+	}
+	if decimalComma {
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.ReplaceAll(s, ",", ".")
+	} else {
+		s = strings.ReplaceAll(s, ",", "")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", raw)
+	}
+	return math.Abs(v), nil
+}
+
+// MatchImportFunds mencocokkan nama/kode reksa dana di baris impor dengan tabel mutual_funds.
+// Kode dicocokkan dengan PID atau external_id, nama dicocokkan setelah dinormalisasi; kecocokan
+// tunggal dianggap matched. Nama yang hanya mirip (salah satu mengandung yang lain) menjadi
+// ambiguous dan harus dikonfirmasi lewat confirmed (key -> ID reksa dana).
+func MatchImportFunds(db *gorm.DB, rows []ImportRow, confirmed map[string]uint) ([]FundMatch, error) {
+	var funds []models.MutualFund
+	if err := db.Select("id", "name", "p_id", "external_id").Order("id ASC").Find(&funds).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.MutualFund, len(funds))
+	for _, f := range funds {
+		byID[f.ID] = f
+	}
+
+	matches := map[string]*FundMatch{}
+	var keys []string
+	for _, row := range rows {
+		if row.FundKey == "" {
+			continue
+		}
+		if match, ok := matches[row.FundKey]; ok {
+			match.Rows++
+			continue
+		}
+		keys = append(keys, row.FundKey)
+		match := &FundMatch{Key: row.FundKey, Name: row.fundName, Code: row.fundCode, Status: FundMatchUnmatched, Rows: 1}
+		matches[row.FundKey] = match
+
+		if id, ok := confirmed[row.FundKey]; ok {
+			if fund, exists := byID[id]; exists {
+				match.Status, match.MutualFundID = FundMatchConfirmed, fund.ID
+				match.Candidates = []FundCandidate{{ID: fund.ID, Name: fund.Name, PID: fund.PID}}
+				continue
+			}
+		}
+
+		var exact, similar []FundCandidate
+		name := normalizeImportText(row.fundName)
+		for _, f := range funds {
+			candidate := FundCandidate{ID: f.ID, Name: f.Name, PID: f.PID}
+			fundName := normalizeImportText(f.Name)
+			switch {
+			case row.fundCode != "" && (row.fundCode == strconv.FormatUint(uint64(f.PID), 10) || row.fundCode == f.ExternalID):
+				exact = append(exact, candidate)
+			case name != "" && name == fundName:
+				exact = append(exact, candidate)
+			case name != "" && fundName != "" && (strings.Contains(fundName, name) || strings.Contains(name, fundName)):
+				similar = append(similar, candidate)
+			}
+		}
+		switch {
+		case len(exact) == 1:
+			match.Status, match.MutualFundID = FundMatchMatched, exact[0].ID
+			match.Candidates = exact
+		case len(exact) > 1:
+			match.Status, match.Candidates = FundMatchAmbiguous, exact
+		case len(similar) > 0:
+			match.Status, match.Candidates = FundMatchAmbiguous, similar
+		}
+	}
+
+	result := make([]FundMatch, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, *matches[key])
+	}
+	return result, nil
+}
+
+// PreviewImport menjalankan dry run impor ke satu portfolio: mencocokkan reksa dana dan menandai
+// baris yang sudah ada di ledger (atau muncul dua kali di file) sebagai duplikat. Duplikat adalah
+// transaksi dengan reksa dana, jenis, tanggal dan nominal (atau unit jika nominal kosong) yang sama.
+func PreviewImport(db *gorm.DB, userID, portfolioID uint, source string, rows []ImportRow, confirmed map[string]uint) (*ImportPreview, error) {
+	funds, err := MatchImportFunds(db, rows, confirmed)
+	if err != nil {
+		return nil, err
+	}
+	fundIDs := make(map[string]uint, len(funds))
+	for _, f := range funds {
+		if f.Status == FundMatchMatched || f.Status == FundMatchConfirmed {
+			fundIDs[f.Key] = f.MutualFundID
+		}
+	}
+
+	existing, err := queryLedger(db, userID, portfolioID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &ImportPreview{Source: source, TotalRows: len(rows), Funds: funds, Rows: rows}
+	seen := make([]models.Transaction, 0, len(rows))
+	for i := range preview.Rows {
+		row := &preview.Rows[i]
+		if row.Status == ImportRowReady {
+			if id, ok := fundIDs[row.FundKey]; ok {
+				row.MutualFundID = id
+			} else {
+				row.Status, row.Detail = ImportRowUnmatched, "mutual fund not matched"
+			}
+		}
+		if row.Status == ImportRowReady {
+			candidate := row.transaction(userID, portfolioID)
+			if duplicateOf(candidate, existing) {
+				row.Status, row.Detail = ImportRowDuplicate, "already in ledger"
+			} else if duplicateOf(candidate, seen) {
+				row.Status, row.Detail = ImportRowDuplicate, "repeated in file"
+			} else if err := CheckManualDividend(db, candidate); err != nil {
+				if !errors.Is(err, ErrDistributionRecorded) {
+					return nil, err
+				}
+				// Dividen ini sudah dicatat otomatis dari distribusi reksa dana
+				row.Status, row.Detail = ImportRowDuplicate, "already recorded from the fund distribution"
+			} else {
+				seen = append(seen, candidate)
+			}
+		}
+
+		switch row.Status {
+		case ImportRowReady:
+			preview.Ready++
+		case ImportRowDuplicate:
+			preview.Duplicates++
+		case ImportRowUnmatched:
+			preview.Unmatched++
+		case ImportRowInvalid:
+			preview.Invalid++
+		}
+	}
+	return preview, nil
+}
+
+func (row ImportRow) transaction(userID, portfolioID uint) models.Transaction {
+	return models.Transaction{
+		UserID:       userID,
+		PortfolioID:  portfolioID,
+		MutualFundID: row.MutualFundID,
+		Type:         row.Type,
+		Date:         row.date,
+		Amount:       row.Amount,
+		Units:        row.Units,
+		Nav:          row.Nav,
+		Fee:          row.Fee,
+	}
+}
+
+func duplicateOf(tx models.Transaction, ledger []models.Transaction) bool {
+	for _, other := range ledger {
+		if other.MutualFundID != tx.MutualFundID || other.Type != tx.Type || !other.Date.Equal(tx.Date) {
+			continue
+		}
+		if tx.Amount > 0 && math.Abs(other.Amount-tx.Amount) < 0.01 {
+			return true
+		}
+		if tx.Amount == 0 && math.Abs(other.Units-tx.Units) < 1e-4 {
+			return true
+		}
+	}
+	return false
+}
+
+// CommitImport menyimpan semua baris ready dari preview dalam satu transaksi database, lalu menghitung
+// ulang lot dan nilai harian setiap reksa dana yang berubah. Baris duplikat dilewati. Gagal dengan
+// ErrImportNotReady jika masih ada baris unmatched/invalid dan ErrInsufficientUnits jika hasil impor
+// membuat unit yang dijual melebihi unit yang dimiliki.
+func CommitImport(db *gorm.DB, userID, portfolioID uint, fileName string, preview *ImportPreview) (*models.TransactionImport, error) {
+	if !preview.CanCommit() {
+		return nil, ErrImportNotReady
+	}
+
+	record := models.TransactionImport{
+		UserID:      userID,
+		PortfolioID: portfolioID,
+		Source:      preview.Source,
+		FileName:    fileName,
+		TotalRows:   preview.TotalRows,
+		Imported:    preview.Ready,
+		Duplicates:  preview.Duplicates,
+	}
+
+	err := db.Transaction(func(dbtx *gorm.DB) error {
+		if err := dbtx.Create(&record).Error; err != nil {
+			return err
+		}
+
+		changedFrom := map[uint]time.Time{}
+		currencies := map[uint]string{}
+		var txs []models.Transaction
+		for _, row := range preview.Rows {
+			if row.Status != ImportRowReady {
+				continue
+			}
+			tx := row.transaction(userID, portfolioID)
+			tx.ImportID = &record.ID
+			tx.Note = fmt.Sprintf("Import %s line %d", preview.Source, row.Line)
+			if tx.Type == models.TransactionSell || tx.Type == models.TransactionSwitchOut {
+				tx.CostMethod = models.CostMethodFIFO
+			}
+			// Nilai di file ekspor dianggap dalam mata uang reksa dana
+			currency, ok := currencies[tx.MutualFundID]
+			if !ok {
+				var err error
+				if currency, err = FundCurrency(dbtx, tx.MutualFundID); err != nil {
+					return fmt.Errorf("line %d: %w", row.Line, err)
+				}
+				currencies[tx.MutualFundID] = currency
+			}
+			tx.Currency = currency
+			// Angka broker dipakai apa adanya: jika nilai dan unit ada, NAV diturunkan dari keduanya.
+			// Baris yang kurang salah satunya dihitung dari NAV tersimpan; jika belum ada dibiarkan pending.
+			if tx.Units > 0 && tx.Amount > 0 {
+				if tx.Nav == 0 {
+					tx.Nav = impliedNav(tx)
+				}
+			} else if err := ApplyTradeNav(dbtx, &tx); err != nil && !errors.Is(err, ErrNavNotAvailable) {
+				return fmt.Errorf("line %d: %w", row.Line, err)
+			}
+			txs = append(txs, tx)
+			if from, ok := changedFrom[tx.MutualFundID]; !ok || tx.Date.Before(from) {
+				changedFrom[tx.MutualFundID] = tx.Date
+			}
+		}
+		if len(txs) == 0 {
+			return nil
+		}
+		if err := dbtx.CreateInBatches(&txs, 500).Error; err != nil {
+			return err
+		}
+		// Kedua kaki switching dihubungkan seperti hasil SwitchFunds
+		for _, pair := range pairSwitchLegs(txs) {
+			out, in := &txs[pair[0]], &txs[pair[1]]
+			out.LinkedTransactionID, in.LinkedTransactionID = &in.ID, &out.ID
+			if err := dbtx.Model(out).Update("linked_transaction_id", in.ID).Error; err != nil {
+				return err
+			}
+			if err := dbtx.Model(in).Update("linked_transaction_id", out.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		fundIDs := make([]uint, 0, len(changedFrom))
+		for id := range changedFrom {
+			fundIDs = append(fundIDs, id)
+		}
+		sort.Slice(fundIDs, func(i, j int) bool { return fundIDs[i] < fundIDs[j] })
+		for _, fundID := range fundIDs {
+			if err := CheckLedgerUnits(dbtx, userID, portfolioID, fundID); err != nil {
+				return err
+			}
+			if err := LedgerChanged(dbtx, userID, portfolioID, fundID, changedFrom[fundID]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// impliedNav menurunkan NAV dari nilai dan unit broker: Amount BUY/SWITCH_IN sudah termasuk fee,
+// sedangkan Amount SELL/SWITCH_OUT adalah nilai kotor sebelum fee (sama dengan ApplyTradeNav)
+func impliedNav(tx models.Transaction) float64 {
+	if tx.Type == models.TransactionBuy || tx.Type == models.TransactionSwitchIn {
+		return (tx.Amount - tx.Fee) / tx.Units
+	}
+	return tx.Amount / tx.Units
+}
+
+// pairSwitchLegs memasangkan setiap SWITCH_OUT dengan SWITCH_IN paling awal yang belum berpasangan, di
+// reksa dana lain dan bertanggal sama atau paling lambat switchSettlementDays hari sesudahnya.
+// Mengembalikan pasangan indeks [SWITCH_OUT, SWITCH_IN] di txs.
+func pairSwitchLegs(txs []models.Transaction) [][2]int {
+	var pairs [][2]int
+	paired := make([]bool, len(txs))
+	for i, out := range txs {
+		if out.Type != models.TransactionSwitchOut {
+			continue
+		}
+		latest := out.Date.AddDate(0, 0, switchSettlementDays)
+		best := -1
+		for j, in := range txs {
+			if paired[j] || in.Type != models.TransactionSwitchIn || in.MutualFundID == out.MutualFundID ||
+				in.Date.Before(out.Date) || in.Date.After(latest) {
+				continue
+			}
+			if best == -1 || in.Date.Before(txs[best].Date) {
+				best = j
+			}
+		}
+		if best >= 0 {
+			paired[i], paired[best] = true, true
+			pairs = append(pairs, [2]int{i, best})
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUpstreamUnavailable: upstream tidak bisa dihubungi, timeout, atau membalas 5xx/429
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	// ErrUpstreamMalformed: upstream membalas tapi bukan JSON yang valid (misalnya halaman error HTML)
+	ErrUpstreamMalformed = errors.New("upstream response malformed")
+	// ErrUpstreamRejected: upstream menolak request (4xx selain 429), tidak di-retry
+	ErrUpstreamRejected = errors.New("upstream rejected request")
+	// ErrCircuitOpen: upstream gagal terus-menerus, request langsung ditolak tanpa dikirim
+	ErrCircuitOpen = errors.New("upstream circuit breaker open")
+)
+
+// UpstreamError membawa detail kegagalan; errors.Is bisa dipakai terhadap error sentinel di atas
+type UpstreamError struct {
+	Kind       error
+	StatusCode int
+	Err        error
+}
+
+func (e *UpstreamError) Error() string {
+	msg := e.Kind.Error()
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%s (status %d)", msg, e.StatusCode)
+	}
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Kind
+}
+
+// UpstreamClient adalah HTTP client bersama untuk API luar (Bareksa) dengan retry,
+// exponential backoff + jitter, validasi response, dan circuit breaker.
+type UpstreamClient struct {
+	Name        string
+	HTTP        *http.Client
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Breaker     *CircuitBreaker
+}
+
+func NewUpstreamClient(name string) *UpstreamClient {
+	return &UpstreamClient{
+		Name: name,
+		HTTP: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		MaxAttempts: 3,
+		BaseDelay:   300 * time.Millisecond,
+		MaxDelay:    3 * time.Second,
+		Breaker:     NewCircuitBreaker(5, 30*time.Second),
+	}
+}
+
+// BareksaClient dipakai oleh semua pemanggilan ke Bareksa
+var BareksaClient = NewUpstreamClient("bareksa")
+
+// GetJSON mengirim GET dan mengembalikan body yang dijamin berupa JSON valid
+func (uc *UpstreamClient) GetJSON(url string, header http.Header) ([]byte, error) {
+	if !uc.Breaker.Allow() {
+		return nil, &UpstreamError{Kind: ErrCircuitOpen}
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= uc.MaxAttempts; attempt++ {
+		body, err := uc.getOnce(url, header)
+		if err == nil {
+			uc.Breaker.Success()
+			return body, nil
+		}
+		lastErr = err
+
+		// Request yang ditolak upstream tidak akan berhasil jika diulang
+		if errors.Is(err, ErrUpstreamRejected) {
+			uc.Breaker.Success()
+			return nil, err
+		}
+
+		log.Printf("%s request attempt %d/%d failed: %v", uc.Name, attempt, uc.MaxAttempts, err)
+		if attempt < uc.MaxAttempts {
+			time.Sleep(uc.backoff(attempt))
+		}
+	}
+
+	uc.Breaker.Failure()
+	return nil, lastErr
+}
+
+func (uc *UpstreamClient) getOnce(url string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := uc.HTTP.Do(req)
+	if err != nil {
+		return nil, &UpstreamError{Kind: ErrUpstreamUnavailable, Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &UpstreamError{Kind: ErrUpstreamUnavailable, StatusCode: resp.StatusCode, Err: err}
+	}
+
+	switch {
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, &UpstreamError{Kind: ErrUpstreamUnavailable, StatusCode: resp.StatusCode}
+	case resp.StatusCode != http.StatusOK:
+		return nil, &UpstreamError{Kind: ErrUpstreamRejected, StatusCode: resp.StatusCode}
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "json") && !strings.HasPrefix(contentType, "text/plain") {
+		return nil, &UpstreamError{Kind: ErrUpstreamMalformed, StatusCode: resp.StatusCode, Err: fmt.Errorf("unexpected content type %q", contentType)}
+	}
+	if !json.Valid(body) {
+		return nil, &UpstreamError{Kind: ErrUpstreamMalformed, StatusCode: resp.StatusCode, Err: errors.New("body is not valid JSON")}
+	}
+
+	return body, nil
+}
+
+// backoff menghitung jeda sebelum percobaan berikutnya: BaseDelay * 2^(attempt-1), maksimal
+// MaxDelay, dengan full jitter supaya request yang gagal bersamaan tidak mengulang serentak.
+func (uc *UpstreamClient) backoff(attempt int) time.Duration {
+	delay := uc.BaseDelay << (attempt - 1)
+	if delay > uc.MaxDelay || delay <= 0 {
+		delay = uc.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// CircuitBreaker membuka sirkuit setelah Threshold kegagalan berturut-turut. Selama terbuka
+// semua request ditolak; setelah Cooldown satu request percobaan diizinkan (half-open).
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown}
+}
+
+// Allow mengecek apakah request boleh dikirim
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.failures < cb.Threshold {
+		return true
+	}
+	if time.Now().Before(cb.openUntil) || cb.probing {
+		return false
+	}
+	cb.probing = true
+	return true
+}
+
+func (cb *CircuitBreaker) Success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures = 0
+	cb.probing = false
+}
+
+func (cb *CircuitBreaker) Failure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures++
+	cb.probing = false
+	if cb.failures >= cb.Threshold {
+		cb.openUntil = time.Now().Add(cb.Cooldown)
+	}
+}
+
+// Open mengecek apakah sirkuit sedang terbuka
+func (cb *CircuitBreaker) Open() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.failures >= cb.Threshold && time.Now().Before(cb.openUntil)
+}
+
+// IsUpstreamFailure mengecek apakah err berasal dari kegagalan upstream (bukan kesalahan input)
+func IsUpstreamFailure(err error) bool {
+	return errors.Is(err, ErrUpstreamUnavailable) || errors.Is(err, ErrUpstreamMalformed) || errors.Is(err, ErrCircuitOpen)
+}
@@ -0,0 +1,306 @@
+package utils
+
+import (
+	"errors"
+	"golang/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// valuationBatchSize membatasi jumlah baris per INSERT saat menulis portfolio_valuations
+const valuationBatchSize = 500
+
+const (
+	// valuationRefreshLease adalah lama klaim satu baris antrean valuation_refreshes
+	valuationRefreshLease = 30 * time.Minute
+	// valuationRetryDelay adalah jeda sebelum baris antrean yang gagal dicoba lagi
+	valuationRetryDelay = 5 * time.Minute
+)
+
+// RefreshPortfolioValuations menghitung ulang portfolio_valuations satu portfolio user untuk satu
+// reksa dana mulai tanggal from. Posisi sebelum from diturunkan dari ledger; NAV yang dibaca hanya dari NAV terakhir
+// sebelum from, sehingga perubahan di ujung riwayat tidak menghitung ulang seluruh riwayat.
+// Hanya memakai data tersimpan (tanpa memanggil provider NAV).
+func RefreshPortfolioValuations(db *gorm.DB, userID, portfolioID, fundID uint, from time.Time) error {
+	txs, err := queryLedger(db, userID, portfolioID, fundID)
+	if err != nil {
+		return err
+	}
+	from = DateOnly(from)
+
+	var navs []models.NavPrice
+	if len(txs) > 0 {
+		// NAV terakhir sebelum from menjadi titik awal untuk perubahan harian tanggal from
+		var anchor models.NavPrice
+		start := from
+		err := db.Where("mutual_fund_id = ? AND date < ?", fundID, from).Order("date DESC").First(&anchor).Error
+		if err == nil {
+			start = anchor.Date
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := db.Where("mutual_fund_id = ? AND date >= ?", fundID, start).Order("date ASC").Find(&navs).Error; err != nil {
+			return err
+		}
+	}
+
+	var rows []models.PortfolioValuation
+	for _, day := range BuildDailyValuation(txs, navs) {
+		if day.Date.Before(from) {
+			continue
+		}
+		rows = append(rows, models.PortfolioValuation{
+			UserID:       userID,
+			PortfolioID:  portfolioID,
+			MutualFundID: fundID,
+			Date:         day.Date,
+			Nav:          day.Nav,
+			Units:        day.Units,
+			CostBasis:    day.CostBasis,
+			Value:        day.Value,
+			CashIn:       day.CashIn,
+			CashOut:      day.CashOut,
+			DailyGain:    day.DailyGain,
+			TotalGain:    day.TotalGain,
+			Fees:         day.Fees,
+			Dividends:    day.Dividends,
+		})
+	}
+
+	return db.Transaction(func(dbtx *gorm.DB) error {
+		if err := dbtx.Where("user_id = ? AND portfolio_id = ? AND mutual_fund_id = ? AND date >= ?", userID, portfolioID, fundID, from).
+			Delete(&models.PortfolioValuation{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return dbtx.CreateInBatches(&rows, valuationBatchSize).Error
+	})
+}
+
+// ledgerHolders mengembalikan pasangan (user, portfolio) yang punya transaksi aktif di reksa dana
+// fundID, dibatasi ke satu user jika userID bukan 0
+func ledgerHolders(db *gorm.DB, userID, fundID uint) ([]models.Transaction, error) {
+	query := db.Model(&models.Transaction{}).Where("mutual_fund_id = ? AND deleted_at IS NULL", fundID)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	var holders []models.Transaction
+	err := query.Distinct("user_id", "portfolio_id").Order("user_id, portfolio_id").Find(&holders).Error
+	return holders, err
+}
+
+// RefreshFundValuations memperbarui portfolio_valuations semua portfolio yang memegang reksa dana
+// fundID mulai tanggal from, dipanggil setelah NAV baru tersimpan. Kegagalan per portfolio dicatat, portfolio
+// lain tetap diproses, lalu error pertama dikembalikan supaya antrean mengulanginya.
+func RefreshFundValuations(db *gorm.DB, fundID uint, from time.Time) error {
+	holders, err := ledgerHolders(db, 0, fundID)
+	if err != nil {
+		return err
+	}
+	var first error
+	for _, h := range holders {
+		if err := RefreshPortfolioValuations(db, h.UserID, h.PortfolioID, fundID, from); err != nil {
+			log.Printf("Failed to refresh valuations for user %d portfolio %d fund %d: %v", h.UserID, h.PortfolioID, fundID, err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// QueueFundValuationRefresh menjadwalkan RefreshFundValuations (atau ApplyFundDistributions jika
+// applyDistributions) untuk reksa dana fundID mulai tanggal from di background, supaya request yang
+// menulis NAV atau distribusi tidak menunggu perhitungan ulang semua pemegangnya. Jika antrean gagal
+// ditulis, perhitungan dijalankan langsung.
+func QueueFundValuationRefresh(db *gorm.DB, fundID uint, from time.Time, applyDistributions bool) {
+	job := models.ValuationRefresh{
+		MutualFundID:       fundID,
+		FromDate:           DateOnly(from),
+		ApplyDistributions: applyDistributions,
+		QueuedAt:           time.Now(),
+	}
+	err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "mutual_fund_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"from_date":           gorm.Expr("LEAST(valuation_refreshes.from_date, EXCLUDED.from_date)"),
+			"apply_distributions": gorm.Expr("valuation_refreshes.apply_distributions OR EXCLUDED.apply_distributions"),
+			"queued_at":           gorm.Expr("EXCLUDED.queued_at"),
+		}),
+	}).Create(&job).Error
+	if err == nil {
+		return
+	}
+	log.Printf("Failed to queue valuation refresh of fund %d, running it now: %v", fundID, err)
+	if err := runValuationRefresh(db, job); err != nil {
+		log.Printf("Failed to refresh valuations of fund %d: %v", fundID, err)
+	}
+}
+
+// ProcessValuationRefreshes mengerjakan antrean valuation_refreshes yang siap dan mengembalikan jumlah
+// reksa dana yang berhasil diproses. Setiap baris diklaim dengan mengisi locked_until (SKIP LOCKED), jadi
+// beberapa instance bisa memproses antrean bersamaan tanpa mengerjakan reksa dana yang sama dua kali, dan
+// baris yang diklaim instance yang mati diambil lagi setelah klaimnya habis. Baris baru dihapus setelah
+// berhasil; yang gagal diulang setelah valuationRetryDelay.
+func ProcessValuationRefreshes(db *gorm.DB) (int, error) {
+	processed := 0
+	for {
+		var job models.ValuationRefresh
+		err := db.Raw(`UPDATE valuation_refreshes SET locked_until = ?, attempts = attempts + 1 WHERE id = (
+			SELECT id FROM valuation_refreshes WHERE locked_until IS NULL OR locked_until <= ?
+			ORDER BY queued_at ASC LIMIT 1 FOR UPDATE SKIP LOCKED
+		) RETURNING *`, time.Now().Add(valuationRefreshLease), time.Now()).Scan(&job).Error
+		if err != nil {
+			return processed, err
+		}
+		if job.ID == 0 {
+			return processed, nil
+		}
+
+		if err := runValuationRefresh(db, job); err != nil {
+			log.Printf("Valuation refresh of fund %d failed (attempt %d): %v", job.MutualFundID, job.Attempts, err)
+			if err := db.Model(&job).Updates(map[string]interface{}{
+				"locked_until": time.Now().Add(valuationRetryDelay),
+				"last_error":   err.Error(),
+			}).Error; err != nil {
+				return processed, err
+			}
+			continue
+		}
+
+		// Permintaan yang masuk selama diproses mengganti queued_at dan harus dikerjakan lagi
+		done := db.Where("id = ? AND queued_at = ?", job.ID, job.QueuedAt).Delete(&models.ValuationRefresh{})
+		if done.Error != nil {
+			return processed, done.Error
+		}
+		if done.RowsAffected == 0 {
+			if err := db.Model(&job).Update("locked_until", nil).Error; err != nil {
+				return processed, err
+			}
+		}
+		processed++
+	}
+}
+
+func runValuationRefresh(db *gorm.DB, job models.ValuationRefresh) error {
+	if job.ApplyDistributions {
+		return ApplyFundDistributions(db, job.MutualFundID, job.FromDate)
+	}
+	return RefreshFundValuations(db, job.MutualFundID, job.FromDate)
+}
+
+// LedgerChanged dipanggil setelah ledger satu portfolio user untuk satu reksa dana berubah mulai
+// tanggal from: menyinkronkan DIVIDEND hasil distribusi, lalu menghitung ulang pencocokan lot dan
+// portfolio_valuations. Distribusi hanya bergantung pada unit sebelum ex-date, jadi DIVIDEND yang
+// berubah selalu bertanggal setelah from.
+func LedgerChanged(db *gorm.DB, userID, portfolioID, fundID uint, from time.Time) error {
+	if err := SyncDistributionTransactions(db, userID, portfolioID, fundID); err != nil {
+		return err
+	}
+	if err := SyncRealizedGains(db, userID, portfolioID, fundID); err != nil {
+		return err
+	}
+	return RefreshPortfolioValuations(db, userID, portfolioID, fundID, from)
+}
+
+// StoredDailyValuations membaca portfolio_valuations user untuk satu reksa dana sampai tanggal end,
+// untuk satu portfolio atau dijumlahkan per tanggal untuk semua portfolio (portfolioID 0).
+// Jika tabel belum lengkap dibanding NAV tersimpan terakhir, bagian yang kurang dihitung dulu.
+func StoredDailyValuations(db *gorm.DB, userID, portfolioID, fundID uint, end time.Time) ([]DailyValuation, error) {
+	portfolioIDs := []uint{portfolioID}
+	if portfolioID == 0 {
+		holders, err := ledgerHolders(db, userID, fundID)
+		if err != nil {
+			return nil, err
+		}
+		portfolioIDs = portfolioIDs[:0]
+		for _, h := range holders {
+			portfolioIDs = append(portfolioIDs, h.PortfolioID)
+		}
+	}
+	for _, id := range portfolioIDs {
+		if err := ensurePortfolioValuations(db, userID, id, fundID, end); err != nil {
+			return nil, err
+		}
+	}
+
+	var rows []models.PortfolioValuation
+	query := db.Model(&models.PortfolioValuation{}).
+		Where("user_id = ? AND mutual_fund_id = ? AND date <= ?", userID, fundID, DateOnly(end))
+	if portfolioID != 0 {
+		query = query.Where("portfolio_id = ?", portfolioID)
+	} else {
+		query = query.Select(`date, MAX(nav) AS nav, SUM(units) AS units, SUM(cost_basis) AS cost_basis,
+			SUM(value) AS value, SUM(cash_in) AS cash_in, SUM(cash_out) AS cash_out,
+			SUM(daily_gain) AS daily_gain, SUM(total_gain) AS total_gain, SUM(fees) AS fees, SUM(dividends) AS dividends`).Group("date")
+	}
+	if err := query.Order("date ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	days := make([]DailyValuation, 0, len(rows))
+	var prevNav, prevValue float64
+	for _, row := range rows {
+		day := DailyValuation{
+			Date:           row.Date,
+			Nav:            row.Nav,
+			Units:          row.Units,
+			CostBasis:      row.CostBasis,
+			Value:          row.Value,
+			CashIn:         row.CashIn,
+			CashOut:        row.CashOut,
+			DailyGain:      row.DailyGain,
+			TotalGain:      row.TotalGain,
+			Fees:           row.Fees,
+			GrossTotalGain: row.TotalGain + row.Fees,
+			Dividends:      row.Dividends,
+		}
+		if prevNav != 0 {
+			day.NavChange = row.Nav - prevNav
+			day.NavChangePercent = day.NavChange / prevNav * 100
+		}
+		if base := prevValue + row.CashIn; base != 0 {
+			day.DailyGainPercent = row.DailyGain / base * 100
+		}
+		days = append(days, day)
+		prevNav, prevValue = row.Nav, row.Value
+	}
+	return days, nil
+}
+
+// ensurePortfolioValuations melengkapi portfolio_valuations yang tertinggal dari nav_prices, misalnya
+// untuk data lama sebelum tabel ini ada, NAV yang baru masuk, lubang di tengah riwayat (NAV yang diisi
+// belakangan lewat backfill), atau NAV yang dikoreksi. Perhitungan ulang dimulai dari tanggal NAV pertama
+// yang belum punya baris atau barisnya memakai NAV berbeda.
+func ensurePortfolioValuations(db *gorm.DB, userID, portfolioID, fundID uint, end time.Time) error {
+	var first struct {
+		Date *time.Time
+	}
+	if err := db.Model(&models.Transaction{}).Select("MIN(date) AS date").
+		Where("user_id = ? AND portfolio_id = ? AND mutual_fund_id = ? AND deleted_at IS NULL", userID, portfolioID, fundID).
+		Scan(&first).Error; err != nil {
+		return err
+	}
+	if first.Date == nil {
+		return nil
+	}
+
+	var missing struct {
+		Date *time.Time
+	}
+	if err := db.Raw(`SELECT MIN(n.date) AS date FROM nav_prices n
+		LEFT JOIN portfolio_valuations v ON v.user_id = ? AND v.portfolio_id = ? AND v.mutual_fund_id = n.mutual_fund_id AND v.date = n.date
+		WHERE n.mutual_fund_id = ? AND n.date >= ? AND n.date <= ? AND (v.id IS NULL OR v.nav <> n.nav)`,
+		userID, portfolioID, fundID, DateOnly(*first.Date), DateOnly(end)).Scan(&missing).Error; err != nil {
+		return err
+	}
+	if missing.Date == nil {
+		return nil
+	}
+	return RefreshPortfolioValuations(db, userID, portfolioID, fundID, *missing.Date)
+}